@@ -0,0 +1,106 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Authors:
+ *	- Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package cmd
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+	"github.com/wtsi-hgi/dimsum-automation/mlwh"
+)
+
+// options for this cmd.
+var (
+	devFixture    string
+	devSQLitePath string
+)
+
+// devCmd represents the dev command.
+var devCmd = &cobra.Command{
+	Use:   "dev",
+	Short: "Development helpers.",
+	Long: `Development helpers.
+
+The various dev sub-commands help you develop and test dimsum-automation without
+access to production resources.
+`,
+}
+
+// seedMLWHCmd represents the seed-mlwh command.
+var seedMLWHCmd = &cobra.Command{
+	Use:   "seed-mlwh",
+	Short: "Create a SQLite stand-in for MLWH.",
+	Long: `Create a SQLite stand-in for MLWH.
+
+Given a JSON fixture file with rows for the study, sample, iseq_flowcell,
+iseq_run and iseq_run_lane_metrics MLWH tables (see mlwh/testdata/mlwh.json for
+an example), creates or replaces the data in a SQLite database at the --db path.
+
+If you then set:
+
+export DIMSUM_AUTOMATION_SQLITE_DB=/path/to/mlwh.db
+
+the info and run sub-commands will query that database instead of the real MLWH,
+and the DIMSUM_AUTOMATION_SQL_* environment variables are not required.
+`,
+	Run: func(cmd *cobra.Command, _ []string) {
+		err := seedMLWH(cmd.Context(), devFixture, devSQLitePath)
+		if err != nil {
+			die(err)
+		}
+
+		infof("seeded %s from %s", devSQLitePath, devFixture)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(devCmd)
+	devCmd.AddCommand(seedMLWHCmd)
+
+	seedMLWHCmd.Flags().StringVarP(&devFixture, "fixture", "f", "",
+		"path to JSON fixture file")
+	markFlagRequired(seedMLWHCmd, "fixture")
+	seedMLWHCmd.Flags().StringVarP(&devSQLitePath, "db", "d", "",
+		"path to SQLite database file")
+	markFlagRequired(seedMLWHCmd, "db")
+}
+
+func seedMLWH(ctx context.Context, fixturePath, dbPath string) error {
+	f, err := mlwh.LoadFixture(fixturePath)
+	if err != nil {
+		return err
+	}
+
+	m, err := mlwh.NewSQLite(dbPath)
+	if err != nil {
+		return err
+	}
+
+	defer m.Close()
+
+	return m.Seed(ctx, f)
+}
@@ -0,0 +1,154 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/wtsi-hgi/dimsum-automation/config"
+	"github.com/wtsi-hgi/dimsum-automation/dimsum"
+	"github.com/wtsi-hgi/dimsum-automation/sheets"
+	"github.com/wtsi-hgi/dimsum-automation/types"
+)
+
+const (
+	snapshotDir       = "dimsum-automation"
+	snapshotExt       = ".json"
+	snapshotNameBytes = 8
+)
+
+// diffSheet compares the metadata in the configured spreadsheet to the snapshot
+// of it saved by the previous call, printing what changed, then saves a new
+// snapshot. If outputsDir is not blank, existing dimsum output directories in
+// it for changed experiments are listed.
+func diffSheet(c *config.Config, snapshotPath, outputsDir string) error {
+	s, err := sheets.FromConfig(c)
+	if err != nil {
+		return err
+	}
+
+	libs, err := s.DimSumMetaData(c.SheetID)
+	if err != nil {
+		return err
+	}
+
+	if snapshotPath == "" {
+		snapshotPath, err = defaultSnapshotPath(c)
+		if err != nil {
+			return err
+		}
+	}
+
+	current := sheets.NewSnapshot(libs)
+
+	prev, err := sheets.LoadSnapshot(snapshotPath)
+
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		cliPrintf("No previous snapshot of the spreadsheet to compare to; saving one to %s\n", snapshotPath)
+	case err != nil:
+		return err
+	default:
+		reportChanges(prev, current, outputsDir)
+	}
+
+	return current.Save(snapshotPath)
+}
+
+// defaultSnapshotPath returns a path in the user's cache directory unique to
+// the configured spreadsheet.
+func defaultSnapshotPath(c *config.Config) (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	source := c.SheetID
+	if c.SheetPath != "" {
+		source, err = filepath.Abs(c.SheetPath)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	sum := sha256.Sum256([]byte(source))
+
+	return filepath.Join(cacheDir, snapshotDir, hex.EncodeToString(sum[:snapshotNameBytes])+snapshotExt), nil
+}
+
+func reportChanges(prev, current *sheets.Snapshot, outputsDir string) {
+	changes := current.Diff(prev)
+	if len(changes) == 0 {
+		cliPrintf("No changes to the spreadsheet since %s\n", prev.Taken.Format(sheets.RunTimeFormat))
+
+		return
+	}
+
+	cliPrintf("Changes to the spreadsheet since %s:\n", prev.Taken.Format(sheets.RunTimeFormat))
+
+	for _, change := range changes {
+		cliPrintf("- %s\n", change)
+	}
+
+	if outputsDir == "" {
+		return
+	}
+
+	for _, change := range changes {
+		if change.Kind != sheets.RowKindExperiment || change.Change != sheets.RowChanged {
+			continue
+		}
+
+		reportStaleOutputs(prev.Experiments[change.ID], current.Experiments[change.ID], outputsDir)
+	}
+}
+
+// reportStaleOutputs lists the existing dimsum output directories for the given
+// changed experiment, saying if a new run would have a different key, or would
+// reuse the old outputs despite the changes.
+func reportStaleOutputs(old, current *types.Experiment, outputsDir string) {
+	dirs, err := filepath.Glob(filepath.Join(outputsDir, current.ExperimentID, "*", "*"))
+	if err != nil || len(dirs) == 0 {
+		return
+	}
+
+	if dimsum.KeyChanged(old, current) {
+		cliPrintf("Existing outputs of experiment %s were made with old parameters; "+
+			"new runs will have a different key:\n", current.ExperimentID)
+	} else {
+		cliPrintf("Existing outputs of experiment %s were made with old parameters, "+
+			"but new runs would have the same key and reuse them:\n", current.ExperimentID)
+	}
+
+	for _, dir := range dirs {
+		cliPrintf("  %s\n", dir)
+	}
+}
@@ -0,0 +1,338 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Authors:
+ *	- Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package cmd
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/wtsi-hgi/dimsum-automation/dimsum"
+	"github.com/wtsi-hgi/dimsum-automation/itl"
+	"github.com/wtsi-hgi/dimsum-automation/types"
+)
+
+const (
+	ErrInvalidSort    = Error("invalid sort field")
+	ErrInvalidQCState = Error("invalid --qc-state; must be " + types.QCStatePassed + ", " +
+		types.QCStateFailed + " or " + types.QCStatePending + " (or passed or failed)")
+
+	dateFormat = "2006-01-02"
+)
+
+// options for filtering and sorting info output.
+var (
+	infoLane            int
+	infoTagIndex        int
+	infoInstrumentModel string
+	infoPipeline        string
+	infoMinReadLength   int
+	infoCompletedAfter  string
+	infoCompletedBefore string
+	infoSort            string
+	infoLibraryIDs      []string
+	infoExperimentIDs   []string
+	infoStudyName       string
+	infoAssay           string
+	infoMinRun          string
+	infoMaxRun          string
+	infoQCStates        []string
+	infoHasFastqs       string
+	infoHasOutput       string
+)
+
+// sampleSorters are the less functions for the fields that info can sort sample
+// runs on.
+var sampleSorters = map[string]func(a, b *types.Sample) bool{ //nolint:gochecknoglobals
+	"run": func(a, b *types.Sample) bool {
+		return types.CompareRunIDs(a.RunID, b.RunID) < 0
+	},
+	"lane": func(a, b *types.Sample) bool {
+		return firstLane(a) < firstLane(b)
+	},
+	"tag_index": func(a, b *types.Sample) bool {
+		return a.TagIndex < b.TagIndex
+	},
+	"instrument_model": func(a, b *types.Sample) bool {
+		return a.InstrumentModel < b.InstrumentModel
+	},
+	"run_complete": func(a, b *types.Sample) bool {
+		return a.RunComplete.Before(b.RunComplete)
+	},
+	"read_length": func(a, b *types.Sample) bool {
+		return a.ReadLength < b.ReadLength
+	},
+	"pipeline_id_lims": func(a, b *types.Sample) bool {
+		return a.PipelineIDLims < b.PipelineIDLims
+	},
+}
+
+func firstLane(s *types.Sample) int {
+	if len(s.Lanes) == 0 {
+		return 0
+	}
+
+	return s.Lanes[0]
+}
+
+// addFilterFlags adds the flags for filtering and sorting sample runs to the
+// given command.
+func addFilterFlags(cmd *cobra.Command) {
+	sortFields := make([]string, 0, len(sampleSorters))
+	for field := range sampleSorters {
+		sortFields = append(sortFields, field)
+	}
+
+	sort.Strings(sortFields)
+
+	cmd.Flags().IntVar(&infoLane, "lane", 0, "only show sample runs sequenced on this lane")
+	cmd.Flags().IntVar(&infoTagIndex, "tag-index", 0, "only show sample runs with this tag index")
+	cmd.Flags().StringVar(&infoInstrumentModel, "instrument-model", "",
+		"only show sample runs sequenced on this instrument model")
+	cmd.Flags().StringVar(&infoPipeline, "pipeline-id-lims", "",
+		"only show sample runs with this pipeline id_lims (library type)")
+	cmd.Flags().IntVar(&infoMinReadLength, "min-read-length", 0,
+		"only show sample runs with at least this read length")
+	cmd.Flags().StringVar(&infoCompletedAfter, "completed-after", "",
+		"only show sample runs completed on or after this date (YYYY-MM-DD)")
+	cmd.Flags().StringVar(&infoCompletedBefore, "completed-before", "",
+		"only show sample runs completed before this date (YYYY-MM-DD)")
+	cmd.Flags().StringSliceVar(&infoLibraryIDs, "library", nil, "only show sample runs of this library (can be repeated)")
+	cmd.Flags().StringSliceVar(&infoExperimentIDs, "experiment", nil,
+		"only show sample runs of this experiment (can be repeated)")
+	cmd.Flags().StringVar(&infoStudyName, "study-name", "",
+		"only show sample runs of studies with names containing this text")
+	cmd.Flags().StringVar(&infoAssay, "assay", "", "only show sample runs of experiments with this assay")
+	cmd.Flags().StringVar(&infoMinRun, "min-run", "", "only show sample runs with at least this run ID")
+	cmd.Flags().StringVar(&infoMaxRun, "max-run", "", "only show sample runs with at most this run ID")
+	cmd.Flags().StringSliceVar(&infoQCStates, "qc-state", nil,
+		"only show sample runs in this manual QC state: pass, fail or pending, or passed or failed as "+
+			"for --qc (can be repeated; see also --qc)")
+	cmd.Flags().StringVar(&infoHasFastqs, "has-fastqs", "",
+		"only show sample runs whose fastqs are already in this directory (the -o of run irods-to-lustre)")
+	cmd.Flags().StringVar(&infoHasOutput, "has-dimsum-output", "",
+		"only show sample runs with a completed dimsum output in this directory (the -o of run dimsum)")
+	cmd.Flags().StringVar(&infoSort, "sort", "",
+		"sort sample runs within each experiment by one of: "+strings.Join(sortFields, ", "))
+}
+
+// filterAndSortLibs returns the sample runs in the given libraries that match
+// the filter flags, sorted according to the sort flag.
+func filterAndSortLibs(libs types.Libraries) (types.Libraries, error) {
+	keeps, err := sampleFilters()
+	if err != nil {
+		return nil, err
+	}
+
+	filtered := libs.Filter(func(lib *types.Library, exp *types.Experiment, s *types.Sample) bool {
+		for _, keep := range keeps {
+			if !keep(lib, exp, s) {
+				return false
+			}
+		}
+
+		return true
+	})
+
+	if infoSort == "" {
+		return filtered, nil
+	}
+
+	less, ok := sampleSorters[infoSort]
+	if !ok {
+		return nil, ErrInvalidSort
+	}
+
+	filtered.SortSamples(less)
+
+	return filtered, nil
+}
+
+type sampleFilter func(lib *types.Library, exp *types.Experiment, s *types.Sample) bool
+
+// sampleFilters returns a sampleFilter for each filter flag that was set.
+func sampleFilters() ([]sampleFilter, error) { //nolint:gocognit,gocyclo,funlen
+	var keeps []sampleFilter
+
+	if infoLane != 0 {
+		keeps = append(keeps, func(_ *types.Library, _ *types.Experiment, s *types.Sample) bool {
+			return slices.Contains(s.Lanes, infoLane)
+		})
+	}
+
+	if infoTagIndex != 0 {
+		keeps = append(keeps, func(_ *types.Library, _ *types.Experiment, s *types.Sample) bool {
+			return s.TagIndex == infoTagIndex
+		})
+	}
+
+	if infoInstrumentModel != "" {
+		keeps = append(keeps, func(_ *types.Library, _ *types.Experiment, s *types.Sample) bool {
+			return strings.EqualFold(s.InstrumentModel, infoInstrumentModel)
+		})
+	}
+
+	if infoPipeline != "" {
+		keeps = append(keeps, func(_ *types.Library, _ *types.Experiment, s *types.Sample) bool {
+			return strings.EqualFold(s.PipelineIDLims, infoPipeline)
+		})
+	}
+
+	if infoMinReadLength != 0 {
+		keeps = append(keeps, func(_ *types.Library, _ *types.Experiment, s *types.Sample) bool {
+			return s.ReadLength >= infoMinReadLength
+		})
+	}
+
+	dateFilters, err := completionDateFilters()
+	if err != nil {
+		return nil, err
+	}
+
+	idFilters, err := identityFilters()
+	if err != nil {
+		return nil, err
+	}
+
+	keeps = append(keeps, dateFilters...)
+	keeps = append(keeps, idFilters...)
+
+	return append(keeps, outputFilters()...), nil
+}
+
+// identityFilters returns sampleFilters for the library, experiment,
+// study-name, assay, min-run, max-run and qc-state flags, if set.
+func identityFilters() ([]sampleFilter, error) { //nolint:gocognit,gocyclo,funlen
+	var keeps []sampleFilter
+
+	if len(infoLibraryIDs) > 0 {
+		keeps = append(keeps, func(lib *types.Library, _ *types.Experiment, _ *types.Sample) bool {
+			return slices.Contains(infoLibraryIDs, lib.LibraryID)
+		})
+	}
+
+	if len(infoExperimentIDs) > 0 {
+		keeps = append(keeps, func(_ *types.Library, exp *types.Experiment, _ *types.Sample) bool {
+			return slices.Contains(infoExperimentIDs, exp.ExperimentID)
+		})
+	}
+
+	if infoStudyName != "" {
+		keeps = append(keeps, func(_ *types.Library, _ *types.Experiment, s *types.Sample) bool {
+			return strings.Contains(strings.ToLower(s.StudyName), strings.ToLower(infoStudyName))
+		})
+	}
+
+	if infoAssay != "" {
+		keeps = append(keeps, func(_ *types.Library, exp *types.Experiment, _ *types.Sample) bool {
+			return strings.EqualFold(exp.Assay, infoAssay)
+		})
+	}
+
+	if infoMinRun != "" {
+		keeps = append(keeps, func(_ *types.Library, _ *types.Experiment, s *types.Sample) bool {
+			return types.CompareRunIDs(s.RunID, infoMinRun) >= 0
+		})
+	}
+
+	if infoMaxRun != "" {
+		keeps = append(keeps, func(_ *types.Library, _ *types.Experiment, s *types.Sample) bool {
+			return types.CompareRunIDs(s.RunID, infoMaxRun) <= 0
+		})
+	}
+
+	if len(infoQCStates) == 0 {
+		return keeps, nil
+	}
+
+	states := make([]string, len(infoQCStates))
+
+	for i, state := range infoQCStates {
+		var err error
+
+		states[i], err = types.StringToQCState(state)
+		if err != nil {
+			return nil, ErrInvalidQCState
+		}
+	}
+
+	return append(keeps, func(_ *types.Library, _ *types.Experiment, s *types.Sample) bool {
+		return slices.Contains(states, s.QCState())
+	}), nil
+}
+
+// outputFilters returns sampleFilters for the has-fastqs and has-dimsum-output
+// flags, if set.
+func outputFilters() []sampleFilter {
+	var keeps []sampleFilter
+
+	if infoHasFastqs != "" {
+		keeps = append(keeps, func(_ *types.Library, _ *types.Experiment, s *types.Sample) bool {
+			return itl.HasFastqs(s, infoHasFastqs)
+		})
+	}
+
+	if infoHasOutput != "" {
+		keeps = append(keeps, func(_ *types.Library, exp *types.Experiment, s *types.Sample) bool {
+			return dimsum.HasOutput(infoHasOutput, exp.ExperimentID, s)
+		})
+	}
+
+	return keeps
+}
+
+// completionDateFilters returns sampleFilters for the completed-after and
+// completed-before flags, if set.
+func completionDateFilters() ([]sampleFilter, error) {
+	var keeps []sampleFilter
+
+	if infoCompletedAfter != "" {
+		after, err := time.Parse(dateFormat, infoCompletedAfter)
+		if err != nil {
+			return nil, err
+		}
+
+		keeps = append(keeps, func(_ *types.Library, _ *types.Experiment, s *types.Sample) bool {
+			return !s.RunComplete.Before(after)
+		})
+	}
+
+	if infoCompletedBefore != "" {
+		before, err := time.Parse(dateFormat, infoCompletedBefore)
+		if err != nil {
+			return nil, err
+		}
+
+		keeps = append(keeps, func(_ *types.Library, _ *types.Experiment, s *types.Sample) bool {
+			return !s.RunComplete.IsZero() && s.RunComplete.Before(before)
+		})
+	}
+
+	return keeps, nil
+}
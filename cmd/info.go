@@ -26,12 +26,15 @@
 package cmd
 
 import (
-	"encoding/json"
+	"bytes"
+	"context"
+	"os"
 	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/wtsi-hgi/dimsum-automation/config"
 	"github.com/wtsi-hgi/dimsum-automation/mlwh"
+	"github.com/wtsi-hgi/dimsum-automation/report"
 	"github.com/wtsi-hgi/dimsum-automation/samples"
 	"github.com/wtsi-hgi/dimsum-automation/sheets"
 	"github.com/wtsi-hgi/dimsum-automation/types"
@@ -40,6 +43,34 @@ import (
 const (
 	sponsor       = "Ben Lehner"
 	cacheLifetime = 10 * time.Minute
+	qcFlag        = "qc"
+	qcFlagHelp    = "manual QC states of sample runs to include: pass, failed (pass and failed) " +
+		"or pending (pass and not yet QC'd)"
+	studyConflictsFlag     = "study-conflicts"
+	studyConflictsFlagHelp = "what to do with libraries that have samples in multiple studies: " +
+		"exclude them, split them in to one library per study, or allow them"
+	sponsorFlag     = "sponsor"
+	sponsorFlagHelp = "faculty sponsor of the studies to get samples from"
+	studyFlag       = "study"
+	studyFlagHelp   = "ID of a study to get samples from, instead of by sponsor (can be repeated)"
+
+	ErrStudiesAndSamples = Error("--study and --sample can't be used together")
+	ErrInvalidSheet      = Error("the spreadsheet has invalid cells")
+)
+
+// options for this cmd.
+var (
+	infoQC             string
+	infoStudyConflicts string
+	infoSponsor        string
+	infoStudies        []string
+	infoSampleNames    []string
+	infoValidate       bool
+	infoDiff           bool
+	infoSnapshot       string
+	infoOutputs        string
+	infoFormat         string
+	infoColumns        []string
 )
 
 // infoCmd represents the info command.
@@ -54,9 +85,62 @@ can extract for samples found in both.
 
 You can then use the sample names and run IDs from merged details as input to
 the run sub-commands.
+
+By default only sample runs that passed manual QC are shown. Use --qc failed to
+also see those that failed QC (they will be warned about), or --qc pending to
+also see those that have not been QC'd yet.
+
+Libraries with samples in more than one study are warned about, and by default
+excluded. Use --study-conflicts split to instead see them split in to one
+library per study, or --study-conflicts allow to see them as-is.
+
+By default the samples of studies sponsored by Ben Lehner are shown. Use
+--sponsor to pick a different faculty sponsor, --study (repeatedly) to see the
+samples of particular studies regardless of sponsor, or --sample (repeatedly) to
+see particular samples regardless of study.
+
+The libraries are output as a single JSON document by default. Use --format to
+choose between:
+json:  an array of libraries, with their experiments and samples nested inside
+jsonl: one library per line
+tsv:   a header line and then one line per sample run, with the details of its
+       library and experiment
+table: an aligned table of sample runs, followed by a summary
+
+Use --columns to choose which columns tsv and table output have, in what order.
+For json and jsonl, --columns makes them output objects with just those columns
+for each sample run, instead of whole libraries. The columns are: library_id,
+study_id, study_name, experiment_id, assay, sample_name, sample_id, run_id,
+manual_qc, lanes, tag_index, instrument_model, run_complete, read_length,
+pipeline_id_lims (the library type), selection, experiment_replicate,
+technical_replicate, selection_time and cell_density.
+
+You can filter the sample runs shown by their sequencing details (eg. --lane 1
+--instrument-model NovaSeqX --completed-after 2025-01-01), and sort the sample
+runs within each experiment by one of those details with --sort.
+
+You can also filter them by --library, --experiment, --study-name, --assay, a
+range of run IDs (--min-run and --max-run) and manual QC state (eg. --qc pending
+--qc-state pending to see only those not yet QC'd). To find sample runs to feed
+to the run sub-commands, use --has-fastqs to only see those whose fastqs are
+already in the given run irods-to-lustre output directory, and
+--has-dimsum-output to only see those with a completed run in the given run
+dimsum output directory.
+
+Use --validate to instead just check the Google sheet for invalid cell values,
+getting a list of every problem that needs to be fixed. (See the validate-sheet
+command for more thorough checks.)
+
+Use --diff to instead see which libraries, experiments and samples were added,
+removed or changed in the spreadsheet since you last used --diff, including
+which effective DiMSum parameters of experiments changed. A snapshot of the
+spreadsheet is kept in your cache directory (or at --snapshot) for this. With
+--outputs set to the -o directory you use for 'run dimsum', existing outputs of
+changed experiments are listed, saying whether new runs would get a different
+key or reuse those outputs.
 `,
-	Run: func(_ *cobra.Command, _ []string) {
-		err := sampleInfo()
+	Run: func(cmd *cobra.Command, _ []string) {
+		err := sampleInfo(cmd.Context())
 		if err != nil {
 			die(err)
 		}
@@ -65,63 +149,209 @@ the run sub-commands.
 
 func init() {
 	RootCmd.AddCommand(infoCmd)
+
+	infoCmd.Flags().StringVar(&infoQC, qcFlag, string(types.QCPolicyPassOnly), qcFlagHelp)
+	infoCmd.Flags().StringVar(&infoStudyConflicts, studyConflictsFlag, string(samples.StudyConflictExclude),
+		studyConflictsFlagHelp)
+	infoCmd.Flags().StringVar(&infoSponsor, sponsorFlag, sponsor, sponsorFlagHelp)
+	infoCmd.Flags().StringSliceVar(&infoStudies, studyFlag, nil, studyFlagHelp)
+	infoCmd.Flags().StringSliceVar(&infoSampleNames, "sample", nil,
+		"name of a sample to get, instead of by sponsor (can be repeated)")
+	infoCmd.Flags().BoolVar(&infoValidate, "validate", false,
+		"just check the spreadsheet, listing every invalid cell")
+	infoCmd.Flags().BoolVar(&infoDiff, "diff", false,
+		"just show what changed in the spreadsheet since the last --diff")
+	infoCmd.Flags().StringVar(&infoSnapshot, "snapshot", "",
+		"path to the snapshot file that --diff compares to and updates (defaults to one in your cache directory)")
+	infoCmd.Flags().StringVar(&infoOutputs, "outputs", "",
+		"with --diff, the -o directory of run dimsum, to list existing outputs of changed experiments")
+	infoCmd.Flags().StringVar(&infoFormat, "format", string(report.FormatJSON),
+		"output format: json, jsonl, tsv or table")
+	infoCmd.Flags().StringSliceVar(&infoColumns, "columns", nil,
+		"comma separated columns to output, in order (see help text for the choices)")
+	addFilterFlags(infoCmd)
 }
 
-func sampleInfo() error {
-	c, err := config.FromEnv()
+func sampleInfo(ctx context.Context) error {
+	opts, err := clientOptions(infoQC, infoStudyConflicts)
 	if err != nil {
 		return err
 	}
 
-	db, sheets, err := getDBAndSheets(c)
+	q, err := samplesQuery(infoSponsor, infoStudies, infoSampleNames)
 	if err != nil {
 		return err
 	}
 
-	cliPrint("Extracted library => experiement => sample info:\n")
+	w, err := report.NewWriter(infoFormat, infoColumns)
+	if err != nil {
+		return err
+	}
+
+	req := config.RequireAll
+	if infoValidate || infoDiff {
+		req = config.RequireSheet
+	}
 
-	libs, err := sponsorLibs(c, db, sheets)
+	c, err := loadConfig(req)
 	if err != nil {
 		return err
 	}
 
-	for _, lib := range libs {
-		bytes, _ := json.MarshalIndent(lib, "", "  ") //nolint:errcheck,errchkjson
-		cliPrint(string(bytes))
+	if infoValidate {
+		return validateSheet(c)
 	}
 
-	cliPrint("\n")
+	if infoDiff {
+		return diffSheet(c, infoSnapshot, infoOutputs)
+	}
+
+	db, sheets, err := getDBAndSheets(ctx, c)
+	if err != nil {
+		return err
+	}
+
+	infof("extracting library => experiment => sample info (QC policy: %s)", opts.QCPolicy)
+
+	libs, err := queryLibs(ctx, c, db, sheets, opts, q)
+	if err != nil {
+		return err
+	}
+
+	libs, err = filterAndSortLibs(libs)
+	if err != nil {
+		return err
+	}
+
+	warnAboutQC(libs)
+
+	var buf bytes.Buffer
+
+	if err = w.Write(&buf, libs); err != nil {
+		return err
+	}
 
-	return nil
+	_, err = buf.WriteTo(os.Stdout)
+
+	return err
 }
 
-func getDBAndSheets(c *config.Config) (*mlwh.MLWH, *sheets.Sheets, error) {
-	db, err := mlwh.New(mlwh.MySQLConfigFromConfig(c))
+// validateSheet reads the metadata in the configured spreadsheet and prints a
+// list of any invalid cells, returning ErrInvalidSheet if there were any.
+func validateSheet(c *config.Config) error {
+	s, err := sheets.FromConfig(c)
 	if err != nil {
-		return nil, nil, err
+		return err
 	}
 
-	sc, err := sheets.ServiceCredentialsFromConfig(c)
+	_, err = s.DimSumMetaData(c.SheetID)
+
+	return reportCellErrors(err)
+}
+
+func getDBAndSheets(ctx context.Context, c *config.Config) (*mlwh.MLWH, sheets.MetaDataReader, error) {
+	db, err := mlwh.FromConfig(ctx, c)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	s, err := sheets.New(sc)
+	s, err := sheets.FromConfig(c)
 	if err != nil {
+		db.Close()
+
 		return nil, nil, err
 	}
 
 	return db, s, err
 }
 
-func sponsorLibs(c *config.Config, db *mlwh.MLWH, s *sheets.Sheets) (types.Libraries, error) {
-	client := samples.New(db, s, samples.ClientOptions{
-		SheetID:       c.SheetID,
-		CacheLifetime: cacheLifetime,
-		Prefetch:      []string{sponsor},
-	})
+// clientOptions returns samples.ClientOptions with the QCPolicy and
+// StudyConflictPolicy corresponding to the given strings.
+func clientOptions(qc, studyConflicts string) (samples.ClientOptions, error) {
+	qcPolicy, err := types.StringToQCPolicy(qc)
+	if err != nil {
+		return samples.ClientOptions{}, err
+	}
+
+	studyPolicy, err := samples.StringToStudyConflictPolicy(studyConflicts)
+	if err != nil {
+		return samples.ClientOptions{}, err
+	}
+
+	return samples.ClientOptions{
+		QCPolicy:            qcPolicy,
+		StudyConflictPolicy: studyPolicy,
+	}, nil
+}
+
+// samplesQuery returns a samples.Query for the given studies if any, otherwise
+// the given sample names if any, otherwise the given sponsor.
+func samplesQuery(sponsor string, studyIDs, sampleNames []string) (samples.Query, error) {
+	switch {
+	case len(studyIDs) > 0 && len(sampleNames) > 0:
+		return samples.Query{}, ErrStudiesAndSamples
+	case len(studyIDs) > 0:
+		return samples.StudiesQuery(studyIDs), nil
+	case len(sampleNames) > 0:
+		return samples.SampleNamesQuery(sampleNames), nil
+	default:
+		return samples.SponsorQuery(sponsor), nil
+	}
+}
+
+// queryLibs returns the libraries for the given query, using the given options
+// for the QC and study conflict policies. Libraries with samples in multiple
+// studies are warned about.
+func queryLibs(ctx context.Context, c *config.Config, db *mlwh.MLWH, s sheets.MetaDataReader,
+	opts samples.ClientOptions, q samples.Query) (types.Libraries, error) {
+	opts.SheetID = c.SheetID
+	opts.CacheLifetime = cacheLifetime
+
+	client := samples.New(ctx, db, s, opts)
 
 	defer client.Close()
 
-	return client.ForSponsor(sponsor)
+	libs, err := client.Libraries(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	conflicts, err := client.StudyConflicts(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, conflict := range conflicts {
+		warnf("%s", conflict)
+	}
+
+	return libs, nil
+}
+
+// warnAboutQC logs a warning for every sample run in the given libraries that
+// did not pass manual QC, returning the number of such sample runs.
+func warnAboutQC(libs types.Libraries) int {
+	n := 0
+
+	for _, lib := range libs {
+		for _, exp := range lib.Experiments {
+			for _, s := range exp.Samples {
+				if s.QCPassed() {
+					continue
+				}
+
+				n++
+
+				state := "failed"
+				if s.QCPending() {
+					state = "is pending"
+				}
+
+				warnf("manual QC %s for sample %s run %s (experiment %s)",
+					state, s.SampleName, s.RunID, exp.ExperimentID)
+			}
+		}
+	}
+
+	return n
 }
@@ -0,0 +1,179 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Authors:
+ *	- Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/wtsi-hgi/dimsum-automation/itl"
+	"github.com/wtsi-hgi/dimsum-automation/types"
+)
+
+const dryRunFlagHelp = "do all lookups and validation, and generate working files, but only print a JSON " +
+	"plan of what would be run and where files would land, without running anything or creating output directories"
+
+// itlPlan describes what 'run irods-to-lustre' would do.
+type itlPlan struct {
+	OutputDir         string          `json:"output_dir"`
+	SamplesTSVCommand string          `json:"samples_tsv_command,omitempty"`
+	SamplesTSV        string          `json:"samples_tsv,omitempty"`
+	SamplesTSVExists  bool            `json:"samples_tsv_exists"`
+	ExistingFastqs    []string        `json:"existing_fastqs"`
+	Samples           []itlSamplePlan `json:"samples"`
+}
+
+// itlSamplePlan describes how the fastqs of a sample run would be retrieved.
+type itlSamplePlan struct {
+	SampleRun string   `json:"sample_run"`
+	TSV       string   `json:"tsv"`
+	Command   string   `json:"command"`
+	Fastqs    []string `json:"fastqs"`
+}
+
+// newITLPlan returns a plan for the given ITL of the given libraries. If the
+// samples TSV file already exists in the working directory, it is filtered in
+// to per-sample run TSV files as a real run would.
+func newITLPlan(i *itl.ITL, libs types.Libraries) *itlPlan {
+	p := &itlPlan{OutputDir: itlOutput, ExistingFastqs: []string{}, Samples: []itlSamplePlan{}}
+
+	for _, lib := range libs {
+		for _, exp := range lib.Experiments {
+			for _, s := range exp.Samples {
+				if itl.HasFastqs(s, itlOutput) {
+					p.ExistingFastqs = append(p.ExistingFastqs, s.SampleName+":"+s.RunID)
+				}
+			}
+		}
+	}
+
+	if len(i.Samples()) == 0 {
+		return p
+	}
+
+	p.SamplesTSVCommand, p.SamplesTSV = i.GenerateSamplesTSVCommand()
+	fcs := i.FastqCreators()
+
+	if _, err := os.Stat(p.SamplesTSV); err == nil {
+		p.SamplesTSVExists = true
+
+		filtered, errf := i.FilterSamplesTSV(p.SamplesTSV)
+		if errf == nil {
+			fcs = filtered
+		} else {
+			warnf("could not filter existing samples TSV file: %s", errf)
+		}
+	}
+
+	for _, fc := range fcs {
+		p.Samples = append(p.Samples, itlSamplePlan{
+			SampleRun: fc.IDRun(),
+			TSV:       fc.TSVPath(),
+			Command:   fc.Command(),
+			Fastqs:    fc.FastqPaths(),
+		})
+	}
+
+	return p
+}
+
+// dimsumPlan describes what 'run dimsum' would do.
+type dimsumPlan struct {
+	OutputDir string          `json:"output_dir"`
+	Runs      []dimsumRunPlan `json:"runs"`
+}
+
+// dimsumRunPlan describes a DiMSum run that would be done.
+type dimsumRunPlan struct {
+	ExperimentID string            `json:"experiment_id"`
+	Samples      []string          `json:"samples"`
+	Sweep        map[string]string `json:"sweep,omitempty"`
+	Skip         bool              `json:"skip_existing_outputs,omitempty"`
+	DesignFile   string            `json:"design_file,omitempty"`
+	Command      string            `json:"command,omitempty"`
+	WorkDir      string            `json:"work_dir,omitempty"`
+	OutputDir    string            `json:"output_dir"`
+}
+
+// newDimsumPlan returns a plan for the given runs, writing their experiment
+// design files to the working directory.
+func newDimsumPlan(runs []*dimsumRun) (*dimsumPlan, error) {
+	p := &dimsumPlan{OutputDir: dimsumOutput, Runs: make([]dimsumRunPlan, len(runs))}
+
+	for i, r := range runs {
+		rp, err := r.plan()
+		if err != nil {
+			return nil, err
+		}
+
+		p.Runs[i] = rp
+	}
+
+	return p, nil
+}
+
+// plan writes our experiment design file and describes what run() would do.
+func (r *dimsumRun) plan() (dimsumRunPlan, error) {
+	rp := dimsumRunPlan{
+		ExperimentID: r.exp.ExperimentID,
+		Samples:      make([]string, len(r.exp.Samples)),
+		Sweep:        r.sweep.Settings(r.params),
+		Skip:         r.skipped,
+		OutputDir:    r.outputDir,
+	}
+
+	for i, s := range r.exp.Samples {
+		rp.Samples[i] = s.SampleName + ":" + s.RunID
+	}
+
+	if r.skipped {
+		return rp, nil
+	}
+
+	var err error
+
+	rp.DesignFile, err = r.design.Write(".")
+	if err != nil {
+		return rp, err
+	}
+
+	rp.Command = r.d.CommandLine()
+	rp.WorkDir = r.d.ProjectDir()
+
+	return rp, nil
+}
+
+// printPlan prints the given plan as JSON to STDOUT.
+func printPlan(plan interface{}) {
+	b, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		die(err)
+	}
+
+	cliPrint(string(b) + "\n")
+	info("dry run: nothing was run")
+}
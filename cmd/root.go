@@ -29,11 +29,15 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/inconshreveable/log15"
 	"github.com/spf13/cobra"
+	"github.com/wtsi-hgi/dimsum-automation/config"
 )
 
 type Error string
@@ -43,6 +47,12 @@ func (e Error) Error() string { return string(e) }
 // appLogger is used for logging events in our commands.
 var appLogger = log15.New()
 
+// options for all commands.
+var (
+	rootConfigFile string
+	rootProfile    string
+)
+
 // RootCmd represents the base command when called without any subcommands.
 var RootCmd = &cobra.Command{
 	Use:   "dimsum-automation",
@@ -56,21 +66,81 @@ samples.
 For now, you can run the "info" sub-command to see some sample info, then
 pass desired samples to the "run" sub-commands to get the fastqs and then run
 dimsum on them.
+
+Settings (such as the MLWH connection details and the spreadsheet ID) come from
+DIMSUM_AUTOMATION_* environment variables, which may be defined in a .env file
+in the current directory. They can also be given in a YAML config file of named
+profiles, eg.:
+
+default_profile: prod
+profiles:
+  prod:
+    credentials_file: /path/to/credentials.json
+    spreadsheet_id: 1ksldfj3lsadfj
+    sql_host: mlwh-db
+    sql_port: 3306
+    sql_user: user
+    sql_pass: pass
+    sql_db: mlwarehouse
+  offline:
+    sqlite_db: /path/to/mlwh.db
+    spreadsheet_file: /path/to/export.xlsx
+
+Keys are the environment variable names without the DIMSUM_AUTOMATION_ prefix,
+in lower case. The file is given with --config (or $DIMSUM_AUTOMATION_CONFIG),
+defaulting to dimsum-automation/config.yml in your config directory (eg.
+~/.config) if it exists. The profile is chosen with --profile (or
+$DIMSUM_AUTOMATION_PROFILE), defaulting to default_profile, or the only profile.
+Environment variables override the profile's settings.
 `,
 }
 
 // Execute adds all child commands to the root command and sets flags
 // appropriately. This is called by main.main(). It only needs to happen once to
 // the rootCmd.
+//
+// Commands are given a context that is cancelled if we receive SIGINT or
+// SIGTERM, so that in-progress queries are abandoned.
 func Execute() {
-	if err := RootCmd.Execute(); err != nil {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := RootCmd.ExecuteContext(ctx); err != nil {
 		die(err)
 	}
 }
 
 func init() {
 	// set up logging to stderr
-	appLogger.SetHandler(log15.LvlFilterHandler(log15.LvlInfo, log15.StderrHandler))
+	appLogger.SetHandler(logHandler())
+
+	RootCmd.PersistentFlags().StringVar(&rootConfigFile, "config", "",
+		"path to a YAML config file of settings profiles")
+	RootCmd.PersistentFlags().StringVar(&rootProfile, "profile", "",
+		"name of the profile in the config file to use")
+}
+
+// loadConfig returns our settings from the environment and any config file and
+// profile chosen with --config and --profile. An error listing the missing
+// settings is returned if any needed for the given requirement are not set.
+func loadConfig(req config.Requirement) (*config.Config, error) {
+	return config.Load(config.Options{File: rootConfigFile, Profile: rootProfile, Require: req})
+}
+
+// logHandler returns our stderr log handler, which redacts the values of any
+// context keys that name secrets (see config.IsSecretKey).
+func logHandler() log15.Handler {
+	h := log15.LvlFilterHandler(log15.LvlInfo, log15.StderrHandler)
+
+	return log15.FuncHandler(func(r *log15.Record) error {
+		for i := 0; i+1 < len(r.Ctx); i += 2 {
+			if key, ok := r.Ctx[i].(string); ok && config.IsSecretKey(key) {
+				r.Ctx[i+1] = config.Redacted
+			}
+		}
+
+		return h.Log(r)
+	})
 }
 
 // cliPrint outputs the message to STDOUT.
@@ -96,6 +166,12 @@ func dief(msg string, a ...interface{}) {
 	os.Exit(1)
 }
 
+// warnf is a convenience to log a warning message, with printf formatting
+// args, at the Warn level.
+func warnf(msg string, a ...interface{}) {
+	appLogger.Warn(fmt.Sprintf(msg, a...))
+}
+
 // info is a convenience to log a message at the Info level.
 func info(msg string) {
 	appLogger.Info(msg)
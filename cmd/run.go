@@ -26,28 +26,51 @@
 package cmd
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/wtsi-hgi/dimsum-automation/config"
 	"github.com/wtsi-hgi/dimsum-automation/dimsum"
 	"github.com/wtsi-hgi/dimsum-automation/itl"
+	"github.com/wtsi-hgi/dimsum-automation/samples"
+	"github.com/wtsi-hgi/dimsum-automation/sheets"
 	"github.com/wtsi-hgi/dimsum-automation/types"
 )
 
 const (
-	ErrBadOutputDir    = Error("output directory must not be a sub-directory of the current working directory")
-	ErrSamplesRequired = Error("at least one sampleName:runID pair is required")
+	ErrBadOutputDir         = Error("output directory must not be a sub-directory of the current working directory")
+	ErrSamplesRequired      = Error("at least one sampleName:runID pair (or --samples-file or --experiment) is required")
+	ErrOutputDirNotEmpty    = Error("unique dimsum output directory already exists and is not empty")
+	ErrSweepShareNeedsSweep = Error("--sweep-share-intermediates requires --sweep")
+	ErrQCNotPassed          = Error("some desired sample runs did not pass manual QC; " +
+		"supply --allow-qc-failures if you really want to use them")
 
 	dirPerm    = 0755
 	outputFlag = "output"
+
+	sweepStateExisting   = "existing"
+	sweepIndexPrefix     = "sweep_"
+	sweepIndexSuffix     = ".tsv"
+	sweepIndexTimeFormat = "20060102-150405.000000"
 )
 
 // options for this cmd.
 var (
+	runQC                         string
+	runStudyConflicts             string
+	runSponsor                    string
+	runStudies                    []string
+	runAllowQCFailures            bool
+	runUpdateSheet                bool
+	runDryRun                     bool
 	itlOutput                     string
 	dimsumOutput                  string
 	dimsumFastqDir                string
@@ -61,6 +84,8 @@ var (
 	dimsumMixedSubstitutions      bool
 	dimsumMutagenesisType         string
 	dimsumDesignPairDuplicates    bool
+	dimsumSweep                   []string
+	dimsumSweepShare              bool
 )
 
 // runCmd represents the run command.
@@ -73,6 +98,45 @@ The various run sub-commands run the various steps of the workflow on the local
 machine in the current working directory.
 
 It is intended that these will be automatically run in a workflow using wr.
+
+Only sample runs that passed manual QC can be used by default. To use sample
+runs that failed QC or have not been QC'd yet, you must supply --qc failed or
+--qc pending, along with --allow-qc-failures.
+
+Libraries with samples in more than one study can't be used by default. Use
+--study-conflicts split to use just the samples of a single study, or
+--study-conflicts allow to use samples from multiple studies together.
+
+Desired samples are looked for amongst the studies sponsored by Ben Lehner by
+default. Use --sponsor to pick a different faculty sponsor, or --study
+(repeatedly) to look in particular studies regardless of sponsor.
+
+Samples are normally supplied as a series of sampleName:runID pairs. Instead,
+you can supply --samples-file with the path to a TSV file of sample names in
+the first column and run IDs in the second (with an optional sample_name,
+run_id header line), so that you can keep reproducible batch definitions. Or
+supply --experiment with an experiment ID to use all the sample runs of that
+experiment (that are acceptable under --qc), optionally limited to those with
+particular --runs run IDs, and/or excluding particular samples with --exclude
+(sampleName or sampleName:runID).
+
+The desired samples must normally all be in the same experiment. With --batch,
+they can span experiments (and --experiment can be repeated): they are grouped
+by experiment, and each group is validated separately. irods-to-lustre then
+retrieves the fastqs of all of them in one go, while dimsum does a separate
+DiMSum run for each experiment, continuing with the others if one fails, and
+then prints a summary of them all.
+
+With --dry-run, all the lookups and validation are done, and working files (like
+DiMSum experiment design files) are generated in the current working directory,
+but instead of running anything, a JSON plan is printed describing the commands
+that would be run and where files would land. No output directories are created
+and the Google sheet is not updated.
+
+With --update-sheet, the progress of the run (fastq retrieval and DiMSum states,
+output directories, key hash and timestamps) is recorded in a row of the "runs"
+tab of the Google sheet, so you can follow it there. The tab must already exist
+(it can be empty), and the service account must have edit access to the sheet.
 `,
 }
 
@@ -87,12 +151,15 @@ calling this command.
 
 Given desired samples, crams will be downloaded from iRODS, merged as necessary
 and FASTQ files created. The samples must be from the same study, otherwise an
-error will be raised. You must also specify an output directory with the -o
+error will be raised, unless you supply --study-conflicts allow. You must also specify an output directory with the -o
 option, which will be created if it doesn't exist.
 
-Samples should be supplied as a series of sampleName:runID pairs. An example
-command line could look like this:
+Samples should be supplied as a series of sampleName:runID pairs, or with
+--samples-file or --experiment (see the help for 'run'). Example command lines
+could look like this:
 $ dimsum-automation run irods_to_lustre -o /output/dir AMA1:1234 AMA2:5678
+$ dimsum-automation run irods_to_lustre -o /output/dir --experiment exp1 \
+    --exclude AMA3
 
 Note that the current working directory will be used for various working files
 and it is expected that you delete this directory afterwards, ie. that you run
@@ -102,37 +169,62 @@ directory of the current working directory, or the working directory itself.
 If output files already exist in the output directory for a sample, the process
 will be skipped for that sample.
 `,
-	Run: func(_ *cobra.Command, nameRunStrs []string) {
-		desired := subsetDesiredSamples(nameRunStrs)
+	Run: func(command *cobra.Command, nameRunStrs []string) {
+		desired, c := subsetDesiredSamples(command.Context(), nameRunStrs)
+
+		status := newRunStatus(c, desired)
+		status.base.FastqDir = itlOutput
+		fail := func(err error) {
+			status.fastq(sheets.RunStateFailed, err)
+			die(err)
+		}
 
 		err := validateOutputDir(itlOutput)
 		if err != nil {
-			die(err)
+			fail(err)
 		}
 
-		itl, err := itl.New(desired, itlOutput)
+		newITL := itl.NewBatch
+		if runStudyConflicts == string(samples.StudyConflictAllow) {
+			newITL = itl.NewMultiStudyBatch
+		}
+
+		itl, err := newITL(desired, itlOutput)
 		if err != nil {
-			die(err)
+			fail(err)
+		}
+
+		if runAllowQCFailures {
+			itl.AllowQCFailures()
+		}
+
+		if runDryRun {
+			printPlan(newITLPlan(itl, desired))
+
+			return
 		}
 
 		if len(itl.Samples()) == 0 {
 			info("fastqs for these samples already exist in the output directory")
+			status.fastq(sheets.RunStateComplete, nil)
 
 			return
 		}
 
+		status.fastq(sheets.RunStateRunning, nil)
+
 		cmd, tsvPath := itl.GenerateSamplesTSVCommand()
 
 		infof("running command to generate samples TSV file:\n%s", cmd)
 
 		err = executeCmd(cmd)
 		if err != nil {
-			die(err)
+			fail(err)
 		}
 
 		fcs, err := itl.FilterSamplesTSV(tsvPath)
 		if err != nil {
-			die(err)
+			fail(err)
 		}
 
 		for _, fc := range fcs {
@@ -142,16 +234,17 @@ will be skipped for that sample.
 
 			err = executeCmd(cmd)
 			if err != nil {
-				die(err)
+				fail(err)
 			}
 
 			err = fc.MoveFastqFiles()
 			if err != nil {
-				die(err)
+				fail(err)
 			}
 		}
 
 		infof("fastq files for %d samples downloaded to %s", len(fcs), itlOutput)
+		status.fastq(sheets.RunStateComplete, nil)
 	},
 }
 
@@ -170,6 +263,10 @@ func validateOutputDir(outputDir string) error {
 		return ErrBadOutputDir
 	}
 
+	if runDryRun {
+		return nil
+	}
+
 	if _, err := os.Stat(outputDir); err != nil {
 		err = createDirIfNotExist(outputDir, err)
 		if err != nil {
@@ -188,59 +285,54 @@ func createDirIfNotExist(dir string, statErr error) error {
 	return os.MkdirAll(dir, dirPerm)
 }
 
-func subsetDesiredSamples(nameRunStrs []string) *types.Library {
-	nameRuns := nameRunStrsToNameRuns(nameRunStrs)
-
-	c, err := config.FromEnv()
+// subsetDesiredSamples returns a library for each experiment of the samples the
+// user selected (only one unless --batch was supplied), along with the config
+// used to find them.
+func subsetDesiredSamples(ctx context.Context, nameRunStrs []string) (types.Libraries, *config.Config) {
+	sel, err := newSampleSelection(nameRunStrs)
 	if err != nil {
 		die(err)
 	}
 
-	db, s, err := getDBAndSheets(c)
+	opts, err := clientOptions(runQC, runStudyConflicts)
 	if err != nil {
 		die(err)
 	}
 
-	libs, err := sponsorLibs(c, db, s)
+	c, err := loadConfig(config.RequireAll)
 	if err != nil {
 		die(err)
 	}
 
-	filtered, err := libs.Subset(nameRuns)
+	db, s, err := getDBAndSheets(ctx, c)
 	if err != nil {
 		die(err)
 	}
 
-	return filtered
-}
-
-func nameRunStrsToNameRuns(nameRunStrs []string) []*types.Sample {
-	result := make([]*types.Sample, 0, len(nameRunStrs))
-	done := make(map[string]bool)
-
-	for _, nameRunStr := range nameRunStrs {
-		if done[nameRunStr] {
-			continue
-		}
+	q, err := samplesQuery(runSponsor, runStudies, nil)
+	if err != nil {
+		die(err)
+	}
 
-		parts := strings.Split(nameRunStr, ":")
-		if len(parts) != 2 {
-			dief("invalid sampleName:runID pair: %s", nameRunStr)
-		}
+	libs, err := queryLibs(ctx, c, db, s, opts, q)
+	if err != nil {
+		die(err)
+	}
 
-		result = append(result, &types.Sample{
-			SampleName: parts[0],
-			RunID:      parts[1],
-		})
+	filtered, err := sel.subset(libs)
+	if err != nil {
+		die(err)
+	}
 
-		done[nameRunStr] = true
+	for _, lib := range filtered {
+		infof("selected sample runs of experiment %s: %s", lib.Experiments[0].ExperimentID, describeNameRuns(lib))
 	}
 
-	if len(result) == 0 {
-		die(ErrSamplesRequired)
+	if warnAboutQC(filtered) > 0 && !runAllowQCFailures {
+		die(ErrQCNotPassed)
 	}
 
-	return result
+	return filtered, c
 }
 
 func executeCmd(cmd string) error {
@@ -271,95 +363,444 @@ library metadata, otherwise an error will be raised.
 
 You must also specify an output directory with the -o option, which will be
 created if it doesn't exist. In this output directory, a unique sub-directory
-will be created corresponding to your choice of samples and dimsum options. If
-that unique sub-directory already exists and has files in it, an error will be
+will be created corresponding to your choice of samples and dimsum options,
+and DiMSum's output files are moved in to it after a successful run. If that
+unique sub-directory already exists and has files in it, an error will be
 raised.
 
-Samples should be supplied as a series of sampleName:runID pairs. All other
-options should be supplied before these. An example command line could look like
-this:
+Samples should be supplied as a series of sampleName:runID pairs, or with
+--samples-file or --experiment (see the help for 'run'). All other options
+should be supplied before any pairs. Example command lines could look like this:
 $ dimsum-automation run dimsum -o /output/dir -f /fastqs/dir \
     --barcodeIdentityPath /path/to/barcode AMA1:1234 AMA2:5678
+$ dimsum-automation run dimsum -o /output/dir -f /fastqs/dir \
+    --barcodeIdentityPath /path/to/barcode --samples-file batch1.tsv
+
+To try out several values of some DiMSum options, supply --sweep (repeatedly)
+with an option name and a comma separated list of values, eg.
+--sweep vsearchMinQual=20,30 --sweep maxSubstitutions=2,3. A DiMSum run is then
+done for every combination of the values (their cartesian product), each with
+its own unique sub-directory of -o. Combinations whose unique sub-directory
+already has files in it are skipped, instead of raising an error. The options
+that can be swept are: cutAdaptErrorRate, cutAdaptMinLength,
+fitnessMinInputCountAll, fitnessMinInputCountAny, maxSubstitutions,
+mutagenesisType and vsearchMinQual.
+
+With --sweep-share-intermediates, the combinations are run so that those with
+the same cutAdapt* values reuse the outputs of DiMSum stages 1-3 of the first of
+them (starting the rest at stage 4), saving time. After a sweep, a sweep index
+TSV file with columns for each swept option, the unique output directory and
+the state of that combination (complete, failed or existing) is written to the
+experiment's sub-directory of -o, and its path printed.
 
 Note that the current working directory will be used for various working files
 and it is expected that you delete this directory afterwards, ie. that you run
 this command via wr without --cwd_matters. -o must therefore not be a sub
 directory of the current working directory, or the working directory itself.
 `,
-	Run: func(_ *cobra.Command, nameRunStrs []string) {
-		lib := subsetDesiredSamples(nameRunStrs)
-
-		design, err := dimsum.NewExperimentDesign(lib.Experiments[0])
+	Run: func(command *cobra.Command, nameRunStrs []string) {
+		params, err := dimsumSweepParams()
 		if err != nil {
 			die(err)
 		}
 
-		d := dimsum.New(dimsumFastqDir, design)
+		libs, c := subsetDesiredSamples(command.Context(), nameRunStrs)
 
-		d.VSearchMinQual = dimsumVsearchMinQual
-		d.StartStage = dimsumStartStage
-		d.FitnessMinInputCountAny = dimsumFitnessMinInputCountAny
-		d.FitnessMinInputCountAll = dimsumFitnessMinInputCountAll
-		d.CutAdaptMinLength = dimsumCutAdaptMinLength
-		d.CutAdaptErrorRate = dimsumCutAdaptErrorRate
-		d.MixedSubstitutions = dimsumMixedSubstitutions
-		d.MutagenesisType = dimsumMutagenesisType
-		d.DesignPairDuplicates = dimsumDesignPairDuplicates
+		if err := validateOutputDir(dimsumOutput); err != nil {
+			die(err)
+		}
 
-		err = validateOutputDir(dimsumOutput)
+		runs, err := newDimsumRuns(c, libs, params)
 		if err != nil {
 			die(err)
 		}
 
-		uniqueDimsumOutputDir := dimsumUniqueOutputDir(d, dimsumOutput, lib.Experiments[0].Samples)
+		if runDryRun {
+			plan, errp := newDimsumPlan(runs)
+			if errp != nil {
+				die(errp)
+			}
 
-		dir := "."
+			printPlan(plan)
 
-		experimentPath, err := design.Write(dir)
-		if err != nil {
-			die(err)
+			return
 		}
 
-		infof("created experiment design file: %s", experimentPath)
+		for _, r := range runs {
+			r.run()
+		}
+
+		if len(params) > 0 {
+			writeSweepIndexes(params, runs)
+		}
 
-		cmd, err := d.Command()
+		if len(runs) == 1 && len(params) == 0 {
+			if runs[0].err != nil {
+				die(runs[0].err)
+			}
+
+			return
+		}
+
+		if failed := reportDimsumRuns(runs); failed > 0 {
+			dief("%d of %d dimsum runs failed", failed, len(runs))
+		}
+	},
+}
+
+// dimsumRun is a DiMSum run of the samples of one experiment.
+type dimsumRun struct {
+	exp       *types.Experiment
+	design    dimsum.ExperimentDesign
+	d         dimsum.DimSum
+	outputDir string
+	status    *runStatus
+	sweep     *dimsum.SweepRun
+	params    []dimsum.SweepParam
+	skipped   bool
+	keep      bool
+	err       error
+}
+
+// newDimsumRuns returns the dimsumRuns for each of the given libraries, each
+// having a single experiment: one per combination of the given sweep
+// parameters, or just one if there are none. Every library is validated and
+// has its unique output directories created before any are run; if there are
+// problems with any, an error describing all of them is returned.
+func newDimsumRuns(c *config.Config, libs types.Libraries, params []dimsum.SweepParam) ([]*dimsumRun, error) {
+	runs := make([]*dimsumRun, 0, len(libs))
+
+	var errs []error
+
+	for _, lib := range libs {
+		rs, err := newExperimentDimsumRuns(c, lib, params)
 		if err != nil {
-			die(err)
+			if len(libs) > 1 {
+				err = fmt.Errorf("experiment %s: %w", lib.Experiments[0].ExperimentID, err)
+			}
+
+			errs = append(errs, err)
+
+			continue
 		}
 
-		infof("will run dimsum:\n%s", cmd)
+		runs = append(runs, rs...)
+	}
 
-		err = executeCmd(cmd)
+	return runs, errors.Join(errs...)
+}
+
+// newExperimentDimsumRuns returns a dimsumRun for each combination of the given
+// sweep parameters for the given library's experiment, in the order they must
+// be run. When sweeping, combinations with existing outputs are marked as
+// skipped, and come first, and combinations with the same output directory as
+// an earlier one are left out.
+func newExperimentDimsumRuns(
+	c *config.Config, lib *types.Library, params []dimsum.SweepParam,
+) ([]*dimsumRun, error) {
+	exp := lib.Experiments[0]
+	status := newRunStatus(c, types.Libraries{lib})
+	status.base.FastqDir = dimsumFastqDir
+
+	design, err := dimsum.NewExperimentDesign(exp)
+	if err != nil {
+		status.dimsum(sheets.RunStateFailed, err)
+
+		return nil, err
+	}
+
+	d, err := newDimSum(design)
+	if err != nil {
+		return nil, err
+	}
+
+	sweep, err := d.Sweep(params)
+	if err != nil {
+		return nil, err
+	}
+
+	sweep, duplicates := dimsum.DistinctRuns(sweep, exp.Samples)
+
+	for _, s := range duplicates {
+		warnf("skipping sweep combination [%s] of experiment %s: it has the same output directory as another",
+			strings.Join(s.Values, ","), exp.ExperimentID)
+	}
+
+	runs := make([]*dimsumRun, 0, len(sweep))
+	toRun := make([]*dimsum.SweepRun, 0, len(sweep))
+	sweepRuns := make(map[*dimsum.SweepRun]*dimsumRun, len(sweep))
+
+	for _, s := range sweep {
+		r, errr := newDimsumRun(status, exp, design, s, len(params) > 0)
+		if errr != nil {
+			return nil, errr
+		}
+
+		r.params = params
+
+		if r.skipped {
+			runs = append(runs, r)
+
+			continue
+		}
+
+		toRun = append(toRun, s)
+		sweepRuns[s] = r
+	}
+
+	if dimsumSweepShare {
+		toRun = dimsum.ShareIntermediates(params, toRun)
+	}
+
+	for i, s := range toRun {
+		r := sweepRuns[s]
+		r.d = s.DimSum
+		r.keep = dimsumSweepShare && i+1 < len(toRun) && toRun[i+1].DimSum.StartStage >= dimsum.SharedStartStage
+		runs = append(runs, r)
+	}
+
+	return runs, nil
+}
+
+// newDimsumRun returns a dimsumRun of the given sweep combination, creating its
+// unique output directory. If sweeping, a combination that already has outputs
+// is skipped instead of being an error.
+func newDimsumRun(expStatus *runStatus, exp *types.Experiment, design dimsum.ExperimentDesign,
+	s *dimsum.SweepRun, sweeping bool) (*dimsumRun, error) {
+	status := *expStatus
+	r := &dimsumRun{exp: exp, design: design, status: &status, sweep: s}
+
+	var err error
+
+	r.outputDir, err = dimsumUniqueOutputDir(s.DimSum, dimsumOutput, exp.Samples)
+
+	switch {
+	case sweeping && errors.Is(err, ErrOutputDirNotEmpty):
+		r.outputDir = filepath.Join(dimsumOutput, s.DimSum.Key(exp.Samples))
+		r.skipped = true
+		s.State = sweepStateExisting
+	case err != nil:
+		r.status.dimsum(sheets.RunStateFailed, err)
+
+		return nil, err
+	}
+
+	s.OutputDir = r.outputDir
+	r.status.base.OutputDir = r.outputDir
+	r.status.base.KeyHash = filepath.Base(r.outputDir)
+
+	return r, nil
+}
+
+// newDimSum returns a DimSum for the given design, with options set from our
+// command line flags.
+func newDimSum(design dimsum.ExperimentDesign) (dimsum.DimSum, error) {
+	d := dimsum.New(dimsumFastqDir, design)
+
+	mt, err := types.StringToMutagenesisType(dimsumMutagenesisType)
+	if err != nil {
+		return d, fmt.Errorf("%w: --mutagenesisType %q", err, dimsumMutagenesisType)
+	}
+
+	d.VSearchMinQual = dimsumVsearchMinQual
+	d.StartStage = dimsumStartStage
+	d.FitnessMinInputCountAny = dimsumFitnessMinInputCountAny
+	d.FitnessMinInputCountAll = dimsumFitnessMinInputCountAll
+	d.CutAdaptMinLength = dimsumCutAdaptMinLength
+	d.CutAdaptErrorRate = dimsumCutAdaptErrorRate
+	d.MixedSubstitutions = dimsumMixedSubstitutions
+	d.MutagenesisType = string(mt)
+	d.DesignPairDuplicates = dimsumDesignPairDuplicates
+	d.BarcodeIdentityPath = dimsumBarcodeIdentityPath
+
+	return d, nil
+}
+
+// run writes the experiment design file, runs DiMSum and moves its outputs to
+// our outputDir (copying them instead if the next run shares them), recording
+// any error. Does nothing if the run was skipped.
+func (r *dimsumRun) run() {
+	if r.skipped {
+		infof("skipping dimsum run with existing outputs in %s", r.outputDir)
+
+		return
+	}
+
+	started := time.Now()
+
+	defer r.writeManifest(started)
+
+	r.err = r.execute()
+	if r.err == nil {
+		r.err = r.d.CollectOutputs(r.outputDir, r.keep)
+	}
+
+	if r.err != nil {
+		r.status.dimsum(sheets.RunStateFailed, r.err)
+		r.sweep.State = sheets.RunStateFailed
+
+		return
+	}
+
+	infof("dimsum outputs are in %s", r.outputDir)
+	r.status.dimsum(sheets.RunStateComplete, nil)
+	r.sweep.State = sheets.RunStateComplete
+}
+
+// writeManifest records this finished run in a manifest in our output
+// directory, for the run history shown by 'serve'.
+func (r *dimsumRun) writeManifest(started time.Time) {
+	m := &dimsum.Manifest{
+		ExperimentID: r.exp.ExperimentID,
+		Samples:      make([]string, len(r.exp.Samples)),
+		FastqDir:     dimsumFastqDir,
+		OutputDir:    r.outputDir,
+		Command:      r.d.CommandLine(),
+		State:        r.sweep.State,
+		Sweep:        r.sweep.Settings(r.params),
+		Error:        errMsg(r.err),
+		Started:      started,
+		Finished:     time.Now(),
+	}
+
+	for i, s := range r.exp.Samples {
+		m.Samples[i] = s.SampleName + ":" + s.RunID
+	}
+
+	if _, err := dimsum.WriteManifest(dimsumOutput, m); err != nil {
+		warnf("could not write run manifest: %s", err)
+	}
+}
+
+func (r *dimsumRun) execute() error {
+	experimentPath, err := r.design.Write(".")
+	if err != nil {
+		return err
+	}
+
+	infof("created experiment design file: %s", experimentPath)
+
+	cmd, err := r.d.Command()
+	if err != nil {
+		return err
+	}
+
+	infof("will run dimsum:\n%s", cmd)
+	r.status.dimsum(sheets.RunStateRunning, nil)
+
+	return executeCmd(cmd)
+}
+
+// reportDimsumRuns prints a summary of the outcome of each of the given runs,
+// returning the number that failed.
+func reportDimsumRuns(runs []*dimsumRun) int {
+	failed := 0
+
+	cliPrint("DiMSum runs:\n")
+
+	for _, r := range runs {
+		desc := "experiment " + r.exp.ExperimentID
+
+		if len(r.sweep.Values) > 0 {
+			desc += " [" + strings.Join(r.sweep.Values, ",") + "]"
+		}
+
+		switch {
+		case r.err != nil:
+			failed++
+
+			cliPrintf("- %s: failed: %s\n", desc, r.err)
+		case r.skipped:
+			cliPrintf("- %s: skipped, outputs already in %s\n", desc, r.outputDir)
+		default:
+			cliPrintf("- %s: %s, outputs in %s\n", desc, sheets.RunStateComplete, r.outputDir)
+		}
+	}
+
+	return failed
+}
+
+// dimsumSweepParams parses our --sweep options.
+func dimsumSweepParams() ([]dimsum.SweepParam, error) {
+	if dimsumSweepShare && len(dimsumSweep) == 0 {
+		return nil, ErrSweepShareNeedsSweep
+	}
+
+	params := make([]dimsum.SweepParam, len(dimsumSweep))
+
+	for i, spec := range dimsumSweep {
+		p, err := dimsum.ParseSweepParam(spec)
 		if err != nil {
-			die(err)
+			return nil, err
 		}
 
-		infof("then would move output files to %s", uniqueDimsumOutputDir)
-	},
+		params[i] = p
+	}
+
+	return params, nil
 }
 
-func dimsumUniqueOutputDir(d dimsum.DimSum, outputDir string, desired []*types.Sample) string {
-	uniqueDimsumOutputDir := filepath.Join(outputDir, d.Key(desired))
+// writeSweepIndexes writes a sweep index file for each experiment of the given
+// runs to the experiment's sub-directory of our output directory, and prints
+// its path.
+func writeSweepIndexes(params []dimsum.SweepParam, runs []*dimsumRun) {
+	var expIDs []string
+
+	sweeps := make(map[string][]*dimsum.SweepRun)
 
-	if _, err := os.Stat(uniqueDimsumOutputDir); err == nil {
-		entries, readErr := os.ReadDir(uniqueDimsumOutputDir)
-		if readErr != nil {
-			die(readErr)
+	for _, r := range runs {
+		id := r.exp.ExperimentID
+		if _, ok := sweeps[id]; !ok {
+			expIDs = append(expIDs, id)
 		}
 
-		if len(entries) > 0 {
-			dief("unique dimsum output directory %s already exists and is not empty", uniqueDimsumOutputDir)
+		sweeps[id] = append(sweeps[id], r.sweep)
+	}
+
+	stamp := time.Now().Format(sweepIndexTimeFormat)
+
+	for _, id := range expIDs {
+		path := filepath.Join(dimsumOutput, id, sweepIndexPrefix+stamp+sweepIndexSuffix)
+
+		if err := writeSweepIndex(path, params, sweeps[id]); err != nil {
+			warnf("could not write sweep index: %s", err)
+
+			continue
 		}
-	} else if !os.IsNotExist(err) {
-		die(err)
+
+		cliPrintf("sweep index for experiment %s written to %s\n", id, path)
 	}
+}
 
-	err := os.MkdirAll(uniqueDimsumOutputDir, dirPerm)
+func writeSweepIndex(path string, params []dimsum.SweepParam, sweep []*dimsum.SweepRun) error {
+	f, err := os.Create(path)
 	if err != nil {
-		die(err)
+		return err
+	}
+
+	err = dimsum.WriteSweepIndex(f, params, sweep)
+	if errc := f.Close(); err == nil {
+		err = errc
 	}
 
-	return uniqueDimsumOutputDir
+	return err
+}
+
+func dimsumUniqueOutputDir(d dimsum.DimSum, outputDir string, desired []*types.Sample) (string, error) {
+	uniqueDimsumOutputDir := filepath.Join(outputDir, d.Key(desired))
+
+	entries, err := os.ReadDir(uniqueDimsumOutputDir)
+
+	switch {
+	case err == nil && len(entries) > 0:
+		return "", fmt.Errorf("%w: %s", ErrOutputDirNotEmpty, uniqueDimsumOutputDir)
+	case err != nil && !errors.Is(err, fs.ErrNotExist):
+		return "", err
+	case runDryRun:
+		return uniqueDimsumOutputDir, nil
+	}
+
+	return uniqueDimsumOutputDir, os.MkdirAll(uniqueDimsumOutputDir, dirPerm)
 }
 
 func init() {
@@ -367,6 +808,18 @@ func init() {
 	runCmd.AddCommand(irodsToLustreCmd)
 	runCmd.AddCommand(dimsumCmd)
 
+	// flags common to all sub-commands
+	runCmd.PersistentFlags().StringVar(&runQC, qcFlag, string(types.QCPolicyPassOnly), qcFlagHelp)
+	runCmd.PersistentFlags().BoolVar(&runAllowQCFailures, "allow-qc-failures", false,
+		"allow the use of sample runs that did not pass manual QC")
+	runCmd.PersistentFlags().StringVar(&runStudyConflicts, studyConflictsFlag, string(samples.StudyConflictExclude),
+		studyConflictsFlagHelp)
+	runCmd.PersistentFlags().StringVar(&runSponsor, sponsorFlag, sponsor, sponsorFlagHelp)
+	runCmd.PersistentFlags().StringSliceVar(&runStudies, studyFlag, nil, studyFlagHelp)
+	runCmd.PersistentFlags().BoolVar(&runUpdateSheet, "update-sheet", false, updateSheetFlagHelp)
+	runCmd.PersistentFlags().BoolVar(&runDryRun, "dry-run", false, dryRunFlagHelp)
+	addSelectionFlags(runCmd)
+
 	// flags specific to these sub-commands
 	irodsToLustreCmd.Flags().StringVarP(&itlOutput, outputFlag, "o", "",
 		"output directory for FASTQ files")
@@ -380,7 +833,7 @@ func init() {
 	markFlagRequired(dimsumCmd, "fastqs")
 
 	dimsumCmd.Flags().StringVar(&dimsumBarcodeIdentityPath, "barcodeIdentityPath", "",
-		"path to your barcode identity file")
+		"path to your barcode identity file (overrides the experiments' barcodeIdentityPath)")
 	dimsumCmd.Flags().IntVar(&dimsumVsearchMinQual, "vsearchMinQual", dimsum.DefaultVsearchMinQual,
 		"passed through to dimsum")
 	dimsumCmd.Flags().IntVar(&dimsumStartStage, "startStage", dimsum.DefaultStartStage,
@@ -399,6 +852,10 @@ func init() {
 		"passed through to dimsum")
 	dimsumCmd.Flags().BoolVar(&dimsumDesignPairDuplicates, "designPairDuplicates", dimsum.DefaultDesignPairDuplicates,
 		"passed through to dimsum")
+	dimsumCmd.Flags().StringArrayVar(&dimsumSweep, "sweep", nil,
+		"option=value1,value2,... to do a run for every combination of values (repeatable)")
+	dimsumCmd.Flags().BoolVar(&dimsumSweepShare, "sweep-share-intermediates", false,
+		"reuse DiMSum stage 1-3 outputs between --sweep runs with the same cutAdapt* values")
 }
 
 func markFlagRequired(cmd *cobra.Command, flagName string) {
@@ -0,0 +1,257 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Authors:
+ *	- Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/wtsi-hgi/dimsum-automation/types"
+)
+
+const (
+	ErrSampleSources  = Error("supply only one of sampleName:runID pairs, --samples-file or --experiment")
+	ErrNeedExperiment = Error("--runs and --exclude can only be used with --experiment")
+	ErrNeedBatch      = Error("the desired samples are in more than one experiment; supply --batch to run them all")
+)
+
+// options for selecting the samples of run sub-commands.
+var (
+	runExperiments []string
+	runRunIDs      []string
+	runExclude     []string
+	runSamplesFile string
+	runBatch       bool
+)
+
+// addSelectionFlags adds the flags for choosing samples other than by
+// sampleName:runID args to the given command.
+func addSelectionFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringSliceVar(&runExperiments, "experiment", nil,
+		"use the sample runs of this experiment, instead of sampleName:runID args (can be repeated with --batch)")
+	cmd.PersistentFlags().StringSliceVar(&runRunIDs, "runs", nil,
+		"with --experiment, only use sample runs with these run IDs")
+	cmd.PersistentFlags().StringSliceVar(&runExclude, "exclude", nil,
+		"with --experiment, don't use these samples (sampleName or sampleName:runID)")
+	cmd.PersistentFlags().StringVar(&runSamplesFile, "samples-file", "",
+		"path to a TSV of sample names and run IDs to use, instead of sampleName:runID args")
+	cmd.PersistentFlags().BoolVar(&runBatch, "batch", false,
+		"allow the desired samples to be in more than one experiment, handling each experiment separately")
+}
+
+// sampleSelection is how the user chose the samples to run on.
+type sampleSelection struct {
+	nameRuns    []*types.Sample
+	experiments []string
+	keep        func(s *types.Sample) bool
+}
+
+// newSampleSelection returns a sampleSelection based on the given
+// sampleName:runID args and our selection flags.
+func newSampleSelection(nameRunStrs []string) (*sampleSelection, error) {
+	if err := checkSampleSources(nameRunStrs); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case len(runExperiments) > 0:
+		return &sampleSelection{experiments: runExperiments, keep: experimentSampleFilter()}, nil
+	case runSamplesFile != "":
+		nameRuns, err := readSamplesFile(runSamplesFile)
+		if err != nil {
+			return nil, err
+		}
+
+		return &sampleSelection{nameRuns: nameRuns}, nil
+	default:
+		nameRuns, err := nameRunStrsToNameRuns(nameRunStrs)
+
+		return &sampleSelection{nameRuns: nameRuns}, err
+	}
+}
+
+// checkSampleSources returns an error if more than one way of choosing samples
+// was used, or if --runs or --exclude were used without --experiment.
+func checkSampleSources(nameRunStrs []string) error {
+	sources := 0
+
+	for _, used := range []bool{len(nameRunStrs) > 0, runSamplesFile != "", len(runExperiments) > 0} {
+		if used {
+			sources++
+		}
+	}
+
+	if sources > 1 {
+		return ErrSampleSources
+	}
+
+	if len(runExperiments) == 0 && (len(runRunIDs) > 0 || len(runExclude) > 0) {
+		return ErrNeedExperiment
+	}
+
+	return nil
+}
+
+// experimentSampleFilter returns a filter that keeps samples with one of the
+// --runs run IDs (if any), that aren't in --exclude.
+func experimentSampleFilter() func(s *types.Sample) bool {
+	return func(s *types.Sample) bool {
+		if len(runRunIDs) > 0 && !slices.Contains(runRunIDs, s.RunID) {
+			return false
+		}
+
+		return !slices.ContainsFunc(runExclude, func(excluded string) bool {
+			return excluded == s.SampleName || excluded == s.SampleName+":"+s.RunID
+		})
+	}
+}
+
+func readSamplesFile(path string) ([]*types.Sample, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	nameRuns, err := types.ReadNameRuns(f)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(nameRuns) == 0 {
+		return nil, ErrSamplesRequired
+	}
+
+	return nameRuns, nil
+}
+
+func nameRunStrsToNameRuns(nameRunStrs []string) ([]*types.Sample, error) {
+	result := make([]*types.Sample, 0, len(nameRunStrs))
+	done := make(map[string]bool)
+
+	for _, nameRunStr := range nameRunStrs {
+		if done[nameRunStr] {
+			continue
+		}
+
+		s, err := types.StringToNameRun(nameRunStr)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, s)
+		done[nameRunStr] = true
+	}
+
+	if len(result) == 0 {
+		return nil, ErrSamplesRequired
+	}
+
+	return result, nil
+}
+
+// subset returns a library for each experiment with selected samples, from
+// amongst the given libraries. Unless --batch was supplied, returns
+// ErrNeedBatch if there would be more than one.
+func (sel *sampleSelection) subset(libs types.Libraries) (types.Libraries, error) {
+	groups, err := sel.groups(libs)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(groups) > 1 && !runBatch {
+		return nil, ErrNeedBatch
+	}
+
+	return groups, nil
+}
+
+func (sel *sampleSelection) groups(libs types.Libraries) (types.Libraries, error) {
+	if len(sel.experiments) == 0 {
+		if !runBatch {
+			return sel.singleGroup(libs)
+		}
+
+		return libs.SubsetByExperiment(sel.nameRuns)
+	}
+
+	groups := make(types.Libraries, len(sel.experiments))
+
+	for i, id := range sel.experiments {
+		lib, err := libs.SubsetExperiment(id, sel.keep)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", err, id)
+		}
+
+		groups[i] = lib
+	}
+
+	return groups, nil
+}
+
+// singleGroup returns a library for the single experiment our selected samples
+// are in. If they're not, it works out if that's because some weren't found
+// (returning an error naming them), or because they span experiments
+// (returning ErrNeedBatch).
+func (sel *sampleSelection) singleGroup(libs types.Libraries) (types.Libraries, error) {
+	lib, err := libs.Subset(sel.nameRuns)
+	if err == nil {
+		return types.Libraries{lib}, nil
+	}
+
+	if !errors.Is(err, types.ErrNotAllSamplesInSameExperiment) && !errors.Is(err, types.ErrSamplesNotFound) {
+		return nil, err
+	}
+
+	groups, byExpErr := libs.SubsetByExperiment(sel.nameRuns)
+	if byExpErr != nil {
+		return nil, byExpErr
+	}
+
+	if len(groups) > 1 {
+		return nil, ErrNeedBatch
+	}
+
+	return nil, err
+}
+
+// describeNameRuns returns the sampleName:runID pairs of the samples of the
+// given library's first experiment.
+func describeNameRuns(lib *types.Library) string {
+	nameRuns := make([]string, 0, len(lib.Experiments[0].Samples))
+
+	for _, s := range lib.Experiments[0].Samples {
+		nameRuns = append(nameRuns, s.SampleName+":"+s.RunID)
+	}
+
+	return strings.Join(nameRuns, " ")
+}
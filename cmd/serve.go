@@ -0,0 +1,345 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package cmd
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/wtsi-hgi/dimsum-automation/config"
+	"github.com/wtsi-hgi/dimsum-automation/samples"
+	"github.com/wtsi-hgi/dimsum-automation/server"
+	"github.com/wtsi-hgi/dimsum-automation/types"
+)
+
+const (
+	ErrEmptyToken = Error("--token-file is empty")
+
+	defaultServeAddr  = "localhost:8080"
+	readHeaderTimeout = 10 * time.Second
+	shutdownTimeout   = 10 * time.Second
+	tokenBytes        = 32
+)
+
+// options for this cmd.
+var (
+	serveAddr           string
+	serveQC             string
+	serveStudyConflicts string
+	serveSponsor        string
+	serveMaxStaleness   time.Duration
+	serveWorkDir        string
+	serveReadOnly       bool
+	serveFastqDir       string
+	serveOutputDir      string
+	serveTokenFile      string
+)
+
+// serveCmd represents the serve command.
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve a web UI and HTTP API for sample info and runs.",
+	Long: `Serve a web UI and HTTP API for sample info and runs.
+
+Starts a long-running server that keeps the sample info of the --sponsor's
+studies from MLWH and the Google sheet in memory, refreshing it every 10
+minutes, and serves it as JSON (in the same form as 'info') at these endpoints:
+
+GET  /api/libraries        all libraries, with their experiments and samples
+GET  /api/libraries/{id}   a single library
+GET  /api/experiments      all experiments, with their LibraryID
+GET  /api/experiments/{id} a single experiment
+GET  /api/samples          all sample runs, with their LibraryID and
+                           ExperimentID, optionally filtered with ?library=
+                           and/or ?experiment=
+GET  /api/health           when the sample info was last refreshed, whether
+                           that's longer ago than --max-staleness, any error
+                           from the last refresh, and counts of runs by state;
+                           the status code is 503 if not ok
+GET  /api/settings         the --fastqs and --output directories, the DiMSum
+                           options and sweep parameters runs can use, and
+                           whether runs can be submitted
+GET  /api/history          the manifests of the dimsum runs done in --output,
+                           most recent first
+
+With --fastqs, sample runs also say if their fastqs are in that directory
+(HasFastqs), and all sample runs have their QCState.
+
+Runs can be submitted, and are done one at a time in the order submitted, by
+running this executable's 'run' sub-commands (with the same --qc,
+--study-conflicts, --sponsor, --config and --profile) in a new sub-directory of
+--work-dir (which is deleted after successful runs):
+
+POST /api/runs/fastq       submit an irods-to-lustre run
+POST /api/runs/dimsum      submit a dimsum run
+GET  /api/runs             all submitted runs, with their state and output
+GET  /api/runs/{id}        a single submitted run
+
+The body of a run submission is JSON like:
+{
+  "samples": ["AMA1:1234", "AMA2:5678"],
+  "output_dir": "/absolute/output/dir",
+  "fastq_dir": "/absolute/fastqs/dir",
+  "options": {"vsearchMinQual": "30"},
+  "sweep": ["maxSubstitutions=2,3"]
+}
+where fastq_dir, options and sweep are only for dimsum runs, and options can be
+any of the dimsum sub-command's DiMSum options. Samples that span experiments
+are run with --batch. fastq_dir must be within --fastqs, and output_dir within
+--fastqs for fastq runs and --output for dimsum runs; they default to those
+directories.
+
+Run submissions must have a Content-Type of application/json, can't be made by
+web pages from other sites, and need an "Authorization: Bearer <token>" header.
+The token is read from --token-file (which must not be world-readable), or if
+not supplied, a random one is generated and printed to STDOUT on start up,
+along with a URL for the web UI that includes it.
+
+With --read-only, runs can't be submitted.
+
+Browsing to the server's address (eg. http://localhost:8080/) gives a web UI
+for people who'd rather not use a terminal. It lists libraries, their
+experiments and their samples with QC state and fastq availability, lets you
+tick samples and choose DiMSum parameters to get their fastqs or run DiMSum on
+them, and shows submitted runs and the run history of --output.
+
+Settings are read as for other commands, and are inherited by the runs, but
+since runs have a different working directory, any paths in them should be
+absolute.
+`,
+	Run: func(command *cobra.Command, _ []string) {
+		if err := serve(command.Context()); err != nil {
+			die(err)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(serveCmd)
+
+	serveCmd.Flags().StringVar(&serveAddr, "addr", defaultServeAddr, "host:port to listen on")
+	serveCmd.Flags().StringVar(&serveQC, qcFlag, string(types.QCPolicyPassOnly), qcFlagHelp)
+	serveCmd.Flags().StringVar(&serveStudyConflicts, studyConflictsFlag, string(samples.StudyConflictExclude),
+		studyConflictsFlagHelp)
+	serveCmd.Flags().StringVar(&serveSponsor, sponsorFlag, sponsor, sponsorFlagHelp)
+	serveCmd.Flags().DurationVar(&serveMaxStaleness, "max-staleness", server.DefaultMaxStaleness,
+		"how old sample info can be before health reports it as stale")
+	serveCmd.Flags().StringVar(&serveWorkDir, "work-dir", "",
+		"directory to create run working directories in (defaults to the system temp directory)")
+	serveCmd.Flags().BoolVar(&serveReadOnly, "read-only", false, "don't allow runs to be submitted")
+	serveCmd.Flags().StringVarP(&serveFastqDir, "fastqs", "f", "",
+		"directory that runs get FASTQ files in to by default")
+	serveCmd.Flags().StringVarP(&serveOutputDir, outputFlag, "o", "",
+		"directory that dimsum runs output to by default")
+	serveCmd.Flags().StringVar(&serveTokenFile, "token-file", "",
+		"file containing the token needed to submit runs (defaults to a random one)")
+}
+
+// serve serves our API until the given context is cancelled.
+func serve(ctx context.Context) error {
+	opts, err := clientOptions(serveQC, serveStudyConflicts)
+	if err != nil {
+		return err
+	}
+
+	c, err := loadConfig(config.RequireAll)
+	if err != nil {
+		return err
+	}
+
+	db, s, err := getDBAndSheets(ctx, c)
+	if err != nil {
+		return err
+	}
+
+	opts.SheetID = c.SheetID
+	opts.CacheLifetime = cacheLifetime
+	opts.Prefetch = []string{serveSponsor}
+
+	infof("fetching sample info for %s", serveSponsor)
+
+	client := samples.New(ctx, db, s, opts)
+	defer client.Close()
+
+	if err = client.Err(); err != nil {
+		warnf("initial fetch of sample info failed: %s", err)
+	}
+
+	serverOpts, err := serverOptions()
+	if err != nil {
+		return err
+	}
+
+	srv := server.New(client, serverOpts)
+	defer srv.Close()
+
+	srv.Handle("GET /", server.UI())
+
+	return listenAndServe(ctx, srv)
+}
+
+// serverOptions returns server.Options from our command line flags.
+func serverOptions() (server.Options, error) {
+	opts := server.Options{
+		Sponsor:      serveSponsor,
+		MaxStaleness: serveMaxStaleness,
+		Logger:       appLogger,
+		RunArgs: []string{
+			"--" + qcFlag, serveQC,
+			"--" + studyConflictsFlag, serveStudyConflicts,
+			"--" + sponsorFlag, serveSponsor,
+		},
+	}
+
+	var err error
+
+	if opts.FastqDir, err = absOrBlank(serveFastqDir); err != nil {
+		return opts, err
+	}
+
+	if opts.OutputDir, err = absOrBlank(serveOutputDir); err != nil {
+		return opts, err
+	}
+
+	if qc, _ := types.StringToQCPolicy(serveQC); qc != types.QCPolicyPassOnly {
+		opts.RunArgs = append(opts.RunArgs, "--allow-qc-failures")
+	}
+
+	if rootConfigFile != "" {
+		path, err := filepath.Abs(rootConfigFile)
+		if err != nil {
+			return opts, err
+		}
+
+		opts.RunArgs = append(opts.RunArgs, "--config", path)
+	}
+
+	if rootProfile != "" {
+		opts.RunArgs = append(opts.RunArgs, "--profile", rootProfile)
+	}
+
+	if serveReadOnly {
+		return opts, nil
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return opts, err
+	}
+
+	opts.Runner = &server.ExecRunner{Exe: exe, WorkDir: serveWorkDir}
+
+	opts.Token, err = serveToken()
+
+	return opts, err
+}
+
+// serveToken returns the contents of --token-file, or if not supplied, a new
+// random token that it prints to STDOUT.
+func serveToken() (string, error) {
+	if serveTokenFile != "" {
+		b, err := config.ReadSecretFile(serveTokenFile)
+		if err != nil {
+			return "", err
+		}
+
+		token := strings.TrimSpace(string(b))
+		if token == "" {
+			return "", ErrEmptyToken
+		}
+
+		return token, nil
+	}
+
+	b := make([]byte, tokenBytes)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	token := hex.EncodeToString(b)
+
+	cliPrintf("runs can be submitted with the token %s, eg. via http://%s/#token=%s\n", token, serveAddr, token)
+
+	return token, nil
+}
+
+// absOrBlank returns the absolute version of the given path, or blank if it's
+// blank.
+func absOrBlank(path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+
+	return filepath.Abs(path)
+}
+
+// listenAndServe serves the given handler on our address until the given
+// context is cancelled, then shuts down gracefully.
+func listenAndServe(ctx context.Context, handler http.Handler) error {
+	hs := &http.Server{
+		Addr:              serveAddr,
+		Handler:           handler,
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+
+	errCh := make(chan error, 1)
+
+	go func() {
+		errCh <- hs.ListenAndServe()
+	}()
+
+	infof("serving on http://%s", serveAddr)
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	info("shutting down")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := hs.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+
+	if err := <-errCh; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
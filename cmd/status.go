@@ -0,0 +1,137 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/wtsi-hgi/dimsum-automation/config"
+	"github.com/wtsi-hgi/dimsum-automation/sheets"
+	"github.com/wtsi-hgi/dimsum-automation/types"
+)
+
+const (
+	ErrSheetNotWritable = Error("--update-sheet needs a Google sheet, not " + config.EnvVarSheetFile)
+
+	updateSheetFlagHelp = "record the progress of this run in the \"" + sheets.RunsSheetName +
+		"\" tab of the Google sheet"
+)
+
+// runStatus records the progress of a run in the runs tab of the Google sheet,
+// if --update-sheet was supplied. Failing to record progress only results in a
+// warning, so never stops the run itself.
+type runStatus struct {
+	w       sheets.RunStatusWriter
+	sheetID string
+	base    sheets.RunStatus
+}
+
+// newRunStatus returns a runStatus for the samples of the given libraries, using
+// the sheet in the given config, which does nothing if --update-sheet wasn't
+// supplied (or --dry-run was).
+func newRunStatus(c *config.Config, libs types.Libraries) *runStatus {
+	if !runUpdateSheet || runDryRun {
+		return &runStatus{}
+	}
+
+	if c.SheetPath != "" {
+		die(ErrSheetNotWritable)
+	}
+
+	sc, err := sheets.ServiceCredentialsFromConfig(c)
+	if err != nil {
+		die(err)
+	}
+
+	w, err := sheets.NewWritable(sc)
+	if err != nil {
+		die(err)
+	}
+
+	var expIDs, nameRuns []string
+
+	for _, lib := range libs {
+		for _, exp := range lib.Experiments {
+			expIDs = append(expIDs, exp.ExperimentID)
+
+			for _, s := range exp.Samples {
+				nameRuns = append(nameRuns, fmt.Sprintf("%s:%s", s.SampleName, s.RunID))
+			}
+		}
+	}
+
+	sort.Strings(nameRuns)
+
+	return &runStatus{
+		w:       w,
+		sheetID: c.SheetID,
+		base: sheets.RunStatus{
+			ExperimentID: strings.Join(expIDs, ","),
+			Samples:      strings.Join(nameRuns, ","),
+		},
+	}
+}
+
+// fastq records the given state of fastq retrieval, along with the error
+// message if err is not nil.
+func (r *runStatus) fastq(state string, err error) {
+	r.update(func(s *sheets.RunStatus) {
+		s.FastqStatus = state
+		s.Message = errMsg(err)
+	})
+}
+
+// dimsum records the given state of the DiMSum run, along with the error
+// message if err is not nil.
+func (r *runStatus) dimsum(state string, err error) {
+	r.update(func(s *sheets.RunStatus) {
+		s.DimSumStatus = state
+		s.Message = errMsg(err)
+	})
+}
+
+func (r *runStatus) update(fn func(*sheets.RunStatus)) {
+	if r.w == nil {
+		return
+	}
+
+	status := r.base
+	fn(&status)
+
+	if err := r.w.UpdateRunStatus(r.sheetID, &status); err != nil {
+		warnf("could not update run status in the Google sheet: %s", err)
+	}
+}
+
+func errMsg(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	return err.Error()
+}
@@ -0,0 +1,123 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package cmd
+
+import (
+	"errors"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/wtsi-hgi/dimsum-automation/config"
+	"github.com/wtsi-hgi/dimsum-automation/sheets"
+)
+
+// options for this cmd.
+var validateSkipPaths bool
+
+// validateSheetCmd represents the validate-sheet command.
+var validateSheetCmd = &cobra.Command{
+	Use:   "validate-sheet",
+	Short: "Check the spreadsheet for problems.",
+	Long: `Check the spreadsheet for problems.
+
+Every library, experiment and sample row of the spreadsheet is checked, not just
+for values that can't be read (like --validate of the info sub-command), but
+also for values that wouldn't make sense to DiMSum:
+
+- wildtypeSequence must only contain A, C, G and T, and be a multiple of 3
+  bases long for coding experiments;
+- cutadapt5First and cutadapt5Second must be valid cutadapt adaptors, and the
+  cutadaptCut* columns numbers of bases;
+- permittedSequences must be IUPAC codes as long as the wildtypeSequence;
+- retainedReplicates must be "all" or experiment_replicates of the
+  experiment's samples;
+- experiments must have both input and output samples;
+- experiment_replicate must be 1 or more;
+- barcodeDesignPath, barcodeIdentityPath, countPath and synonymSequencePath
+  must exist.
+
+Use --skip-paths if you're not running this on a machine that can see those
+paths.
+
+Every problem found is listed with its tab, row and column, and the command
+exits with an error if there were any.
+`,
+	Run: func(_ *cobra.Command, _ []string) {
+		c, err := loadConfig(config.RequireSheet)
+		if err != nil {
+			die(err)
+		}
+
+		s, err := sheets.FromConfig(c)
+		if err != nil {
+			die(err)
+		}
+
+		pathExists := func(path string) bool {
+			_, err := os.Stat(path)
+
+			return err == nil
+		}
+
+		if validateSkipPaths {
+			pathExists = nil
+		}
+
+		err = reportCellErrors(s.Validate(c.SheetID, pathExists))
+		if err != nil {
+			die(err)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(validateSheetCmd)
+
+	validateSheetCmd.Flags().BoolVar(&validateSkipPaths, "skip-paths", false,
+		"don't check that paths in the experiments tab exist")
+}
+
+// reportCellErrors prints the given error's list of problem cells and returns
+// ErrInvalidSheet if it is a sheets.CellErrors. Otherwise, reports that there
+// were no problems if the error is nil, and returns it.
+func reportCellErrors(err error) error {
+	var cellErrs sheets.CellErrors
+	if !errors.As(err, &cellErrs) {
+		if err == nil {
+			cliPrint("No problems found in the spreadsheet.\n")
+		}
+
+		return err
+	}
+
+	cliPrintf("Please fix the following cells in the spreadsheet (%d problems):\n", len(cellErrs))
+
+	for _, ce := range cellErrs {
+		cliPrintf("- %s tab, row %d, column %s: %q should be %s\n", ce.Sheet, ce.Row, ce.Column, ce.Value, ce.Expected)
+	}
+
+	return ErrInvalidSheet
+}
@@ -27,28 +27,99 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
 )
 
 const (
-	EnvVarCreds  = "DIMSUM_AUTOMATION_CREDENTIALS_FILE"
-	EnvVarSheet  = "DIMSUM_AUTOMATION_SPREADSHEET_ID"
-	EnvVarUser   = "DIMSUM_AUTOMATION_SQL_USER"
-	EnvVarPass   = "DIMSUM_AUTOMATION_SQL_PASS"
-	EnvVarHost   = "DIMSUM_AUTOMATION_SQL_HOST"
-	EnvVarPort   = "DIMSUM_AUTOMATION_SQL_PORT"
-	EnvVarDBName = "DIMSUM_AUTOMATION_SQL_DB"
+	EnvVarPrefix      = "DIMSUM_AUTOMATION_"
+	EnvVarCreds       = EnvVarPrefix + "CREDENTIALS_FILE"
+	EnvVarSheet       = EnvVarPrefix + "SPREADSHEET_ID"
+	EnvVarUser        = EnvVarPrefix + "SQL_USER"
+	EnvVarPass        = EnvVarPrefix + "SQL_PASS"
+	EnvVarPassFile    = EnvVarPrefix + "PASSWORD_FILE"
+	EnvVarPassCommand = EnvVarPrefix + "PASSWORD_COMMAND"
+	EnvVarHost        = EnvVarPrefix + "SQL_HOST"
+	EnvVarPort        = EnvVarPrefix + "SQL_PORT"
+	EnvVarDBName      = EnvVarPrefix + "SQL_DB"
+	EnvVarSQLite      = EnvVarPrefix + "SQLITE_DB"
+	EnvVarSQLTimeout  = EnvVarPrefix + "SQL_TIMEOUT"
+	EnvVarSheetFile   = EnvVarPrefix + "SPREADSHEET_FILE"
+	EnvVarLayout      = EnvVarPrefix + "SHEET_LAYOUT"
+	EnvVarConfig      = EnvVarPrefix + "CONFIG"
+	EnvVarProfile     = EnvVarPrefix + "PROFILE"
 
 	sqlNetwork = "tcp"
+
+	defaultConfigDir  = "dimsum-automation"
+	defaultConfigFile = "config.yml"
 )
 
 type Error string
 
 func (e Error) Error() string { return string(e) }
 
-const ErrMissingEnvs = Error("missing required environment variables")
+const (
+	ErrMissingEnvs    = Error("missing required settings")
+	ErrInvalidTimeout = Error("invalid " + EnvVarSQLTimeout + " duration")
+	ErrUnknownProfile = Error("profile not found in config file")
+	ErrNoProfile      = Error("config file has multiple profiles but none was chosen")
+	ErrUnknownSetting = Error("unknown setting in config file")
+)
+
+// settings are the env vars that can also be set in a config file profile.
+var settings = []string{ //nolint:gochecknoglobals
+	EnvVarCreds, EnvVarSheet, EnvVarUser, EnvVarPass, EnvVarPassFile, EnvVarPassCommand,
+	EnvVarHost, EnvVarPort, EnvVarDBName, EnvVarSQLite, EnvVarSQLTimeout, EnvVarSheetFile, EnvVarLayout,
+}
+
+// SettingKey returns the config file key corresponding to the given env var,
+// eg. "sql_user" for DIMSUM_AUTOMATION_SQL_USER.
+func SettingKey(envVar string) string {
+	return strings.ToLower(strings.TrimPrefix(envVar, EnvVarPrefix))
+}
+
+// Requirement says which groups of settings a command needs.
+type Requirement int
+
+const (
+	// RequireSheet needs either the Google sheet settings, or a spreadsheet
+	// file.
+	RequireSheet Requirement = 1 << iota
+
+	// RequireMLWH needs either the MLWH MySQL settings, or a SQLite database.
+	RequireMLWH
+
+	// RequireAll needs all of the above.
+	RequireAll = RequireSheet | RequireMLWH
+)
+
+// MissingSettingsError lists the settings that were required but not set. It
+// satisfies errors.Is(err, ErrMissingEnvs).
+type MissingSettingsError struct {
+	// Missing are the env var names of the missing settings, along with their
+	// config file keys and any alternatives.
+	Missing []string
+}
+
+// Error lists the missing settings.
+func (e *MissingSettingsError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrMissingEnvs, strings.Join(e.Missing, "; "))
+}
+
+// Is returns true for ErrMissingEnvs.
+func (e *MissingSettingsError) Is(target error) bool {
+	return target == ErrMissingEnvs //nolint:errorlint
+}
 
 type Config struct {
 	CredentialsPath string
@@ -58,44 +129,359 @@ type Config struct {
 	Host            string
 	Port            string
 	DBName          string
+	SQLitePath      string
+	SheetPath       string
+	QueryTimeout    time.Duration
+	LayoutPath      string
+
+	// Profile is the name of the config file profile used, if any.
+	Profile string
+}
+
+// Options control how Load() finds settings.
+type Options struct {
+	// Dir is a directory to look for the .env file in, instead of the current
+	// working directory.
+	Dir string
+
+	// File is the path to a YAML config file. If blank, the file at
+	// DIMSUM_AUTOMATION_CONFIG is used, or failing that,
+	// dimsum-automation/config.yml in your config directory (eg. ~/.config),
+	// if it exists.
+	File string
+
+	// Profile is the name of the profile in the config file to use. If blank,
+	// DIMSUM_AUTOMATION_PROFILE is used, or failing that, the file's
+	// default_profile, or its only profile.
+	Profile string
+
+	// Require says which settings must be set.
+	Require Requirement
 }
 
 // FromEnv returns a new Config with properies populated from environment
 // variables DIMSUM_AUTOMATION_*, where * is amongst: CREDENTIALS_FILE,
-// SPREADSHEET_ID, SQL_USER, SQL_PASS, SQL_HOST, SQL_PORT, and TSQL_DB.
+// SPREADSHEET_ID, SQL_USER, SQL_PASS, SQL_HOST, SQL_PORT, and SQL_DB.
+//
+// Alternatively to the SQL_* variables, you can define SQLITE_DB as the path to
+// a local SQLite database seeded with MLWH test data, in which case the SQL_*
+// variables are not required.
+//
+// Alternatively to the CREDENTIALS_FILE and SPREADSHEET_ID variables, you can
+// define SPREADSHEET_FILE as the path to a local XLSX export of the
+// spreadsheet, or a directory of CSV or TSV exports of its sheets, in which case
+// the Google variables are not required.
+//
+// Instead of putting the password in SQL_PASS, you can define PASSWORD_FILE as
+// the path to a file containing it (which must not be world-readable), or
+// PASSWORD_COMMAND as a shell command that prints it (eg. from a password
+// manager). A .env or config file that sets SQL_PASS must not be world-readable
+// either.
+//
+// You can optionally define SHEET_LAYOUT as the path to a YAML file describing
+// the tab and column names of the spreadsheet, if they differ from the
+// defaults (see sheets.LoadLayout).
+//
+// You can optionally define SQL_TIMEOUT as a duration (eg. "90s") to limit how
+// long each attempt at an MLWH query can take. If not defined, QueryTimeout
+// will be zero, meaning the mlwh package's default.
 //
 // If these environment variables are defined in a file called .env (and not
 // previously set in an environment variable), they will be automatically
-// loaded.
+// loaded. Any that are still not set are taken from a config file, if there is
+// one (see Load()).
 //
 // Optionally supply a directory to look for the .env file in.
 func FromEnv(dir ...string) (*Config, error) {
-	var parentDir string
+	opts := Options{Require: RequireAll}
 	if len(dir) == 1 {
-		parentDir = dir[0] + string(os.PathSeparator)
+		opts.Dir = dir[0]
+	}
+
+	return Load(opts)
+}
+
+// Load is like FromEnv(), but lets you choose the config file and profile,
+// and which settings are required.
+//
+// The config file is YAML with named profiles of settings, whose keys are the
+// env var names without the DIMSUM_AUTOMATION_ prefix, in lower case:
+//
+//	default_profile: prod
+//	profiles:
+//	  prod:
+//	    credentials_file: /path/to/credentials.json
+//	    spreadsheet_id: 1ksldfj3lsadfj
+//	    sql_host: mlwh-db
+//	    ...
+//	  offline:
+//	    sqlite_db: /path/to/mlwh.db
+//	    spreadsheet_file: /path/to/export.xlsx
+//
+// Environment variables override the chosen profile's settings. If required
+// settings are missing, returns a *MissingSettingsError listing them.
+func Load(opts Options) (*Config, error) {
+	var parentDir string
+	if opts.Dir != "" {
+		parentDir = opts.Dir + string(os.PathSeparator)
+	}
+
+	if err := loadDotEnv(parentDir + ".env"); err != nil {
+		return nil, err
+	}
+
+	profile, values, err := profileSettings(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	get := func(envVar string) string {
+		if v := os.Getenv(envVar); v != "" {
+			return v
+		}
+
+		return values[envVar]
+	}
+
+	timeout, err := parseTimeout(get(EnvVarSQLTimeout))
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Config{
+		CredentialsPath: get(EnvVarCreds),
+		SheetID:         get(EnvVarSheet),
+		User:            get(EnvVarUser),
+		Password:        get(EnvVarPass),
+		Host:            get(EnvVarHost),
+		Port:            get(EnvVarPort),
+		DBName:          get(EnvVarDBName),
+		SQLitePath:      get(EnvVarSQLite),
+		SheetPath:       get(EnvVarSheetFile),
+		QueryTimeout:    timeout,
+		LayoutPath:      get(EnvVarLayout),
+		Profile:         profile,
+	}
+
+	if opts.Require&RequireMLWH != 0 && c.SQLitePath == "" {
+		if err = c.resolvePassword(get(EnvVarPassFile), get(EnvVarPassCommand)); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = c.check(opts.Require); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// loadDotEnv sets any env vars defined in the given .env file that aren't
+// already set. A missing or unparsable file is ignored, but if the file sets a
+// secret it must not be world-readable.
+func loadDotEnv(path string) error {
+	values, err := godotenv.Read(path)
+	if err != nil {
+		return nil //nolint:nilerr
+	}
+
+	if setsSecret(values) {
+		if err = checkSecretFile(path); err != nil {
+			return err
+		}
+	}
+
+	for envVar, value := range values {
+		if _, set := os.LookupEnv(envVar); !set {
+			os.Setenv(envVar, value)
+		}
+	}
+
+	return nil
+}
+
+// configFile is the structure of a config file.
+type configFile struct {
+	DefaultProfile string                       `yaml:"default_profile"`
+	Profiles       map[string]map[string]string `yaml:"profiles"`
+}
+
+// profileSettings returns the name and settings (keyed on env var) of the
+// chosen profile of the config file, if there is one.
+func profileSettings(opts Options) (string, map[string]string, error) {
+	path, err := configFilePath(opts.File)
+	if err != nil || path == "" {
+		return "", nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var cf configFile
+
+	if err = yaml.Unmarshal(data, &cf); err != nil {
+		return "", nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	name, err := cf.chooseProfile(opts.Profile)
+	if err != nil {
+		return "", nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	values := make(map[string]string, len(settings))
+
+	for key, value := range cf.Profiles[name] {
+		i := slices.IndexFunc(settings, func(envVar string) bool { return SettingKey(envVar) == key })
+		if i < 0 {
+			return "", nil, fmt.Errorf("%w: %s in profile %s of %s", ErrUnknownSetting, key, name, path)
+		}
+
+		values[settings[i]] = value
 	}
 
-	godotenv.Load(parentDir + ".env")
+	if cf.setsSecret() {
+		if err = checkSecretFile(path); err != nil {
+			return "", nil, err
+		}
+	}
+
+	return name, values, nil
+}
+
+// setsSecret returns true if any profile of the config file sets a secret.
+func (cf *configFile) setsSecret() bool {
+	return slices.ContainsFunc(secretSettings, func(envVar string) bool {
+		for _, profile := range cf.Profiles {
+			if profile[SettingKey(envVar)] != "" {
+				return true
+			}
+		}
+
+		return false
+	})
+}
+
+// configFilePath returns the given path, or the one in DIMSUM_AUTOMATION_CONFIG,
+// or the default path if that file exists, or blank.
+func configFilePath(path string) (string, error) {
+	if path != "" {
+		return path, nil
+	}
 
-	cred := os.Getenv(EnvVarCreds)
-	sheet := os.Getenv(EnvVarSheet)
-	user := os.Getenv(EnvVarUser)
-	pass := os.Getenv(EnvVarPass)
-	host := os.Getenv(EnvVarHost)
-	port := os.Getenv(EnvVarPort)
-	dbname := os.Getenv(EnvVarDBName)
+	if path = os.Getenv(EnvVarConfig); path != "" {
+		return path, nil
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", nil //nolint:nilerr
+	}
+
+	path = filepath.Join(dir, defaultConfigDir, defaultConfigFile)
+
+	if _, err = os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+
+	return path, err
+}
+
+// chooseProfile returns the given profile name, or the one in
+// DIMSUM_AUTOMATION_PROFILE, or our default, or our only profile.
+func (cf *configFile) chooseProfile(name string) (string, error) {
+	for _, candidate := range []string{name, os.Getenv(EnvVarProfile), cf.DefaultProfile} {
+		if candidate == "" {
+			continue
+		}
+
+		if _, ok := cf.Profiles[candidate]; !ok {
+			return "", fmt.Errorf("%w: %s", ErrUnknownProfile, candidate)
+		}
+
+		return candidate, nil
+	}
+
+	switch len(cf.Profiles) {
+	case 0:
+		return "", nil
+	case 1:
+		for name := range cf.Profiles {
+			return name, nil
+		}
+	}
+
+	return "", ErrNoProfile
+}
+
+// check returns a *MissingSettingsError if any of the settings needed for the
+// given Requirement are not set.
+func (c *Config) check(req Requirement) error {
+	var missing []string
+
+	if req&RequireSheet != 0 && c.SheetPath == "" {
+		missing = append(missing, missingSettings(EnvVarSheetFile,
+			setting{EnvVarCreds, c.CredentialsPath}, setting{EnvVarSheet, c.SheetID})...)
+	}
+
+	if req&RequireMLWH != 0 && c.SQLitePath == "" {
+		missing = append(missing, missingSettings(EnvVarSQLite,
+			setting{EnvVarUser, c.User}, setting{EnvVarPass, c.Password}, setting{EnvVarHost, c.Host},
+			setting{EnvVarPort, c.Port}, setting{EnvVarDBName, c.DBName})...)
+	}
+
+	if len(missing) > 0 {
+		return &MissingSettingsError{Missing: missing}
+	}
+
+	return nil
+}
+
+// setting is an env var and its value.
+type setting struct {
+	envVar string
+	value  string
+}
+
+// missingSettings returns descriptions of the given settings that have blank
+// values, mentioning that the given alternative could be set instead.
+func missingSettings(alternative string, group ...setting) []string {
+	var missing []string
+
+	for _, s := range group {
+		if s.value == "" {
+			missing = append(missing, describeSetting(s.envVar))
+		}
+
+		if s.value == "" && s.envVar == EnvVarPass {
+			missing[len(missing)-1] += " (or " + describeSetting(EnvVarPassFile) +
+				" or " + describeSetting(EnvVarPassCommand) + ")"
+		}
+	}
+
+	if len(missing) > 0 {
+		missing[len(missing)-1] += " (or instead set " + describeSetting(alternative) + ")"
+	}
+
+	return missing
+}
+
+// describeSetting returns the given env var name along with its config file
+// key.
+func describeSetting(envVar string) string {
+	return fmt.Sprintf("%s [%s]", envVar, SettingKey(envVar))
+}
+
+func parseTimeout(timeout string) (time.Duration, error) {
+	if timeout == "" {
+		return 0, nil
+	}
 
-	if cred == "" || sheet == "" || user == "" || pass == "" || host == "" || port == "" || dbname == "" {
-		return nil, ErrMissingEnvs
+	d, err := time.ParseDuration(timeout)
+	if err != nil || d < 0 {
+		return 0, ErrInvalidTimeout
 	}
 
-	return &Config{
-		CredentialsPath: cred,
-		SheetID:         sheet,
-		User:            user,
-		Password:        pass,
-		Host:            host,
-		Port:            port,
-		DBName:          dbname,
-	}, nil
+	return d, nil
 }
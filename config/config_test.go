@@ -27,8 +27,11 @@
 package config
 
 import (
+	"errors"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	. "github.com/smartystreets/goconvey/convey"
 )
@@ -36,6 +39,9 @@ import (
 const filePerm = 0644
 
 func TestConfig(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
 	Convey("Given a full set of env vars, you can make a config", t, func() {
 		testPath := "/path"
 		testSheetID := "sheetid"
@@ -67,13 +73,15 @@ func TestConfig(t *testing.T) {
 		Convey("Without a full set of env vars, ConfigFromEnv fails", func() {
 			os.Setenv(EnvVarUser, "")
 			config, err := FromEnv()
-			So(err, ShouldEqual, ErrMissingEnvs)
+			So(errors.Is(err, ErrMissingEnvs), ShouldBeTrue)
+			So(err.Error(), ShouldEqual, "missing required settings: "+EnvVarUser+
+				" [sql_user] (or instead set "+EnvVarSQLite+" [sqlite_db])")
 			So(config, ShouldBeNil)
 
 			os.Setenv(EnvVarUser, "user")
 			os.Setenv(EnvVarCreds, "")
 			config, err = FromEnv()
-			So(err, ShouldEqual, ErrMissingEnvs)
+			So(errors.Is(err, ErrMissingEnvs), ShouldBeTrue)
 			So(config, ShouldBeNil)
 		})
 
@@ -92,7 +100,7 @@ func TestConfig(t *testing.T) {
 			So(err, ShouldBeNil)
 
 			config, err := FromEnv()
-			So(err, ShouldEqual, ErrMissingEnvs)
+			So(errors.Is(err, ErrMissingEnvs), ShouldBeTrue)
 			So(config, ShouldBeNil)
 
 			err = os.WriteFile(".env",
@@ -105,5 +113,203 @@ func TestConfig(t *testing.T) {
 			So(config.CredentialsPath, ShouldEqual, testPath)
 			So(config.DBName, ShouldEqual, testDBName)
 		})
+
+		Convey("You can optionally set a query timeout", func() {
+			So(config.QueryTimeout, ShouldEqual, 0)
+
+			os.Setenv(EnvVarSQLTimeout, "90s")
+
+			defer os.Unsetenv(EnvVarSQLTimeout)
+
+			config, err := FromEnv()
+			So(err, ShouldBeNil)
+			So(config.QueryTimeout, ShouldEqual, 90*time.Second)
+
+			os.Setenv(EnvVarSQLTimeout, "90")
+			config, err = FromEnv()
+			So(err, ShouldEqual, ErrInvalidTimeout)
+			So(config, ShouldBeNil)
+		})
+
+		Convey("You can optionally set a sheet layout file", func() {
+			So(config.LayoutPath, ShouldBeBlank)
+
+			os.Setenv(EnvVarLayout, "/path/to/layout.yml")
+
+			defer os.Unsetenv(EnvVarLayout)
+
+			config, err := FromEnv()
+			So(err, ShouldBeNil)
+			So(config.LayoutPath, ShouldEqual, "/path/to/layout.yml")
+		})
+
+		Convey("With a spreadsheet file path, the Google env vars are not required", func() {
+			os.Setenv(EnvVarCreds, "")
+			os.Setenv(EnvVarSheet, "")
+			os.Setenv(EnvVarSheetFile, "/path/to/metadata.xlsx")
+
+			defer os.Unsetenv(EnvVarSheetFile)
+
+			config, err := FromEnv()
+			So(err, ShouldBeNil)
+			So(config.SheetPath, ShouldEqual, "/path/to/metadata.xlsx")
+
+			os.Setenv(EnvVarUser, "")
+			config, err = FromEnv()
+			So(errors.Is(err, ErrMissingEnvs), ShouldBeTrue)
+			So(config, ShouldBeNil)
+		})
+
+		Convey("With a SQLite database path, the SQL env vars are not required", func() {
+			os.Setenv(EnvVarUser, "")
+			os.Setenv(EnvVarSQLite, "/path/to/mlwh.db")
+
+			defer os.Unsetenv(EnvVarSQLite)
+
+			config, err := FromEnv()
+			So(err, ShouldBeNil)
+			So(config.SQLitePath, ShouldEqual, "/path/to/mlwh.db")
+
+			os.Setenv(EnvVarCreds, "")
+			config, err = FromEnv()
+			So(errors.Is(err, ErrMissingEnvs), ShouldBeTrue)
+			So(config, ShouldBeNil)
+
+			Convey("unless only the MLWH settings are required", func() {
+				config, err = Load(Options{Require: RequireMLWH})
+				So(err, ShouldBeNil)
+				So(config.SQLitePath, ShouldEqual, "/path/to/mlwh.db")
+			})
+		})
+
+		Convey("Only the settings needed by the given requirement are reported missing", func() {
+			os.Setenv(EnvVarCreds, "")
+			os.Setenv(EnvVarUser, "")
+			os.Setenv(EnvVarHost, "")
+
+			_, err := Load(Options{Require: RequireSheet})
+			So(err.Error(), ShouldEqual, "missing required settings: "+EnvVarCreds+
+				" [credentials_file] (or instead set "+EnvVarSheetFile+" [spreadsheet_file])")
+
+			_, err = Load(Options{Require: RequireAll})
+
+			var mse *MissingSettingsError
+			So(errors.As(err, &mse), ShouldBeTrue)
+			So(mse.Missing, ShouldHaveLength, 3)
+			So(mse.Missing[1], ShouldEqual, EnvVarUser+" [sql_user]")
+
+			_, err = Load(Options{})
+			So(err, ShouldBeNil)
+		})
+	})
+
+	Convey("Given a config file with profiles", t, func() {
+		for _, envVar := range settings {
+			t.Setenv(envVar, "")
+		}
+
+		path := filepath.Join(t.TempDir(), "config.yml")
+		err := os.WriteFile(path, []byte(`default_profile: prod
+profiles:
+  prod:
+    credentials_file: /prod/creds.json
+    spreadsheet_id: prodsheet
+    sql_user: produser
+    sql_pass: prodpass
+    sql_host: prodhost
+    sql_port: 3306
+    sql_db: proddb
+    sql_timeout: 90s
+  offline:
+    sqlite_db: /path/to/mlwh.db
+    spreadsheet_file: /path/to/metadata.xlsx
+`), secretPerm)
+		So(err, ShouldBeNil)
+
+		Convey("You can load the default profile", func() {
+			config, err := Load(Options{File: path, Require: RequireAll})
+			So(err, ShouldBeNil)
+			So(config.Profile, ShouldEqual, "prod")
+			So(config.CredentialsPath, ShouldEqual, "/prod/creds.json")
+			So(config.SheetID, ShouldEqual, "prodsheet")
+			So(config.User, ShouldEqual, "produser")
+			So(config.Port, ShouldEqual, "3306")
+			So(config.QueryTimeout, ShouldEqual, 90*time.Second)
+		})
+
+		Convey("You can choose a profile by option or env var", func() {
+			config, err := Load(Options{File: path, Profile: "offline", Require: RequireAll})
+			So(err, ShouldBeNil)
+			So(config.Profile, ShouldEqual, "offline")
+			So(config.SQLitePath, ShouldEqual, "/path/to/mlwh.db")
+			So(config.User, ShouldBeBlank)
+
+			os.Setenv(EnvVarConfig, path)
+			os.Setenv(EnvVarProfile, "offline")
+
+			defer os.Unsetenv(EnvVarConfig)
+			defer os.Unsetenv(EnvVarProfile)
+
+			config, err = Load(Options{Require: RequireAll})
+			So(err, ShouldBeNil)
+			So(config.SheetPath, ShouldEqual, "/path/to/metadata.xlsx")
+
+			_, err = Load(Options{Profile: "missing"})
+			So(errors.Is(err, ErrUnknownProfile), ShouldBeTrue)
+		})
+
+		Convey("Env vars override profile settings", func() {
+			os.Setenv(EnvVarUser, "envuser")
+
+			defer os.Unsetenv(EnvVarUser)
+
+			config, err := Load(Options{File: path, Require: RequireAll})
+			So(err, ShouldBeNil)
+			So(config.User, ShouldEqual, "envuser")
+			So(config.Password, ShouldEqual, "prodpass")
+		})
+
+		Convey("The default config file is used if it exists", func() {
+			dir, err := os.UserConfigDir()
+			So(err, ShouldBeNil)
+
+			defaultPath := filepath.Join(dir, defaultConfigDir, defaultConfigFile)
+			So(os.MkdirAll(filepath.Dir(defaultPath), 0755), ShouldBeNil)
+
+			data, err := os.ReadFile(path)
+			So(err, ShouldBeNil)
+			So(os.WriteFile(defaultPath, data, secretPerm), ShouldBeNil)
+
+			defer os.Remove(defaultPath)
+
+			config, err := Load(Options{Require: RequireAll})
+			So(err, ShouldBeNil)
+			So(config.SheetID, ShouldEqual, "prodsheet")
+		})
+
+		Convey("Missing settings of a profile are reported", func() {
+			_, err := Load(Options{File: path, Profile: "offline", Require: RequireAll})
+			So(err, ShouldBeNil)
+
+			err = os.WriteFile(path, []byte("profiles:\n  a:\n    sql_user: u\n  b:\n    sql_user: v\n"), filePerm)
+			So(err, ShouldBeNil)
+
+			_, err = Load(Options{File: path})
+			So(errors.Is(err, ErrNoProfile), ShouldBeTrue)
+
+			_, err = Load(Options{File: path, Profile: "a", Require: RequireMLWH})
+			So(errors.Is(err, ErrMissingEnvs), ShouldBeTrue)
+			So(err.Error(), ShouldNotContainSubstring, EnvVarUser)
+			So(err.Error(), ShouldContainSubstring, EnvVarPass+" [sql_pass]")
+		})
+
+		Convey("Unknown settings are an error", func() {
+			err := os.WriteFile(path, []byte("profiles:\n  a:\n    sql_usr: u\n"), filePerm)
+			So(err, ShouldBeNil)
+
+			_, err = Load(Options{File: path})
+			So(errors.Is(err, ErrUnknownSetting), ShouldBeTrue)
+			So(err.Error(), ShouldContainSubstring, "sql_usr")
+		})
 	})
 }
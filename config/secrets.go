@@ -0,0 +1,151 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Authors:
+ *	- Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+)
+
+const (
+	ErrSecretFileReadable = Error("secret file is readable by everyone")
+	ErrPasswordCommand    = Error(EnvVarPassCommand + " failed")
+
+	// Redacted replaces the values of secrets, see IsSecretKey().
+	Redacted = "[REDACTED]"
+
+	worldReadable fs.FileMode = 0o004
+)
+
+// ReadSecretFile returns the contents of the given file, which holds a secret
+// such as a password or service account credentials. Returns an
+// ErrSecretFileReadable error without reading it if the file's permissions
+// allow anyone to read it.
+func ReadSecretFile(path string) ([]byte, error) {
+	if err := checkSecretFile(path); err != nil {
+		return nil, err
+	}
+
+	return os.ReadFile(path)
+}
+
+// checkSecretFile returns an ErrSecretFileReadable error if the given file's
+// permissions allow anyone to read it.
+func checkSecretFile(path string) error {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	if fi.Mode().Perm()&worldReadable != 0 {
+		return fmt.Errorf("%w: %s has mode %s; run chmod o-r on it",
+			ErrSecretFileReadable, path, fi.Mode().Perm())
+	}
+
+	return nil
+}
+
+// secretSettings are the env vars of our settings whose values are secret.
+var secretSettings = []string{EnvVarPass} //nolint:gochecknoglobals
+
+// secretKeyWords are parts of (lower case) key names that suggest the key is
+// for a secret value.
+var secretKeyWords = []string{"pass", "secret", "token"} //nolint:gochecknoglobals
+
+// IsSecretKey returns true if the given key, such as the key of a log context
+// value, is the env var or config file key of a secret setting, or otherwise
+// looks like it names a secret. Values with such keys should be replaced with
+// Redacted before being output.
+func IsSecretKey(key string) bool {
+	if slices.ContainsFunc(secretSettings, func(envVar string) bool {
+		return key == envVar || key == SettingKey(envVar)
+	}) {
+		return true
+	}
+
+	key = strings.ToLower(key)
+
+	return slices.ContainsFunc(secretKeyWords, func(word string) bool { return strings.Contains(key, word) })
+}
+
+// setsSecret returns true if any of the given settings, keyed on env var, is a
+// non-blank secret.
+func setsSecret(values map[string]string) bool {
+	return slices.ContainsFunc(secretSettings, func(envVar string) bool { return values[envVar] != "" })
+}
+
+// resolvePassword sets our Password, if not already set, to the contents of
+// the passwordFile, or failing that, the output of passwordCommand. Trailing
+// newlines are removed.
+func (c *Config) resolvePassword(passwordFile, passwordCommand string) error {
+	var (
+		pass []byte
+		err  error
+	)
+
+	switch {
+	case c.Password != "":
+		return nil
+	case passwordFile != "":
+		pass, err = ReadSecretFile(passwordFile)
+	case passwordCommand != "":
+		pass, err = runPasswordCommand(passwordCommand)
+	default:
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	c.Password = strings.TrimRight(string(pass), "\r\n")
+
+	return nil
+}
+
+// runPasswordCommand runs the given command line with sh and returns what it
+// printed to STDOUT. Its STDERR is passed through, so it can prompt the user.
+// The output is not included in any returned error.
+func runPasswordCommand(command string) ([]byte, error) {
+	var stdout bytes.Buffer
+
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrPasswordCommand, err)
+	}
+
+	return stdout.Bytes(), nil
+}
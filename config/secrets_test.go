@@ -0,0 +1,174 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Authors:
+ *	- Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const (
+	secretPerm   = 0600
+	readablePerm = 0604
+)
+
+func TestSecrets(t *testing.T) {
+	Convey("ReadSecretFile refuses world-readable files", t, func() {
+		path := filepath.Join(t.TempDir(), "secret")
+		So(os.WriteFile(path, []byte("s3cret\n"), secretPerm), ShouldBeNil)
+
+		data, err := ReadSecretFile(path)
+		So(err, ShouldBeNil)
+		So(string(data), ShouldEqual, "s3cret\n")
+
+		So(os.Chmod(path, readablePerm), ShouldBeNil)
+
+		_, err = ReadSecretFile(path)
+		So(errors.Is(err, ErrSecretFileReadable), ShouldBeTrue)
+		So(err.Error(), ShouldNotContainSubstring, "s3cret")
+
+		_, err = ReadSecretFile(path + ".missing")
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Given MLWH settings without a password", t, func() {
+		for _, envVar := range settings {
+			t.Setenv(envVar, "")
+		}
+
+		t.Setenv(EnvVarUser, "user")
+		t.Setenv(EnvVarHost, "host")
+		t.Setenv(EnvVarPort, "1234")
+		t.Setenv(EnvVarDBName, "db")
+
+		_, err := Load(Options{Require: RequireMLWH})
+		So(errors.Is(err, ErrMissingEnvs), ShouldBeTrue)
+		So(err.Error(), ShouldContainSubstring, EnvVarPass+" [sql_pass] (or "+EnvVarPassFile+
+			" [password_file] or "+EnvVarPassCommand+" [password_command])")
+
+		Convey("You can read it from a file that isn't world-readable", func() {
+			path := filepath.Join(t.TempDir(), "pass")
+			So(os.WriteFile(path, []byte("filepass\n"), secretPerm), ShouldBeNil)
+
+			os.Setenv(EnvVarPassFile, path)
+
+			defer os.Unsetenv(EnvVarPassFile)
+
+			c, err := Load(Options{Require: RequireMLWH})
+			So(err, ShouldBeNil)
+			So(c.Password, ShouldEqual, "filepass")
+
+			So(os.Chmod(path, readablePerm), ShouldBeNil)
+
+			_, err = Load(Options{Require: RequireMLWH})
+			So(errors.Is(err, ErrSecretFileReadable), ShouldBeTrue)
+
+			_, err = Load(Options{Require: RequireSheet})
+			So(errors.Is(err, ErrSecretFileReadable), ShouldBeFalse)
+		})
+
+		Convey("You can get it from a command", func() {
+			os.Setenv(EnvVarPassCommand, "echo cmdpass")
+
+			defer os.Unsetenv(EnvVarPassCommand)
+
+			c, err := Load(Options{Require: RequireMLWH})
+			So(err, ShouldBeNil)
+			So(c.Password, ShouldEqual, "cmdpass")
+
+			os.Setenv(EnvVarPass, "envpass")
+
+			defer os.Unsetenv(EnvVarPass)
+
+			c, err = Load(Options{Require: RequireMLWH})
+			So(err, ShouldBeNil)
+			So(c.Password, ShouldEqual, "envpass")
+
+			os.Unsetenv(EnvVarPass)
+			os.Setenv(EnvVarPassCommand, "echo cmdpass; exit 1")
+
+			_, err = Load(Options{Require: RequireMLWH})
+			So(errors.Is(err, ErrPasswordCommand), ShouldBeTrue)
+			So(err.Error(), ShouldNotContainSubstring, "cmdpass")
+		})
+	})
+
+	Convey("IsSecretKey recognises keys that name secrets", t, func() {
+		So(IsSecretKey(EnvVarPass), ShouldBeTrue)
+		So(IsSecretKey("sql_pass"), ShouldBeTrue)
+		So(IsSecretKey("Password"), ShouldBeTrue)
+		So(IsSecretKey("api_token"), ShouldBeTrue)
+		So(IsSecretKey("client_secret"), ShouldBeTrue)
+		So(IsSecretKey(EnvVarUser), ShouldBeFalse)
+		So(IsSecretKey("sql_user"), ShouldBeFalse)
+		So(IsSecretKey("sample"), ShouldBeFalse)
+	})
+
+	Convey("Files that set the password must not be world-readable", t, func() {
+		for _, envVar := range settings {
+			t.Setenv(envVar, "")
+			os.Unsetenv(envVar)
+		}
+
+		dir := t.TempDir()
+		path := filepath.Join(dir, "config.yml")
+		So(os.WriteFile(path, []byte("profiles:\n  a:\n    sql_user: u\n  b:\n    sql_pass: s3cret\n"),
+			readablePerm), ShouldBeNil)
+
+		_, err := Load(Options{File: path, Profile: "a"})
+		So(errors.Is(err, ErrSecretFileReadable), ShouldBeTrue)
+		So(err.Error(), ShouldNotContainSubstring, "s3cret")
+
+		So(os.Chmod(path, secretPerm), ShouldBeNil)
+
+		c, err := Load(Options{File: path, Profile: "b"})
+		So(err, ShouldBeNil)
+		So(c.Password, ShouldEqual, "s3cret")
+
+		So(os.WriteFile(path, []byte("profiles:\n  a:\n    sql_user: u\n"), readablePerm), ShouldBeNil)
+
+		_, err = Load(Options{File: path})
+		So(err, ShouldBeNil)
+
+		envPath := filepath.Join(dir, ".env")
+		So(os.WriteFile(envPath, []byte(EnvVarPass+"=envs3cret\n"), readablePerm), ShouldBeNil)
+
+		_, err = Load(Options{Dir: dir})
+		So(errors.Is(err, ErrSecretFileReadable), ShouldBeTrue)
+		So(os.Getenv(EnvVarPass), ShouldBeBlank)
+
+		So(os.Chmod(envPath, secretPerm), ShouldBeNil)
+
+		c, err = Load(Options{Dir: dir})
+		So(err, ShouldBeNil)
+		So(c.Password, ShouldEqual, "envs3cret")
+	})
+}
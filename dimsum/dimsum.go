@@ -29,9 +29,12 @@ package dimsum
 import (
 	"crypto/sha1"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 
@@ -45,6 +48,7 @@ func (e Error) Error() string { return string(e) }
 
 const (
 	ErrMultipleExperiments = Error("multiple experiments in samples")
+	ErrNoOutputs           = Error("DiMSum wrote no output files")
 
 	DefaultVsearchMinQual          = 20
 	DefaultStartStage              = 0
@@ -73,6 +77,8 @@ const (
 	cutAdaptRequired    = ":required..."
 	cutAdaptOptional    = ":optional"
 	dimsumProjectPrefix = "dimsumRun_"
+	keySampleSeparator  = ","
+	shellSafeChars      = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_-+=.,:/@%"
 )
 
 // ExperimentDesign represents a single experiment's metadata.
@@ -152,6 +158,7 @@ type DimSum struct {
 	MutagenesisType         string  // Type of mutagenesis
 	RetainIntermediateFiles bool    // Whether to retain intermediate files
 	DesignPairDuplicates    bool    // Whether to design pair duplicates
+	BarcodeIdentityPath     string  // Overrides the experiment's barcodeIdentityPath
 }
 
 // New creates a new DimSum instance with default values for the properties not
@@ -195,14 +202,14 @@ func (d *DimSum) Key(samples []*types.Sample) string {
 	sampleInfo := make([]string, len(samples))
 
 	for i, sample := range samples {
-		sampleInfo[i] = fmt.Sprintf("%s.%s", sample.SampleName, sample.RunID)
+		sampleInfo[i] = sample.Key()
 	}
 
 	sort.Strings(sampleInfo)
 
 	// TODO: include more/all of the properties in the key
 	combinedProps := fmt.Sprintf("%s_%d_%d_%d_%d_%d_%.2f_%d_%t_%s_%t",
-		d.ed.BarcodeIdentityPath, d.VSearchMinQual, d.StartStage,
+		d.barcodeIdentityPath(), d.VSearchMinQual, d.StartStage,
 		d.FitnessMinInputCountAny, d.FitnessMinInputCountAll,
 		d.CutAdaptMinLength, d.CutAdaptErrorRate, d.MaxSubstitutions,
 		d.MixedSubstitutions, d.MutagenesisType, d.DesignPairDuplicates)
@@ -211,7 +218,42 @@ func (d *DimSum) Key(samples []*types.Sample) string {
 	hasher.Write([]byte(combinedProps))
 	encodedProps := hex.EncodeToString(hasher.Sum(nil))
 
-	return filepath.Join(d.ed.Experiment.ExperimentID, strings.Join(sampleInfo, ","), encodedProps)
+	return filepath.Join(d.ed.Experiment.ExperimentID, strings.Join(sampleInfo, keySampleSeparator), encodedProps)
+}
+
+// HasOutput returns true if the given output directory (the one supplied to
+// dimsum runs, that Key()s are relative to) contains a non-empty output
+// directory for a run of the given experiment that included the given sample
+// run.
+func HasOutput(outputDir, experimentID string, s *types.Sample) bool {
+	dirs, err := filepath.Glob(filepath.Join(outputDir, experimentID, "*", "*"))
+	if err != nil {
+		return false
+	}
+
+	for _, dir := range dirs {
+		samples := strings.Split(filepath.Base(filepath.Dir(dir)), keySampleSeparator)
+		if !slices.Contains(samples, s.Key()) {
+			continue
+		}
+
+		if entries, err := os.ReadDir(dir); err == nil && len(entries) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// KeyChanged returns true if a DiMSum run of the current experiment would have a
+// different Key() to the same run (same samples and options) of the old
+// experiment, ie. if the experiment's changes affect any of the properties
+// that Key() encodes. If not, a new run would reuse the old run's outputs.
+func KeyChanged(old, current *types.Experiment) bool {
+	oldD := New("", ExperimentDesign{Experiment: old})
+	currentD := New("", ExperimentDesign{Experiment: current})
+
+	return filepath.Base(oldD.Key(nil)) != filepath.Base(currentD.Key(nil))
 }
 
 // TODO: make Key be an explicit initial "temp" output path method that returns
@@ -230,6 +272,13 @@ func (d *DimSum) Command() (string, error) {
 		return "", err
 	}
 
+	return d.CommandLine(), nil
+}
+
+// CommandLine is like Command(), but doesn't create the "outputs"
+// subdirectory, so is useful for seeing what would be run. Values that didn't
+// come from us are quoted so that the shell doesn't interpret them.
+func (d *DimSum) CommandLine() string {
 	libMeta := d.ed.Experiment
 
 	cmd := fmt.Sprintf("%s -i %s -l %s -g %s -e %s --cutadapt5First %s --cutadapt5Second %s "+
@@ -237,23 +286,92 @@ func (d *DimSum) Command() (string, error) {
 		"--fitnessMinInputCountAny %d --fitnessMinInputCountAll %d "+
 		"--maxSubstitutions %d --mutagenesisType %s --retainIntermediateFiles %s "+
 		"--mixedSubstitutions %s --experimentDesignPairDuplicates %s",
-		DimSumExe, d.FastqDir, d.FastqExtension, "T", experimentDesignPath(".", d.ed.ExperimentID),
-		libMeta.Cutadapt5First,
-		libMeta.Cutadapt5Second,
+		DimSumExe, shellQuote(d.FastqDir), shellQuote(d.FastqExtension), "T",
+		shellQuote(experimentDesignPath(".", d.ed.ExperimentID)),
+		shellQuote(libMeta.Cutadapt5First),
+		shellQuote(libMeta.Cutadapt5Second),
 		d.CutAdaptMinLength, d.CutAdaptErrorRate,
-		d.VSearchMinQual, outputSubdir, dimsumProjectPrefix+d.ed.ExperimentID,
-		d.StartStage, libMeta.WildtypeSequence, d.Cores, d.FitnessMinInputCountAny,
+		d.VSearchMinQual, outputSubdir, shellQuote(dimsumProjectPrefix+d.ed.ExperimentID),
+		d.StartStage, shellQuote(libMeta.WildtypeSequence), d.Cores, d.FitnessMinInputCountAny,
 		d.FitnessMinInputCountAll, d.MaxSubstitutions,
-		d.MutagenesisType, "T", boolToLetter(libMeta.MixedSubstitutions),
+		shellQuote(d.MutagenesisType), "T", boolToLetter(libMeta.MixedSubstitutions),
 		boolToLetter(libMeta.ExperimentDesignPairDuplicates),
 		//TODO: all other dimsum params on libMeta
 	)
 
-	if d.ed.BarcodeIdentityPath != "" {
-		cmd += " --barcodeIdentityPath " + d.ed.BarcodeIdentityPath
+	if bip := d.barcodeIdentityPath(); bip != "" {
+		cmd += " --barcodeIdentityPath " + shellQuote(bip)
+	}
+
+	return cmd
+}
+
+// barcodeIdentityPath returns our BarcodeIdentityPath if set, otherwise our
+// experiment's.
+func (d *DimSum) barcodeIdentityPath() string {
+	if d.BarcodeIdentityPath != "" {
+		return d.BarcodeIdentityPath
+	}
+
+	return d.ed.BarcodeIdentityPath
+}
+
+// shellQuote returns the given value as is if a shell wouldn't interpret any of
+// it, otherwise in single quotes.
+func shellQuote(value string) string {
+	if value != "" && strings.IndexFunc(value, func(r rune) bool {
+		return !strings.ContainsRune(shellSafeChars, r)
+	}) < 0 {
+		return value
+	}
+
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// ProjectDir returns the path, relative to the current working directory, of
+// the directory that the Command() will write its output files to.
+func (d *DimSum) ProjectDir() string {
+	return filepath.Join(outputSubdir, dimsumProjectPrefix+d.ed.ExperimentID)
+}
+
+// CollectOutputs moves the output files that Command() wrote to ProjectDir() in
+// to the given existing directory (eg. one named after our Key()), so that the
+// next run doesn't overwrite them. If keep is true, they are copied instead,
+// so that the next run can share their intermediate files (see
+// ShareIntermediates()).
+//
+// Returns ErrNoOutputs if ProjectDir() is missing or empty.
+func (d *DimSum) CollectOutputs(dest string, keep bool) error {
+	src := d.ProjectDir()
+
+	entries, err := os.ReadDir(src)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	if len(entries) == 0 {
+		return fmt.Errorf("%w in %s", ErrNoOutputs, src)
+	}
+
+	if !keep {
+		for _, entry := range entries {
+			if err = os.Rename(filepath.Join(src, entry.Name()), filepath.Join(dest, entry.Name())); err != nil {
+				break
+			}
+		}
+
+		if err == nil {
+			return os.Remove(src)
+		}
+	}
+
+	// copy anything we couldn't rename, eg. because dest is on a different
+	// filesystem
+	if err = os.CopyFS(dest, os.DirFS(src)); err != nil || keep {
+		return err
 	}
 
-	return cmd, nil
+	return os.RemoveAll(src)
 }
 
 func boolToLetter(b bool) string {
@@ -143,6 +143,9 @@ func TestDimsum(t *testing.T) {
 				So(dimsum, ShouldNotBeNil)
 
 				So(dimsum.Key(testSamples), ShouldEqual, "exp/sample1.run,sample2.run/69b24c9009b4933a204a8d2aace78d566eb8b31b")
+				So(dimsum.ProjectDir(), ShouldEqual, filepath.Join(outputSubdir, dimsumProjectPrefix+exp.ExperimentID))
+
+				cmdLine := dimsum.CommandLine()
 
 				cmd, err := dimsum.Command()
 				So(err, ShouldBeNil)
@@ -165,6 +168,7 @@ func TestDimsum(t *testing.T) {
 
 				_, err = os.Stat(outputSubdir)
 				So(err, ShouldBeNil)
+				So(cmdLine, ShouldEqual, cmd)
 
 				dimsum = New(fastqDir, design)
 				So(dimsum, ShouldNotBeNil)
@@ -174,6 +178,54 @@ func TestDimsum(t *testing.T) {
 				So(err, ShouldBeNil)
 				So(cmd, ShouldNotContainSubstring, "--barcodeIdentityPath")
 				So(dimsum.Key(testSamples), ShouldEqual, "exp/sample1.run,sample2.run/631c90f196443c203f4eeea856da242fafcc1793")
+
+				dimsum.BarcodeIdentityPath = "/other/bi.txt"
+				cmd, err = dimsum.Command()
+				So(err, ShouldBeNil)
+				So(cmd, ShouldContainSubstring, " --barcodeIdentityPath /other/bi.txt")
+				So(dimsum.Key(testSamples), ShouldNotEqual, "exp/sample1.run,sample2.run/631c90f196443c203f4eeea856da242fafcc1793")
+			})
+
+			Convey("Values in the command line are quoted if the shell would interpret them", func() {
+				d := New("/fastqs;touch x", design)
+				d.MutagenesisType = "random$(id)"
+				exp.WildtypeSequence = "AC'GT"
+
+				cmd := d.CommandLine()
+				So(cmd, ShouldStartWith, DimSumExe+" -i '/fastqs;touch x' -l .fastq ")
+				So(cmd, ShouldContainSubstring, " --mutagenesisType 'random$(id)' ")
+				So(cmd, ShouldContainSubstring, ` -w 'AC'\''GT' `)
+
+				So(shellQuote("/a/b_c-1.fastq"), ShouldEqual, "/a/b_c-1.fastq")
+				So(shellQuote(""), ShouldEqual, "''")
+			})
+
+			Convey("You can tell if a sample run has outputs in a directory", func() {
+				outputDir := t.TempDir()
+				So(HasOutput(outputDir, exp.ExperimentID, testSamples[0]), ShouldBeFalse)
+
+				d := New("", design)
+				keyDir := filepath.Join(outputDir, d.Key(testSamples[:1]))
+				So(os.MkdirAll(keyDir, 0700), ShouldBeNil)
+				So(HasOutput(outputDir, exp.ExperimentID, testSamples[0]), ShouldBeFalse)
+
+				So(os.WriteFile(filepath.Join(keyDir, "out.txt"), []byte("done"), 0600), ShouldBeNil)
+				So(HasOutput(outputDir, exp.ExperimentID, testSamples[0]), ShouldBeTrue)
+				So(HasOutput(outputDir, exp.ExperimentID, testSamples[1]), ShouldBeFalse)
+				So(HasOutput(outputDir, "other", testSamples[0]), ShouldBeFalse)
+			})
+
+			Convey("You can tell if changes to an experiment change its Key", func() {
+				changed := exp.Clone(nil)
+				changed.Cutadapt5First = "TTTT"
+				So(KeyChanged(exp, changed), ShouldBeFalse)
+
+				changed.BarcodeIdentityPath = "/other/path"
+				So(KeyChanged(exp, changed), ShouldBeTrue)
+
+				changed = exp.Clone(nil)
+				changed.MaxSubstitutions = 4
+				So(KeyChanged(exp, changed), ShouldBeTrue)
 			})
 		})
 	})
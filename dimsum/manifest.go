@@ -0,0 +1,122 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Authors:
+ *	- Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package dimsum
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const (
+	// ManifestsDir is the sub-directory of a dimsum output directory that
+	// WriteManifest() writes to.
+	ManifestsDir = ".manifests"
+
+	manifestTimeFormat = "20060102-150405.000000"
+	manifestSuffix     = ".json"
+	manifestPerm       = 0644
+	manifestsDirPerm   = 0755
+)
+
+// Manifest records a DiMSum run, for keeping a history of runs.
+type Manifest struct {
+	ExperimentID string            `json:"experiment_id"`
+	Samples      []string          `json:"samples"`
+	Sweep        map[string]string `json:"sweep,omitempty"`
+	FastqDir     string            `json:"fastq_dir"`
+	OutputDir    string            `json:"output_dir"`
+	Command      string            `json:"command"`
+	State        string            `json:"state"`
+	Error        string            `json:"error,omitempty"`
+	Started      time.Time         `json:"started"`
+	Finished     time.Time         `json:"finished"`
+}
+
+// WriteManifest writes the given Manifest as a JSON file in the ManifestsDir
+// sub-directory of the given output directory (the one supplied to dimsum
+// runs, that Key()s are relative to), returning its path.
+func WriteManifest(outputDir string, m *Manifest) (string, error) {
+	dir := filepath.Join(outputDir, ManifestsDir)
+	if err := os.MkdirAll(dir, manifestsDirPerm); err != nil {
+		return "", err
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, m.Started.Format(manifestTimeFormat)+"_"+m.ExperimentID+"_"+
+		filepath.Base(m.OutputDir)+manifestSuffix)
+
+	return path, os.WriteFile(path, data, manifestPerm)
+}
+
+// ReadManifests reads the Manifests written by WriteManifest() to the given
+// output directory, returning them most recently started first. If no
+// manifests have been written, returns none.
+func ReadManifests(outputDir string) ([]*Manifest, error) {
+	paths, err := filepath.Glob(filepath.Join(outputDir, ManifestsDir, "*"+manifestSuffix))
+	if err != nil {
+		return nil, err
+	}
+
+	ms := make([]*Manifest, 0, len(paths))
+
+	for _, path := range paths {
+		m, err := readManifest(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		ms = append(ms, m)
+	}
+
+	sort.SliceStable(ms, func(i, j int) bool {
+		return ms[i].Started.After(ms[j].Started)
+	})
+
+	return ms, nil
+}
+
+func readManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Manifest{}
+
+	return m, json.Unmarshal(data, m)
+}
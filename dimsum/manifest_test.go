@@ -0,0 +1,91 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Authors:
+ *	- Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package dimsum
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestManifests(t *testing.T) {
+	Convey("Given an output directory", t, func() {
+		dir := t.TempDir()
+
+		Convey("With no manifests, you read none", func() {
+			ms, err := ReadManifests(dir)
+			So(err, ShouldBeNil)
+			So(ms, ShouldBeEmpty)
+		})
+
+		Convey("You can write manifests and read them back, most recent first", func() {
+			started := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+			older := &Manifest{
+				ExperimentID: "exp1",
+				Samples:      []string{"s1:1"},
+				OutputDir:    filepath.Join(dir, "exp1", "s1.1", "abc"),
+				Command:      "DiMSum ...",
+				State:        "failed",
+				Error:        "oops",
+				Started:      started,
+				Finished:     started.Add(time.Minute),
+			}
+
+			newer := &Manifest{
+				ExperimentID: "exp1",
+				Samples:      []string{"s1:1"},
+				Sweep:        map[string]string{"vsearchMinQual": "30"},
+				OutputDir:    filepath.Join(dir, "exp1", "s1.1", "def"),
+				State:        "complete",
+				Started:      started.Add(time.Hour),
+				Finished:     started.Add(2 * time.Hour),
+			}
+
+			path, err := WriteManifest(dir, older)
+			So(err, ShouldBeNil)
+			So(path, ShouldEqual, filepath.Join(dir, ManifestsDir, "20250102-030405.000000_exp1_abc.json"))
+
+			_, err = WriteManifest(dir, newer)
+			So(err, ShouldBeNil)
+
+			ms, err := ReadManifests(dir)
+			So(err, ShouldBeNil)
+			So(ms, ShouldResemble, []*Manifest{newer, older})
+
+			Convey("But not invalid ones", func() {
+				So(os.WriteFile(filepath.Join(dir, ManifestsDir, "bad.json"), []byte("{"), 0600), ShouldBeNil)
+
+				_, err = ReadManifests(dir)
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
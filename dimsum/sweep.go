@@ -0,0 +1,300 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Authors:
+ *	- Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package dimsum
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/wtsi-hgi/dimsum-automation/types"
+)
+
+const (
+	ErrInvalidSweep      = Error("invalid sweep; must be param=value1,value2,...")
+	ErrUnknownSweepParam = Error("unknown sweep parameter")
+	ErrInvalidSweepValue = Error("invalid sweep parameter value")
+
+	// SharedStartStage is the DiMSum stage that runs sharing intermediates
+	// start at, skipping stages 1-3 (demultiplexing, FASTQC and cutadapt
+	// trimming), which only depend on the cutadapt options.
+	SharedStartStage = 4
+
+	sweepSpecSeparator  = "="
+	sweepValueSeparator = ","
+	sweepIndexSeparator = "\t"
+	sweepIndexOutputCol = "output_dir"
+	sweepIndexStateCol  = "state"
+	float32Bits         = 32
+)
+
+// sweepSetters set the DimSum options that can be swept, keyed on the option
+// names DiMSum uses.
+var sweepSetters = map[string]func(d *DimSum, value string) error{ //nolint:gochecknoglobals
+	"vsearchMinQual":          intSetter(func(d *DimSum) *int { return &d.VSearchMinQual }),
+	"fitnessMinInputCountAny": intSetter(func(d *DimSum) *int { return &d.FitnessMinInputCountAny }),
+	"fitnessMinInputCountAll": intSetter(func(d *DimSum) *int { return &d.FitnessMinInputCountAll }),
+	"maxSubstitutions":        intSetter(func(d *DimSum) *int { return &d.MaxSubstitutions }),
+	"cutAdaptMinLength":       intSetter(func(d *DimSum) *int { return &d.CutAdaptMinLength }),
+	"cutAdaptErrorRate": func(d *DimSum, value string) error {
+		f, err := strconv.ParseFloat(value, float32Bits)
+		d.CutAdaptErrorRate = float32(f)
+
+		return err
+	},
+	"mutagenesisType": func(d *DimSum, value string) error {
+		mt, err := types.StringToMutagenesisType(value)
+		d.MutagenesisType = string(mt)
+
+		return err
+	},
+}
+
+// earlySweepParams are the sweep parameters that affect DiMSum stages 1-3.
+var earlySweepParams = []string{"cutAdaptMinLength", "cutAdaptErrorRate"} //nolint:gochecknoglobals
+
+func intSetter(field func(d *DimSum) *int) func(d *DimSum, value string) error {
+	return func(d *DimSum, value string) error {
+		i, err := strconv.Atoi(value)
+		*field(d) = i
+
+		return err
+	}
+}
+
+// SweepParams returns the sorted names of the parameters that can be swept.
+func SweepParams() []string {
+	names := make([]string, 0, len(sweepSetters))
+
+	for name := range sweepSetters {
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+
+	return names
+}
+
+// SweepParam is a DimSum option and the values it should take in a sweep.
+type SweepParam struct {
+	Name   string
+	Values []string
+}
+
+// ParseSweepParam parses a "param=value1,value2,..." string, where param is one
+// of SweepParams(). Repeated values are dropped, and invalid ones are an
+// ErrInvalidSweepValue.
+func ParseSweepParam(spec string) (SweepParam, error) {
+	name, values, ok := strings.Cut(spec, sweepSpecSeparator)
+	if !ok || name == "" || values == "" {
+		return SweepParam{}, fmt.Errorf("%w: %s", ErrInvalidSweep, spec)
+	}
+
+	setter, ok := sweepSetters[name]
+	if !ok {
+		return SweepParam{}, fmt.Errorf("%w: %s (valid: %s)", ErrUnknownSweepParam, name,
+			strings.Join(SweepParams(), ", "))
+	}
+
+	p := SweepParam{Name: name}
+
+	for _, value := range strings.Split(values, sweepValueSeparator) {
+		if slices.Contains(p.Values, value) {
+			continue
+		}
+
+		if err := setter(&DimSum{}, value); err != nil {
+			return SweepParam{}, fmt.Errorf("%w: %s=%s", ErrInvalidSweepValue, name, value)
+		}
+
+		p.Values = append(p.Values, value)
+	}
+
+	return p, nil
+}
+
+// SweepRun is one combination of the values of the parameters in a sweep.
+type SweepRun struct {
+	// Values are the values of the swept parameters, in the order the
+	// parameters were given to Sweep().
+	Values []string
+
+	// DimSum has the options of this combination set.
+	DimSum DimSum
+
+	// OutputDir and State are for the user to record where this combination's
+	// outputs are and how it went, for WriteSweepIndex().
+	OutputDir string
+	State     string
+}
+
+// Settings returns the names of the given parameters (those given to Sweep())
+// mapped to this combination's values for them, or nil if there are none.
+func (s *SweepRun) Settings(params []SweepParam) map[string]string {
+	if len(params) == 0 {
+		return nil
+	}
+
+	settings := make(map[string]string, len(params))
+
+	for i, p := range params {
+		settings[p.Name] = s.Values[i]
+	}
+
+	return settings
+}
+
+// Sweep returns a SweepRun for every combination of the values of the given
+// parameters (their cartesian product), each with a copy of this DimSum with
+// those values set. With no parameters, returns a single SweepRun of this
+// DimSum.
+func (d DimSum) Sweep(params []SweepParam) ([]*SweepRun, error) {
+	runs := []*SweepRun{{DimSum: d}}
+
+	for _, p := range params {
+		next := make([]*SweepRun, 0, len(runs)*len(p.Values))
+
+		for _, run := range runs {
+			for _, value := range p.Values {
+				newD := run.DimSum
+
+				if err := sweepSetters[p.Name](&newD, value); err != nil {
+					return nil, fmt.Errorf("%w: %s=%s", ErrInvalidSweepValue, p.Name, value)
+				}
+
+				next = append(next, &SweepRun{Values: append(slices.Clone(run.Values), value), DimSum: newD})
+			}
+		}
+
+		runs = next
+	}
+
+	return runs, nil
+}
+
+// DistinctRuns splits the given runs (from the same Sweep()) in to those with
+// distinct Key()s for the given samples, and the duplicates whose Key() is the
+// same as an earlier run's (eg. because Key() rounds their different values to
+// the same thing), which would otherwise share that run's output directory.
+func DistinctRuns(runs []*SweepRun, samples []*types.Sample) (distinct, duplicates []*SweepRun) {
+	seen := make(map[string]bool, len(runs))
+
+	for _, run := range runs {
+		key := run.DimSum.Key(samples)
+		if seen[key] {
+			duplicates = append(duplicates, run)
+
+			continue
+		}
+
+		seen[key] = true
+
+		distinct = append(distinct, run)
+	}
+
+	return distinct, duplicates
+}
+
+// ShareIntermediates makes the given runs (from the same Sweep()) share the
+// intermediate files of DiMSum stages 1-3, by having all but the first of those
+// with the same values for the parameters that affect those stages start at
+// SharedStartStage.
+//
+// Returns the runs in the order they must be done, in the same working
+// directory, for this to work: grouped by those values, otherwise in their
+// original order.
+//
+// Note that this changes their Key()s, so get those first.
+func ShareIntermediates(params []SweepParam, runs []*SweepRun) []*SweepRun {
+	var early []int
+
+	for i, p := range params {
+		if slices.Contains(earlySweepParams, p.Name) {
+			early = append(early, i)
+		}
+	}
+
+	groups := make(map[string]int)
+	runGroups := make(map[*SweepRun]int, len(runs))
+
+	for _, run := range runs {
+		earlyValues := make([]string, len(early))
+
+		for i, j := range early {
+			earlyValues[i] = run.Values[j]
+		}
+
+		key := strings.Join(earlyValues, sweepValueSeparator)
+
+		group, seen := groups[key]
+		if !seen {
+			group = len(groups)
+			groups[key] = group
+		} else if run.DimSum.StartStage < SharedStartStage {
+			run.DimSum.StartStage = SharedStartStage
+		}
+
+		runGroups[run] = group
+	}
+
+	ordered := slices.Clone(runs)
+
+	slices.SortStableFunc(ordered, func(a, b *SweepRun) int {
+		return runGroups[a] - runGroups[b]
+	})
+
+	return ordered
+}
+
+// WriteSweepIndex writes a TSV to w with a column for each of the given
+// parameters, followed by output_dir and state columns, and a row for each of
+// the given runs.
+func WriteSweepIndex(w io.Writer, params []SweepParam, runs []*SweepRun) error {
+	header := make([]string, 0, len(params)+2)
+
+	for _, p := range params {
+		header = append(header, p.Name)
+	}
+
+	header = append(header, sweepIndexOutputCol, sweepIndexStateCol)
+
+	if _, err := fmt.Fprintln(w, strings.Join(header, sweepIndexSeparator)); err != nil {
+		return err
+	}
+
+	for _, run := range runs {
+		row := append(slices.Clone(run.Values), run.OutputDir, run.State)
+
+		if _, err := fmt.Fprintln(w, strings.Join(row, sweepIndexSeparator)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
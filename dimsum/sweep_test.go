@@ -0,0 +1,228 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Authors:
+ *	- Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package dimsum
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/wtsi-hgi/dimsum-automation/types"
+)
+
+func TestSweep(t *testing.T) {
+	Convey("You can parse sweep parameters", t, func() {
+		p, err := ParseSweepParam("vsearchMinQual=20,30")
+		So(err, ShouldBeNil)
+		So(p, ShouldResemble, SweepParam{Name: "vsearchMinQual", Values: []string{"20", "30"}})
+
+		_, err = ParseSweepParam("vsearchMinQual")
+		So(err, ShouldWrap, ErrInvalidSweep)
+
+		_, err = ParseSweepParam("vsearchMinQual=")
+		So(err, ShouldWrap, ErrInvalidSweep)
+
+		_, err = ParseSweepParam("cores=1,2")
+		So(err, ShouldWrap, ErrUnknownSweepParam)
+
+		_, err = ParseSweepParam("vsearchMinQual=20,x")
+		So(err, ShouldWrap, ErrInvalidSweepValue)
+
+		p, err = ParseSweepParam("vsearchMinQual=20,30,20")
+		So(err, ShouldBeNil)
+		So(p.Values, ShouldResemble, []string{"20", "30"})
+
+		So(SweepParams(), ShouldContain, "maxSubstitutions")
+	})
+
+	Convey("Given a DimSum and some sweep parameters", t, func() {
+		exp := &types.Experiment{
+			ExperimentID: "exp",
+			Samples:      []*types.Sample{{SampleName: "s1", RunID: "1"}},
+		}
+		d := New("", ExperimentDesign{Experiment: exp})
+
+		params := []SweepParam{
+			{Name: "cutAdaptMinLength", Values: []string{"40", "50"}},
+			{Name: "vsearchMinQual", Values: []string{"20", "30", "40"}},
+			{Name: "mutagenesisType", Values: []string{"codon"}},
+		}
+
+		Convey("You can get a DimSum for every combination", func() {
+			runs, err := d.Sweep(params)
+			So(err, ShouldBeNil)
+			So(runs, ShouldHaveLength, 6)
+
+			So(runs[0].Values, ShouldResemble, []string{"40", "20", "codon"})
+			So(runs[0].DimSum.CutAdaptMinLength, ShouldEqual, 40)
+			So(runs[0].DimSum.VSearchMinQual, ShouldEqual, 20)
+			So(runs[0].DimSum.MutagenesisType, ShouldEqual, "codon")
+			So(runs[5].Values, ShouldResemble, []string{"50", "40", "codon"})
+			So(runs[5].Settings(params), ShouldResemble, map[string]string{
+				"cutAdaptMinLength": "50", "vsearchMinQual": "40", "mutagenesisType": "codon",
+			})
+			So(runs[5].DimSum.CutAdaptMinLength, ShouldEqual, 50)
+			So(runs[5].DimSum.VSearchMinQual, ShouldEqual, 40)
+			So(d.VSearchMinQual, ShouldEqual, DefaultVsearchMinQual)
+
+			keys := make(map[string]bool)
+
+			for _, run := range runs {
+				keys[run.DimSum.Key(exp.Samples)] = true
+			}
+
+			So(keys, ShouldHaveLength, 6)
+
+			Convey("Then make them share intermediates", func() {
+				ordered := ShareIntermediates(params, runs)
+				So(ordered, ShouldResemble, runs)
+
+				starts := make([]int, len(runs))
+
+				for i, run := range runs {
+					starts[i] = run.DimSum.StartStage
+				}
+
+				So(starts, ShouldResemble, []int{
+					DefaultStartStage, SharedStartStage, SharedStartStage,
+					DefaultStartStage, SharedStartStage, SharedStartStage,
+				})
+			})
+
+			Convey("Then make them share intermediates when the early parameters vary fastest", func() {
+				params[0], params[1] = params[1], params[0]
+				runs, err = d.Sweep(params)
+				So(err, ShouldBeNil)
+
+				ordered := ShareIntermediates(params, runs)
+				So(ordered, ShouldHaveLength, 6)
+
+				values := make([]string, len(ordered))
+				starts := make([]int, len(ordered))
+
+				for i, run := range ordered {
+					values[i] = run.Values[0] + ":" + run.Values[1]
+					starts[i] = run.DimSum.StartStage
+				}
+
+				So(values, ShouldResemble, []string{"20:40", "30:40", "40:40", "20:50", "30:50", "40:50"})
+				So(starts, ShouldResemble, []int{
+					DefaultStartStage, SharedStartStage, SharedStartStage,
+					DefaultStartStage, SharedStartStage, SharedStartStage,
+				})
+			})
+
+			Convey("Then collect each one's outputs in to its own directory", func() {
+				origDir, err := os.Getwd()
+				So(err, ShouldBeNil)
+
+				defer os.Chdir(origDir) //nolint:errcheck
+
+				So(os.Chdir(t.TempDir()), ShouldBeNil)
+
+				outputDir := t.TempDir()
+				dests := make([]string, 2)
+
+				for i, run := range runs[:2] {
+					So(os.MkdirAll(filepath.Join(run.DimSum.ProjectDir(), "tmp"), 0700), ShouldBeNil)
+					So(os.WriteFile(filepath.Join(run.DimSum.ProjectDir(), "tmp", "fitness.txt"),
+						[]byte(run.Values[1]), 0600), ShouldBeNil)
+
+					dests[i] = filepath.Join(outputDir, run.DimSum.Key(exp.Samples))
+					So(os.MkdirAll(dests[i], 0700), ShouldBeNil)
+
+					So(run.DimSum.CollectOutputs(dests[i], i == 0), ShouldBeNil)
+				}
+
+				So(dests[0], ShouldNotEqual, dests[1])
+
+				for i, dest := range dests {
+					data, errr := os.ReadFile(filepath.Join(dest, "tmp", "fitness.txt"))
+					So(errr, ShouldBeNil)
+					So(string(data), ShouldEqual, runs[i].Values[1])
+				}
+
+				_, err = os.Stat(runs[0].DimSum.ProjectDir())
+				So(os.IsNotExist(err), ShouldBeTrue)
+
+				err = runs[2].DimSum.CollectOutputs(outputDir, false)
+				So(err, ShouldWrap, ErrNoOutputs)
+			})
+
+			Convey("Then write an index of them", func() {
+				for _, run := range runs {
+					run.OutputDir = "/out/" + run.Values[0] + run.Values[1]
+					run.State = "complete"
+				}
+
+				runs[1].State = "failed"
+
+				var buf bytes.Buffer
+
+				So(WriteSweepIndex(&buf, params, runs[:2]), ShouldBeNil)
+				So(buf.String(), ShouldEqual,
+					"cutAdaptMinLength\tvsearchMinQual\tmutagenesisType\toutput_dir\tstate\n"+
+						"40\t20\tcodon\t/out/4020\tcomplete\n"+
+						"40\t30\tcodon\t/out/4030\tfailed\n")
+			})
+		})
+
+		Convey("Combinations with the same Key are duplicates", func() {
+			runs, err := d.Sweep([]SweepParam{
+				{Name: "cutAdaptErrorRate", Values: []string{"0.15", "0.151", "0.2"}},
+				{Name: "vsearchMinQual", Values: []string{"20", "30"}},
+			})
+			So(err, ShouldBeNil)
+			So(runs, ShouldHaveLength, 6)
+
+			distinct, duplicates := DistinctRuns(runs, exp.Samples)
+			So(distinct, ShouldResemble, []*SweepRun{runs[0], runs[1], runs[4], runs[5]})
+			So(duplicates, ShouldResemble, []*SweepRun{runs[2], runs[3]})
+		})
+
+		Convey("With no parameters you get just the original DimSum", func() {
+			runs, err := d.Sweep(nil)
+			So(err, ShouldBeNil)
+			So(runs, ShouldHaveLength, 1)
+			So(runs[0].DimSum, ShouldResemble, d)
+			So(runs[0].Settings(nil), ShouldBeNil)
+		})
+
+		Convey("Invalid values are rejected", func() {
+			_, err := d.Sweep([]SweepParam{{Name: "maxSubstitutions", Values: []string{"2", "x"}}})
+			So(err, ShouldWrap, ErrInvalidSweepValue)
+
+			_, err = d.Sweep([]SweepParam{{Name: "mutagenesisType", Values: []string{"foo"}}})
+			So(err, ShouldWrap, ErrInvalidSweepValue)
+
+			_, err = d.Sweep([]SweepParam{{Name: "cutAdaptErrorRate", Values: []string{"0.1", "0.2"}}})
+			So(err, ShouldBeNil)
+		})
+	})
+}
@@ -47,9 +47,10 @@ const (
 
 // FastqCreator holds the information needed to create fastq files for a sample.
 type FastqCreator struct {
-	sample   *Sample
-	tsvPath  string
-	finalDir string
+	sample         *Sample
+	tsvPath        string
+	finalDir       string
+	filterManualQC bool
 }
 
 // IDRun returns the "SampleID.RunID" for this sample.
@@ -65,12 +66,26 @@ func (fc *FastqCreator) Command() string {
 	return fmt.Sprintf(
 		"irods_to_lustre --run_mode csv_samples_id --input_samples_csv %s "+
 			"--samples_to_process -1 --run_imeta_study false --run_iget_study_cram true "+
-			"--run_merge_crams true --run_crams_to_fastq true --filter_manual_qc true "+
+			"--run_merge_crams true --run_crams_to_fastq true --filter_manual_qc %t "+
 			"--outdir %s%s -w %s.work",
-		fc.tsvPath, outputPath, fastqOutputPathSuffix, outputPath,
+		fc.tsvPath, fc.filterManualQC, outputPath, fastqOutputPathSuffix, outputPath,
 	)
 }
 
+// TSVPath returns the path to the per-sample run TSV file that Command() uses.
+func (fc *FastqCreator) TSVPath() string {
+	return fc.tsvPath
+}
+
+// FastqPaths returns the final paths of the pair 1 and 2 fastq files that
+// MoveFastqFiles() moves to.
+func (fc *FastqCreator) FastqPaths() []string {
+	return []string{
+		fc.sample.FastqPath(fc.finalDir, FastqPair1Suffix),
+		fc.sample.FastqPath(fc.finalDir, FastqPair2Suffix),
+	}
+}
+
 func (fc *FastqCreator) outputPathPrefix() string {
 	return filepath.Join(".", fc.sample.Key())
 }
@@ -30,6 +30,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 
 	"github.com/wtsi-hgi/dimsum-automation/types"
 )
@@ -40,6 +42,7 @@ func (e Error) Error() string { return string(e) }
 
 const (
 	ErrNoStudy             = Error("study not specified")
+	ErrMultipleStudies     = Error("samples from multiple studies provided")
 	ErrMultipleExperiments = Error("samples from multiple experiments provided")
 	ErrMissingFastqFile    = Error("one fastq file for sample run already exists, but not the other")
 
@@ -74,9 +77,10 @@ func (s *Sample) FastqPath(outputDir, pairSuffix string) string {
 
 // ITL lets you use irods_to_lustre to get fastqs for certain samples.
 type ITL struct {
-	studyID  string
-	samples  []*Sample
-	fastqDir string
+	studyIDs       []string
+	samples        []*Sample
+	fastqDir       string
+	filterManualQC bool
 }
 
 // New creates a new ITL for the samples within the given library.
@@ -93,11 +97,80 @@ type ITL struct {
 // You can use Samples() to get the Samples of the unignored samples we will
 // operate on. If none are returned, you won't need to do anything, as all your
 // desired fastq files already exist.
+//
+// The library's samples must all belong to the same study; see NewMultiStudy()
+// if that is not the case.
 func New(lib *types.Library, fastqDir string) (*ITL, error) {
-	if lib == nil || lib.StudyID == "" {
+	return newITL(lib, fastqDir, false)
+}
+
+// NewMultiStudy is like New(), but allows the library's samples to belong to
+// more than one study, in which case metadata will be retrieved for all those
+// studies.
+func NewMultiStudy(lib *types.Library, fastqDir string) (*ITL, error) {
+	return newITL(lib, fastqDir, true)
+}
+
+// NewBatch is like New(), but for the samples of several experiments, given as
+// a Library for each experiment (see types.Libraries.SubsetByExperiment()).
+// Each Library is validated as New() would, but the fastqs of all of them are
+// retrieved together, with the metadata of all their studies being retrieved
+// by a single GenerateSamplesTSVCommand().
+func NewBatch(libs types.Libraries, fastqDir string) (*ITL, error) {
+	return newBatchITL(libs, fastqDir, false)
+}
+
+// NewMultiStudyBatch is like NewBatch(), but allows each Library's samples to
+// belong to more than one study.
+func NewMultiStudyBatch(libs types.Libraries, fastqDir string) (*ITL, error) {
+	return newBatchITL(libs, fastqDir, true)
+}
+
+func newBatchITL(libs types.Libraries, fastqDir string, allowMultipleStudies bool) (*ITL, error) {
+	if len(libs) == 0 {
+		return nil, ErrNoStudy
+	}
+
+	batch := &ITL{fastqDir: fastqDir, filterManualQC: true}
+	seen := make(map[string]bool)
+
+	for _, lib := range libs {
+		i, err := newITL(lib, fastqDir, allowMultipleStudies)
+		if err != nil {
+			return nil, fmt.Errorf("library %s: %w", lib.LibraryID, err)
+		}
+
+		batch.studyIDs = append(batch.studyIDs, i.studyIDs...)
+
+		for _, s := range i.samples {
+			if !seen[s.Key()] {
+				batch.samples = append(batch.samples, s)
+				seen[s.Key()] = true
+			}
+		}
+	}
+
+	slices.Sort(batch.studyIDs)
+	batch.studyIDs = slices.Compact(batch.studyIDs)
+
+	return batch, nil
+}
+
+func newITL(lib *types.Library, fastqDir string, allowMultipleStudies bool) (*ITL, error) {
+	if lib == nil {
+		return nil, ErrNoStudy
+	}
+
+	studyIDs := lib.Studies()
+
+	if len(studyIDs) == 0 {
 		return nil, ErrNoStudy
 	}
 
+	if len(studyIDs) > 1 && !allowMultipleStudies {
+		return nil, ErrMultipleStudies
+	}
+
 	samples, err := extractSamples(lib)
 	if err != nil {
 		return nil, err
@@ -109,9 +182,10 @@ func New(lib *types.Library, fastqDir string) (*ITL, error) {
 	}
 
 	return &ITL{
-		studyID:  lib.StudyID,
-		samples:  todo,
-		fastqDir: fastqDir,
+		studyIDs:       studyIDs,
+		samples:        todo,
+		fastqDir:       fastqDir,
+		filterManualQC: true,
 	}, nil
 }
 
@@ -186,6 +260,15 @@ func checkFastqFiles(input *Sample, fastqDir string) (bool, error) {
 	return false, nil
 }
 
+// HasFastqs returns true if both fastq files for the given sample run already
+// exist in the given fastq directory, ie. if a previous ITL retrieved them
+// there.
+func HasFastqs(s *types.Sample, fastqDir string) bool {
+	found, err := checkFastqFiles(&Sample{Sample: *s}, fastqDir)
+
+	return found && err == nil
+}
+
 func fileExists(path string) bool {
 	_, err := os.Stat(path)
 
@@ -201,16 +284,23 @@ func (i *ITL) Samples() []*Sample {
 	return i.samples
 }
 
+// AllowQCFailures makes the irods_to_lustre commands we generate include
+// sample runs that did not pass manual QC. By default only QC passed sample
+// runs can be retrieved.
+func (i *ITL) AllowQCFailures() {
+	i.filterManualQC = false
+}
+
 // GenerateSamplesTSVCommand returns a command line for irods_to_lustre that
-// will generate a TSV file of the sample metadata for our study. It also
-// returns the path to that TSV file.
+// will generate a TSV file of the sample metadata for our study (or studies).
+// It also returns the path to that TSV file.
 func (i *ITL) GenerateSamplesTSVCommand() (string, string) {
 	return fmt.Sprintf(
 		"irods_to_lustre --run_mode study_id --input_studies %s "+
 			"--samples_to_process -1 --run_imeta_study true --run_iget_study_cram false "+
-			"--run_merge_crams false --run_crams_to_fastq false --filter_manual_qc true "+
+			"--run_merge_crams false --run_crams_to_fastq false --filter_manual_qc %t "+
 			"--outdir %s -w %s",
-		i.studyID, tsvOutputDir, tsvWorkDir,
+		strings.Join(i.studyIDs, ","), i.filterManualQC, tsvOutputDir, tsvWorkDir,
 	), tsvOutputPath
 }
 
@@ -225,12 +315,31 @@ func (i *ITL) FilterSamplesTSV(inputTSVPath string) ([]FastqCreator, error) {
 			return nil, err
 		}
 
-		fcs = append(fcs, FastqCreator{
-			sample:   s,
-			tsvPath:  tsvPath,
-			finalDir: i.fastqDir,
-		})
+		fcs = append(fcs, i.fastqCreator(s, tsvPath))
 	}
 
 	return fcs, nil
 }
+
+// FastqCreators returns the FastqCreators that FilterSamplesTSV() would return,
+// without reading the samples TSV file or creating the per-sample run TSV
+// files. This lets you see what would be done before the samples TSV file
+// exists.
+func (i *ITL) FastqCreators() []FastqCreator {
+	fcs := make([]FastqCreator, len(i.samples))
+
+	for j, s := range i.samples {
+		fcs[j] = i.fastqCreator(s, s.TSVPath())
+	}
+
+	return fcs
+}
+
+func (i *ITL) fastqCreator(s *Sample, tsvPath string) FastqCreator {
+	return FastqCreator{
+		sample:         s,
+		tsvPath:        tsvPath,
+		finalDir:       i.fastqDir,
+		filterManualQC: i.filterManualQC,
+	}
+}
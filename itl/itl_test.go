@@ -27,6 +27,7 @@
 package itl
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -87,7 +88,7 @@ func TestITL(t *testing.T) {
 			itl, err := New(testLib, finalDir)
 			So(err, ShouldBeNil)
 			So(itl, ShouldNotBeNil)
-			So(itl.studyID, ShouldEqual, studyID)
+			So(itl.studyIDs, ShouldResemble, []string{studyID})
 			So(itl.Samples(), ShouldResemble, []*Sample{
 				{Sample: types.Sample{SampleID: "sample1_id", RunID: "run1"}},
 				{Sample: types.Sample{SampleID: "sample1_id", RunID: "run2"}},
@@ -106,9 +107,21 @@ func TestITL(t *testing.T) {
 			)
 			So(tsvPath, ShouldEqual, tsvOutputPath)
 
+			planned := itl.FastqCreators()
+			So(planned, ShouldHaveLength, len(testSamples))
+			So(planned[0].TSVPath(), ShouldEqual, filepath.Join(".", "sample1_id.run1.tsv"))
+			So(planned[0].FastqPaths(), ShouldResemble, []string{
+				filepath.Join(finalDir, "sample1_id.run1"+FastqPair1Suffix),
+				filepath.Join(finalDir, "sample1_id.run1"+FastqPair2Suffix),
+			})
+
+			_, err = os.Stat(planned[0].TSVPath())
+			So(err, ShouldNotBeNil)
+
 			fcs, err := itl.FilterSamplesTSV(testSamplesTSVPath)
 			So(err, ShouldBeNil)
 			So(fcs, ShouldHaveLength, len(testSamples))
+			So(fcs, ShouldResemble, planned)
 
 			for i, sr := range []string{
 				sampleName1 + "_id." + runID1,
@@ -175,15 +188,22 @@ func TestITL(t *testing.T) {
 
 			_, err = New(testLib, finalDir)
 			So(err, ShouldNotBeNil)
+			So(HasFastqs(testSamples[1], finalDir), ShouldBeFalse)
 
 			fastq2 := filepath.Join(finalDir, doneSR+FastqPair2Suffix)
 			err = os.WriteFile(fastq2, []byte("done"), userPerm)
 			So(err, ShouldBeNil)
 
+			So(HasFastqs(testSamples[1], finalDir), ShouldBeTrue)
+			So(HasFastqs(testSamples[0], finalDir), ShouldBeFalse)
+
+			So(HasFastqs(testSamples[1], finalDir), ShouldBeTrue)
+			So(HasFastqs(testSamples[0], finalDir), ShouldBeFalse)
+
 			itl, err := New(testLib, finalDir)
 			So(err, ShouldBeNil)
 			So(itl, ShouldNotBeNil)
-			So(itl.studyID, ShouldEqual, studyID)
+			So(itl.studyIDs, ShouldResemble, []string{studyID})
 			So(itl.Samples(), ShouldResemble, []*Sample{
 				{Sample: types.Sample{SampleID: "sample1_id", RunID: "run1"}},
 				{Sample: types.Sample{SampleID: "sample2_id", RunID: "run1"}},
@@ -192,6 +212,76 @@ func TestITL(t *testing.T) {
 			fcs, err := itl.FilterSamplesTSV(testSamplesTSVPath)
 			So(err, ShouldBeNil)
 			So(fcs, ShouldHaveLength, len(testSamples)-1)
+
+			Convey("And you can allow retrieval of sample runs that failed QC", func() {
+				itl.AllowQCFailures()
+
+				cmd, _ := itl.GenerateSamplesTSVCommand()
+				So(cmd, ShouldContainSubstring, "--filter_manual_qc false")
+
+				fcs, err := itl.FilterSamplesTSV(testSamplesTSVPath)
+				So(err, ShouldBeNil)
+				So(fcs[0].Command(), ShouldContainSubstring, "--filter_manual_qc false")
+			})
+		})
+
+		Convey("You can only make a new ITL with samples in multiple studies if you allow it", func() {
+			dir := t.TempDir()
+
+			testSamples[0].StudyID = "studyB"
+			testSamples[1].StudyID = "studyA"
+			testSamples[2].StudyID = "studyB"
+
+			_, err := New(testLib, dir)
+			So(err, ShouldEqual, ErrMultipleStudies)
+
+			itl, err := NewMultiStudy(testLib, dir)
+			So(err, ShouldBeNil)
+			So(itl.studyIDs, ShouldResemble, []string{"studyA", "studyB"})
+
+			cmd, _ := itl.GenerateSamplesTSVCommand()
+			So(cmd, ShouldStartWith, "irods_to_lustre --run_mode study_id --input_studies studyA,studyB ")
+		})
+
+		Convey("You can make a batch ITL for the samples of several experiments", func() {
+			dir := t.TempDir()
+
+			otherLib := &types.Library{
+				LibraryID: "lib2",
+				StudyID:   "study2",
+				Experiments: []*types.Experiment{
+					{
+						ExperimentID: "exp2",
+						Samples: []*types.Sample{
+							{RunID: runID1, SampleName: "sample3", SampleID: "sample3_id", StudyID: "study2"},
+							testSamples[0],
+						},
+					},
+				},
+			}
+
+			itl, err := NewBatch(types.Libraries{testLib, otherLib}, dir)
+			So(err, ShouldBeNil)
+			So(itl.studyIDs, ShouldResemble, []string{studyID, "study2"})
+			So(itl.Samples(), ShouldHaveLength, len(testSamples)+1)
+			So(itl.Samples()[len(testSamples)].SampleID, ShouldEqual, "sample3_id")
+
+			cmd, _ := itl.GenerateSamplesTSVCommand()
+			So(cmd, ShouldContainSubstring, "--input_studies "+studyID+",study2 ")
+
+			otherLib.Experiments[0].Samples = append(otherLib.Experiments[0].Samples,
+				&types.Sample{RunID: runID2, SampleName: "sample4", SampleID: "sample4_id", StudyID: "study3"})
+
+			_, err = NewBatch(types.Libraries{testLib, otherLib}, dir)
+			So(errors.Is(err, ErrMultipleStudies), ShouldBeTrue)
+			So(err.Error(), ShouldContainSubstring, "lib2")
+
+			itl, err = NewMultiStudyBatch(types.Libraries{testLib, otherLib}, dir)
+			So(err, ShouldBeNil)
+			So(itl.studyIDs, ShouldResemble, []string{studyID, "study2", "study3"})
+
+			_, err = NewBatch(nil, dir)
+			So(err, ShouldEqual, ErrNoStudy)
 		})
 
 		Convey("You can't make a new ITL with multiple or no experiments", func() {
@@ -27,6 +27,7 @@
 package mlwh
 
 import (
+	"context"
 	"net"
 
 	"github.com/go-sql-driver/mysql"
@@ -35,6 +36,8 @@ import (
 
 const sqlNetwork = "tcp"
 
+// MySQLConfigFromConfig returns a mysql.Config for connecting to the MLWH
+// using the SQL details in the given Config.
 func MySQLConfigFromConfig(c *config.Config) *mysql.Config {
 	mc := mysql.NewConfig()
 	mc.User = c.User
@@ -46,3 +49,14 @@ func MySQLConfigFromConfig(c *config.Config) *mysql.Config {
 
 	return mc
 }
+
+// FromConfig returns a new MLWH connection using the details in the given
+// Config: a SQLite database if it has a SQLitePath, otherwise the MySQL
+// warehouse with the Config's QueryTimeout.
+func FromConfig(ctx context.Context, c *config.Config) (*MLWH, error) {
+	if c.SQLitePath != "" {
+		return NewSQLite(c.SQLitePath)
+	}
+
+	return New(ctx, MySQLConfigFromConfig(c), Options{QueryTimeout: c.QueryTimeout})
+}
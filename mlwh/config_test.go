@@ -35,8 +35,8 @@ import (
 
 func TestConfig(t *testing.T) {
 	c, err := config.FromEnv("..")
-	if err != nil {
-		SkipConvey("skipping sheet tests without DIMSUM_AUTOMATION_* set", t, func() {})
+	if err != nil || c.SQLitePath != "" {
+		SkipConvey("skipping sheet tests without DIMSUM_AUTOMATION_SQL_* set", t, func() {})
 
 		return
 	}
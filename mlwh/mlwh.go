@@ -27,7 +27,10 @@
 package mlwh
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/go-sql-driver/mysql"
@@ -41,20 +44,29 @@ const (
 	maxIdleConns    = 10
 )
 
+// Sample is a types.Sample with the extra information we get from MLWH. There
+// is one Sample per lane that a sample was sequenced on.
 type Sample struct {
 	StudyID   string
 	StudyName string
+	Lane      int
 	types.Sample
 }
 
 // MLWH is a connection to the MLWH database.
 type MLWH struct {
 	pool *sql.DB
+	opts Options
 }
 
 // New returns a new MLWH connection using mysql.Config that you can get from
-// MySQLConfigFromConfig(config.FromEnv()).
-func New(c *mysql.Config) (*MLWH, error) {
+// MySQLConfigFromConfig(config.FromEnv()). The given Options control query
+// timeouts and retries; the zero value gives defaults.
+//
+// The connection is checked using the given context, and if the warehouse
+// can't be reached, an *UnavailableError is returned (and the connection pool
+// is closed).
+func New(ctx context.Context, c *mysql.Config, opts Options) (*MLWH, error) {
 	pool, err := sql.Open(sqlDriverName, c.FormatDSN())
 	if err != nil {
 		return nil, err
@@ -64,23 +76,96 @@ func New(c *mysql.Config) (*MLWH, error) {
 	pool.SetMaxOpenConns(maxOpenConns)
 	pool.SetMaxIdleConns(maxIdleConns)
 
-	return &MLWH{pool: pool}, pool.Ping()
+	m := &MLWH{pool: pool, opts: opts.withDefaults()}
+
+	if err = m.opts.retry(ctx, pool.PingContext); err != nil {
+		pool.Close()
+
+		return nil, err
+	}
+
+	return m, nil
 }
 
-const getSamples = `
+const (
+	getSamples = `
 SELECT DISTINCT st.id_study_lims as StudyID, st.name as StudyName,
 r.id_run as RunID, sa.sanger_sample_id as SangerSampleID,
-sa.supplier_name as SupplierName, fc.manual_qc as ManualQC
+sa.supplier_name as SupplierName, fc.manual_qc as ManualQC,
+fc.position as Lane, fc.tag_index as TagIndex,
+rlm.instrument_model as InstrumentModel, rlm.run_complete as RunComplete,
+fc.forward_read_length as ReadLength, fc.pipeline_id_lims as PipelineIDLims
 FROM iseq_flowcell fc
 JOIN study st on st.id_study_tmp = fc.id_study_tmp
 JOIN iseq_run r on r.id_flowcell_lims = fc.id_flowcell_lims
 JOIN sample sa on sa.id_sample_tmp = fc.id_sample_tmp
-WHERE st.faculty_sponsor = ? and (fc.manual_qc = '1' or fc.manual_qc = '0')
+LEFT JOIN iseq_run_lane_metrics rlm on rlm.id_run = r.id_run and rlm.position = fc.position
 `
+	whereSponsor     = `WHERE st.faculty_sponsor = ?`
+	whereStudyIDs    = `WHERE st.id_study_lims IN (%s)`
+	whereSampleNames = `WHERE sa.supplier_name IN (%s)`
+)
+
+// SamplesForSponsor returns all samples in the MLWH for the given sponsor,
+// regardless of their manual QC state. Samples that have not been QC'd yet will
+// have a blank ManualQC. There will be one Sample per lane per run that a
+// sample was sequenced in.
+//
+// Queries that fail with transient errors are retried, and if they never
+// succeed an *UnavailableError is returned.
+func (m *MLWH) SamplesForSponsor(ctx context.Context, sponsor string) ([]*Sample, error) {
+	return m.querySamples(ctx, getSamples+whereSponsor, sponsor)
+}
+
+// SamplesForStudies is like SamplesForSponsor(), but returns the samples in the
+// studies with the given IDs (id_study_lims), regardless of sponsor.
+func (m *MLWH) SamplesForStudies(ctx context.Context, ids []string) ([]*Sample, error) {
+	return m.querySamplesIn(ctx, whereStudyIDs, ids)
+}
+
+// SamplesByName is like SamplesForSponsor(), but returns the samples with the
+// given names (supplier names, ie. the SampleName of the returned Samples),
+// regardless of study or sponsor.
+func (m *MLWH) SamplesByName(ctx context.Context, names []string) ([]*Sample, error) {
+	return m.querySamplesIn(ctx, whereSampleNames, names)
+}
+
+// querySamplesIn queries for samples using the given where clause, which must
+// have a single %s placeholder for the list of values to be IN. Returns no
+// samples if values is empty.
+func (m *MLWH) querySamplesIn(ctx context.Context, where string, values []string) ([]*Sample, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(values)), ",")
+	args := make([]any, len(values))
 
-// SamplesForSponsor returns all samples in the MLWH for the given sponsor.
-func (m *MLWH) SamplesForSponsor(sponsor string) ([]*Sample, error) {
-	rows, err := m.pool.Query(getSamples, sponsor)
+	for i, v := range values {
+		args[i] = v
+	}
+
+	return m.querySamples(ctx, getSamples+fmt.Sprintf(where, placeholders), args...)
+}
+
+// querySamples runs the given getSamples-based query with the given args,
+// retrying on transient errors.
+func (m *MLWH) querySamples(ctx context.Context, query string, args ...any) ([]*Sample, error) {
+	var samples []*Sample
+
+	err := m.opts.retry(ctx, func(ctx context.Context) error {
+		var err error
+
+		samples, err = m.querySamplesOnce(ctx, query, args...)
+
+		return err
+	})
+
+	return samples, err
+}
+
+func (m *MLWH) querySamplesOnce(ctx context.Context, query string, args ...any) ([]*Sample, error) {
+	rows, err := m.pool.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -89,12 +174,36 @@ func (m *MLWH) SamplesForSponsor(sponsor string) ([]*Sample, error) {
 	return m.processSampleRows(rows)
 }
 
+// nullableColumns holds the values of the columns in our getSamples query that
+// could be NULL.
+type nullableColumns struct {
+	manualQC        sql.NullString
+	tagIndex        sql.NullInt64
+	instrumentModel sql.NullString
+	runComplete     sql.NullTime
+	readLength      sql.NullInt64
+	pipelineIDLims  sql.NullString
+}
+
+// setOn sets the non-NULL values on the given Sample.
+func (n *nullableColumns) setOn(sample *Sample) {
+	sample.ManualQC = n.manualQC.String
+	sample.TagIndex = int(n.tagIndex.Int64)
+	sample.InstrumentModel = n.instrumentModel.String
+	sample.RunComplete = n.runComplete.Time
+	sample.ReadLength = int(n.readLength.Int64)
+	sample.PipelineIDLims = n.pipelineIDLims.String
+}
+
 // processSampleRows extracts Sample objects from database rows.
 func (m *MLWH) processSampleRows(rows *sql.Rows) ([]*Sample, error) {
 	var samples []*Sample //nolint:prealloc
 
 	for rows.Next() {
-		var sample Sample
+		var (
+			sample Sample
+			n      nullableColumns
+		)
 
 		if err := rows.Scan(
 			&sample.StudyID,
@@ -102,11 +211,19 @@ func (m *MLWH) processSampleRows(rows *sql.Rows) ([]*Sample, error) {
 			&sample.RunID,
 			&sample.SampleID,
 			&sample.SampleName,
-			&sample.ManualQC,
+			&n.manualQC,
+			&sample.Lane,
+			&n.tagIndex,
+			&n.instrumentModel,
+			&n.runComplete,
+			&n.readLength,
+			&n.pipelineIDLims,
 		); err != nil {
 			return nil, err
 		}
 
+		n.setOn(&sample)
+
 		samples = append(samples, &sample)
 	}
 
@@ -27,6 +27,7 @@
 package mlwh
 
 import (
+	"context"
 	"testing"
 
 	. "github.com/smartystreets/goconvey/convey"
@@ -37,19 +38,21 @@ const sponsor = "Ben Lehner"
 
 func TestMLWH(t *testing.T) {
 	c, err := config.FromEnv("..")
-	if err != nil {
-		SkipConvey("skipping mlwh tests without DIMSUM_AUTOMATION_* set", t, func() {})
+	if err != nil || c.SQLitePath != "" {
+		SkipConvey("skipping mlwh tests without DIMSUM_AUTOMATION_SQL_* set", t, func() {})
 
 		return
 	}
 
 	Convey("Given a working New MLWH", t, func() {
-		mlwh, err := New(MySQLConfigFromConfig(c))
+		ctx := context.Background()
+
+		mlwh, err := New(ctx, MySQLConfigFromConfig(c), Options{})
 		So(err, ShouldBeNil)
 		So(mlwh, ShouldNotBeNil)
 
 		Convey("You can get info about samples belonging to a given sponsor", func() {
-			samples, err := mlwh.SamplesForSponsor(sponsor)
+			samples, err := mlwh.SamplesForSponsor(ctx, sponsor)
 			So(err, ShouldBeNil)
 			So(len(samples), ShouldBeGreaterThan, 10)
 			So(samples[0].SampleID, ShouldNotBeEmpty)
@@ -57,6 +60,7 @@ func TestMLWH(t *testing.T) {
 			So(samples[0].RunID, ShouldNotBeEmpty)
 			So(samples[0].StudyID, ShouldNotBeEmpty)
 			So(samples[0].StudyName, ShouldNotBeEmpty)
+			So(samples[0].Lane, ShouldBeGreaterThan, 0)
 
 			passed := 0
 			failed := 0
@@ -72,9 +76,37 @@ func TestMLWH(t *testing.T) {
 			So(passed, ShouldBeGreaterThan, 0)
 			So(failed, ShouldBeGreaterThan, 0)
 
-			samples, err = mlwh.SamplesForSponsor("invalid sponsor")
+			samples, err = mlwh.SamplesForSponsor(ctx, "invalid sponsor")
 			So(err, ShouldBeNil)
 			So(len(samples), ShouldEqual, 0)
 		})
+
+		Convey("You can get info about samples in given studies or with given names", func() {
+			sponsorSamples, err := mlwh.SamplesForSponsor(ctx, sponsor)
+			So(err, ShouldBeNil)
+			So(len(sponsorSamples), ShouldBeGreaterThan, 0)
+
+			first := sponsorSamples[0]
+
+			samples, err := mlwh.SamplesForStudies(ctx, []string{first.StudyID})
+			So(err, ShouldBeNil)
+			So(len(samples), ShouldBeGreaterThan, 0)
+
+			for _, sample := range samples {
+				So(sample.StudyID, ShouldEqual, first.StudyID)
+			}
+
+			samples, err = mlwh.SamplesByName(ctx, []string{first.SampleName})
+			So(err, ShouldBeNil)
+			So(len(samples), ShouldBeGreaterThan, 0)
+
+			for _, sample := range samples {
+				So(sample.SampleName, ShouldEqual, first.SampleName)
+			}
+
+			samples, err = mlwh.SamplesForStudies(ctx, nil)
+			So(err, ShouldBeNil)
+			So(samples, ShouldBeEmpty)
+		})
 	})
 }
@@ -0,0 +1,179 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Authors:
+ *	- Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package mlwh
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+const (
+	DefaultQueryTimeout = 5 * time.Minute
+	DefaultRetries      = 3
+	DefaultRetryBackoff = 1 * time.Second
+
+	ErrUnavailable = Error("warehouse unavailable")
+
+	mysqlErrTooManyConnections     = 1040
+	mysqlErrUserTooManyConnections = 1203
+	mysqlErrLockWaitTimeout        = 1205
+	mysqlErrDeadlock               = 1213
+)
+
+type Error string
+
+func (e Error) Error() string { return string(e) }
+
+// UnavailableError is returned when a query failed with a transient error (eg.
+// a connection reset, deadlock, too many connections or query timeout) on
+// every attempt. errors.Is(err, ErrUnavailable) is true for these.
+type UnavailableError struct {
+	Attempts int
+	Err      error
+}
+
+// Error returns a message saying how many attempts were made and what the last
+// error was.
+func (e *UnavailableError) Error() string {
+	return fmt.Sprintf("%s after %d attempts: %s", ErrUnavailable, e.Attempts, e.Err)
+}
+
+// Unwrap returns the error from the last attempt.
+func (e *UnavailableError) Unwrap() error {
+	return e.Err
+}
+
+// Is returns true for ErrUnavailable.
+func (e *UnavailableError) Is(target error) bool {
+	return target == ErrUnavailable //nolint:errorlint
+}
+
+// Options control how queries are made.
+type Options struct {
+	// QueryTimeout is the maximum time a single attempt at a query can take.
+	// Defaults to DefaultQueryTimeout.
+	QueryTimeout time.Duration
+
+	// Retries is the number of times a query that failed with a transient
+	// error is retried. Defaults to DefaultRetries; supply a negative value
+	// to disable retries.
+	Retries int
+
+	// RetryBackoff is how long to wait before the first retry, doubling for
+	// each subsequent retry. Defaults to DefaultRetryBackoff.
+	RetryBackoff time.Duration
+}
+
+// withDefaults returns a copy of these Options with zero values replaced by
+// defaults.
+func (o Options) withDefaults() Options {
+	if o.QueryTimeout <= 0 {
+		o.QueryTimeout = DefaultQueryTimeout
+	}
+
+	switch {
+	case o.Retries == 0:
+		o.Retries = DefaultRetries
+	case o.Retries < 0:
+		o.Retries = 0
+	}
+
+	if o.RetryBackoff <= 0 {
+		o.RetryBackoff = DefaultRetryBackoff
+	}
+
+	return o
+}
+
+// retry calls fn with a context limited to our QueryTimeout, retrying with
+// exponential backoff if it returns a transient error. If every attempt fails
+// with a transient error, returns an *UnavailableError. Non-transient errors,
+// including the given context being cancelled, are returned as-is.
+func (o Options) retry(ctx context.Context, fn func(context.Context) error) error {
+	backoff := o.RetryBackoff
+
+	for attempt := 1; ; attempt++ {
+		err := o.attempt(ctx, fn)
+
+		switch {
+		case err == nil:
+			return nil
+		case !isTransient(err):
+			return err
+		case attempt > o.Retries:
+			return &UnavailableError{Attempts: attempt, Err: err}
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return &UnavailableError{Attempts: attempt, Err: err}
+		}
+
+		backoff *= 2
+	}
+}
+
+func (o Options) attempt(ctx context.Context, fn func(context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, o.QueryTimeout)
+	defer cancel()
+
+	return fn(ctx)
+}
+
+// isTransient returns true if the given error is one that might not happen if
+// the query were tried again.
+func isTransient(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		switch mysqlErr.Number {
+		case mysqlErrTooManyConnections, mysqlErrUserTooManyConnections,
+			mysqlErrLockWaitTimeout, mysqlErrDeadlock:
+			return true
+		default:
+			return false
+		}
+	}
+
+	var netErr net.Error
+
+	return errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, mysql.ErrInvalidConn) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.As(err, &netErr)
+}
@@ -0,0 +1,134 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Authors:
+ *	- Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package mlwh
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRetry(t *testing.T) {
+	Convey("Given Options with short timeouts and backoffs", t, func() {
+		opts := Options{
+			QueryTimeout: 20 * time.Millisecond,
+			Retries:      2,
+			RetryBackoff: time.Millisecond,
+		}.withDefaults()
+		ctx := context.Background()
+
+		attempts := 0
+		failWith := func(errs ...error) func(context.Context) error {
+			return func(context.Context) error {
+				attempts++
+
+				if attempts > len(errs) {
+					return nil
+				}
+
+				return errs[attempts-1]
+			}
+		}
+
+		Convey("Transient errors are retried", func() {
+			err := opts.retry(ctx, failWith(driver.ErrBadConn, &mysql.MySQLError{Number: mysqlErrDeadlock}))
+			So(err, ShouldBeNil)
+			So(attempts, ShouldEqual, 3)
+		})
+
+		Convey("Other errors are returned immediately", func() {
+			syntaxErr := &mysql.MySQLError{Number: 1064}
+			err := opts.retry(ctx, failWith(syntaxErr))
+			So(err, ShouldEqual, syntaxErr)
+			So(attempts, ShouldEqual, 1)
+		})
+
+		Convey("Persistent transient errors give an UnavailableError", func() {
+			err := opts.retry(ctx, failWith(syscall.ECONNRESET, syscall.ECONNRESET, syscall.ECONNRESET))
+			So(err, ShouldNotBeNil)
+			So(errors.Is(err, ErrUnavailable), ShouldBeTrue)
+			So(errors.Is(err, syscall.ECONNRESET), ShouldBeTrue)
+			So(attempts, ShouldEqual, 3)
+
+			var uerr *UnavailableError
+			So(errors.As(err, &uerr), ShouldBeTrue)
+			So(uerr.Attempts, ShouldEqual, 3)
+			So(err.Error(), ShouldStartWith, "warehouse unavailable after 3 attempts")
+		})
+
+		Convey("Hung queries time out and are retried", func() {
+			err := opts.retry(ctx, func(ctx context.Context) error {
+				attempts++
+				<-ctx.Done()
+
+				return ctx.Err()
+			})
+			So(errors.Is(err, ErrUnavailable), ShouldBeTrue)
+			So(errors.Is(err, context.DeadlineExceeded), ShouldBeTrue)
+			So(attempts, ShouldEqual, 3)
+		})
+
+		Convey("Cancelling the context stops retries", func() {
+			cctx, cancel := context.WithCancel(ctx)
+			err := opts.retry(cctx, func(context.Context) error {
+				attempts++
+				cancel()
+
+				return context.Canceled
+			})
+			So(err, ShouldEqual, context.Canceled)
+			So(attempts, ShouldEqual, 1)
+		})
+
+		Convey("Retries can be disabled", func() {
+			opts = Options{Retries: -1}.withDefaults()
+			err := opts.retry(ctx, failWith(driver.ErrBadConn))
+			So(errors.Is(err, ErrUnavailable), ShouldBeTrue)
+			So(attempts, ShouldEqual, 1)
+		})
+	})
+
+	Convey("New returns no MLWH when the warehouse can't be reached", t, func() {
+		c := mysql.NewConfig()
+		c.Net = "tcp"
+		c.Addr = "127.0.0.1:1"
+
+		m, err := New(context.Background(), c, Options{
+			QueryTimeout: 100 * time.Millisecond,
+			Retries:      1,
+			RetryBackoff: time.Millisecond,
+		})
+		So(errors.Is(err, ErrUnavailable), ShouldBeTrue)
+		So(m, ShouldBeNil)
+	})
+}
@@ -0,0 +1,279 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Authors:
+ *	- Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package mlwh
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"os"
+	"time"
+
+	_ "modernc.org/sqlite" // register the sqlite driver
+)
+
+const sqliteDriverName = "sqlite"
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS study (
+	id_study_tmp INTEGER PRIMARY KEY,
+	id_study_lims TEXT NOT NULL,
+	name TEXT,
+	faculty_sponsor TEXT
+);
+CREATE TABLE IF NOT EXISTS sample (
+	id_sample_tmp INTEGER PRIMARY KEY,
+	sanger_sample_id TEXT,
+	supplier_name TEXT
+);
+CREATE TABLE IF NOT EXISTS iseq_flowcell (
+	id_iseq_flowcell_tmp INTEGER PRIMARY KEY AUTOINCREMENT,
+	id_flowcell_lims TEXT NOT NULL,
+	id_study_tmp INTEGER NOT NULL,
+	id_sample_tmp INTEGER NOT NULL,
+	position INTEGER NOT NULL,
+	tag_index INTEGER,
+	manual_qc INTEGER,
+	entity_type TEXT NOT NULL,
+	forward_read_length INTEGER,
+	pipeline_id_lims TEXT
+);
+CREATE TABLE IF NOT EXISTS iseq_run (
+	id_run INTEGER PRIMARY KEY,
+	id_flowcell_lims TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS iseq_run_lane_metrics (
+	id_run INTEGER NOT NULL,
+	position INTEGER NOT NULL,
+	instrument_model TEXT,
+	run_complete DATETIME,
+	PRIMARY KEY (id_run, position)
+);
+`
+
+// seededTables are the tables in sqliteSchema, in the order they are cleared
+// and seeded.
+var seededTables = []string{ //nolint:gochecknoglobals
+	"study", "sample", "iseq_flowcell", "iseq_run", "iseq_run_lane_metrics",
+}
+
+// NewSQLite returns an MLWH that uses a local SQLite database file at the given
+// path instead of the real MySQL warehouse, for offline development and
+// testing. The file is created if it doesn't exist, and the subset of MLWH
+// tables and columns we query are created if they don't exist. Use ":memory:"
+// for an in-memory database.
+//
+// Populate the database with Seed().
+func NewSQLite(path string) (*MLWH, error) {
+	pool, err := sql.Open(sqliteDriverName, path)
+	if err != nil {
+		return nil, err
+	}
+
+	// in-memory databases are per-connection, and sqlite only supports a
+	// single writer anyway
+	pool.SetMaxOpenConns(1)
+
+	if _, err = pool.Exec(sqliteSchema); err != nil {
+		pool.Close()
+
+		return nil, err
+	}
+
+	return &MLWH{pool: pool, opts: Options{}.withDefaults()}, nil
+}
+
+// Fixture holds rows for the MLWH tables we query, for seeding a SQLite MLWH.
+// Its JSON form has a key per table, each holding a list of rows keyed by
+// column name.
+type Fixture struct {
+	Studies        []FixtureStudy          `json:"study"`
+	Samples        []FixtureSample         `json:"sample"`
+	Flowcells      []FixtureFlowcell       `json:"iseq_flowcell"`
+	Runs           []FixtureRun            `json:"iseq_run"`
+	RunLaneMetrics []FixtureRunLaneMetrics `json:"iseq_run_lane_metrics"`
+}
+
+// FixtureStudy is a row in the study table.
+type FixtureStudy struct {
+	IDStudyTmp     int    `json:"id_study_tmp"`
+	IDStudyLims    string `json:"id_study_lims"`
+	Name           string `json:"name"`
+	FacultySponsor string `json:"faculty_sponsor"`
+}
+
+// FixtureSample is a row in the sample table.
+type FixtureSample struct {
+	IDSampleTmp    int    `json:"id_sample_tmp"`
+	SangerSampleID string `json:"sanger_sample_id"`
+	SupplierName   string `json:"supplier_name"`
+}
+
+// FixtureFlowcell is a row in the iseq_flowcell table. Nil pointers are NULL.
+type FixtureFlowcell struct {
+	IDFlowcellLims    string  `json:"id_flowcell_lims"`
+	IDStudyTmp        int     `json:"id_study_tmp"`
+	IDSampleTmp       int     `json:"id_sample_tmp"`
+	Position          int     `json:"position"`
+	TagIndex          *int    `json:"tag_index"`
+	ManualQC          *int    `json:"manual_qc"`
+	EntityType        string  `json:"entity_type"`
+	ForwardReadLength *int    `json:"forward_read_length"`
+	PipelineIDLims    *string `json:"pipeline_id_lims"`
+}
+
+// FixtureRun is a row in the iseq_run table.
+type FixtureRun struct {
+	IDRun          int    `json:"id_run"`
+	IDFlowcellLims string `json:"id_flowcell_lims"`
+}
+
+// FixtureRunLaneMetrics is a row in the iseq_run_lane_metrics table. Nil
+// pointers are NULL.
+type FixtureRunLaneMetrics struct {
+	IDRun           int        `json:"id_run"`
+	Position        int        `json:"position"`
+	InstrumentModel *string    `json:"instrument_model"`
+	RunComplete     *time.Time `json:"run_complete"`
+}
+
+// LoadFixture reads a Fixture from the JSON file at the given path.
+func LoadFixture(path string) (*Fixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &Fixture{}
+
+	if err = json.Unmarshal(data, f); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// Seed replaces all data in our tables with the rows in the given Fixture.
+// This only makes sense for an MLWH returned by NewSQLite().
+func (m *MLWH) Seed(ctx context.Context, f *Fixture) error {
+	tx, err := m.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err = f.insertInto(ctx, tx); err != nil {
+		tx.Rollback() //nolint:errcheck
+
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// insertInto clears our tables and inserts our rows in to them using the given
+// transaction.
+func (f *Fixture) insertInto(ctx context.Context, tx *sql.Tx) error {
+	for _, table := range seededTables {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM "+table); err != nil {
+			return err
+		}
+	}
+
+	inserts := make([]insert, 0,
+		len(f.Studies)+len(f.Samples)+len(f.Flowcells)+len(f.Runs)+len(f.RunLaneMetrics))
+
+	inserts = appendInserts(inserts, f.Studies)
+	inserts = appendInserts(inserts, f.Samples)
+	inserts = appendInserts(inserts, f.Flowcells)
+	inserts = appendInserts(inserts, f.Runs)
+	inserts = appendInserts(inserts, f.RunLaneMetrics)
+
+	for _, ins := range inserts {
+		if _, err := tx.ExecContext(ctx, ins.query, ins.args...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// insert is an SQL insert statement with its args.
+type insert struct {
+	query string
+	args  []any
+}
+
+// fixtureRow is implemented by the Fixture* row types.
+type fixtureRow interface {
+	insert() insert
+}
+
+func appendInserts[T fixtureRow](inserts []insert, rows []T) []insert {
+	for _, row := range rows {
+		inserts = append(inserts, row.insert())
+	}
+
+	return inserts
+}
+
+func (r FixtureStudy) insert() insert {
+	return insert{
+		query: `INSERT INTO study (id_study_tmp, id_study_lims, name, faculty_sponsor) VALUES (?, ?, ?, ?)`,
+		args:  []any{r.IDStudyTmp, r.IDStudyLims, r.Name, r.FacultySponsor},
+	}
+}
+
+func (r FixtureSample) insert() insert {
+	return insert{
+		query: `INSERT INTO sample (id_sample_tmp, sanger_sample_id, supplier_name) VALUES (?, ?, ?)`,
+		args:  []any{r.IDSampleTmp, r.SangerSampleID, r.SupplierName},
+	}
+}
+
+func (r FixtureFlowcell) insert() insert {
+	return insert{
+		query: `INSERT INTO iseq_flowcell (id_flowcell_lims, id_study_tmp, id_sample_tmp, position, tag_index,
+manual_qc, entity_type, forward_read_length, pipeline_id_lims) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		args: []any{r.IDFlowcellLims, r.IDStudyTmp, r.IDSampleTmp, r.Position, r.TagIndex,
+			r.ManualQC, r.EntityType, r.ForwardReadLength, r.PipelineIDLims},
+	}
+}
+
+func (r FixtureRun) insert() insert {
+	return insert{
+		query: `INSERT INTO iseq_run (id_run, id_flowcell_lims) VALUES (?, ?)`,
+		args:  []any{r.IDRun, r.IDFlowcellLims},
+	}
+}
+
+func (r FixtureRunLaneMetrics) insert() insert {
+	return insert{
+		query: `INSERT INTO iseq_run_lane_metrics (id_run, position, instrument_model, run_complete)
+VALUES (?, ?, ?, ?)`,
+		args: []any{r.IDRun, r.Position, r.InstrumentModel, r.RunComplete},
+	}
+}
@@ -0,0 +1,110 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Authors:
+ *	- Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package mlwh
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSQLite(t *testing.T) {
+	Convey("Given a SQLite MLWH seeded from a fixture", t, func() {
+		ctx := context.Background()
+
+		f, err := LoadFixture(filepath.Join("testdata", "mlwh.json"))
+		So(err, ShouldBeNil)
+
+		m, err := NewSQLite(filepath.Join(t.TempDir(), "mlwh.db"))
+		So(err, ShouldBeNil)
+
+		defer m.Close()
+
+		err = m.Seed(ctx, f)
+		So(err, ShouldBeNil)
+
+		Convey("You can get info about samples belonging to a given sponsor", func() {
+			samples, err := m.SamplesForSponsor(ctx, sponsor)
+			So(err, ShouldBeNil)
+			So(samples, ShouldHaveLength, 4)
+
+			byLane := make(map[string]*Sample)
+
+			for _, s := range samples {
+				byLane[fmt.Sprintf("%s:%s:%d", s.SampleName, s.RunID, s.Lane)] = s
+			}
+
+			s := byLane["DMS_sample1:50001:2"]
+			So(s, ShouldNotBeNil)
+			So(s.StudyID, ShouldEqual, "7000")
+			So(s.StudyName, ShouldEqual, "DMS study A")
+			So(s.SampleID, ShouldEqual, "7000STDY1")
+			So(s.ManualQC, ShouldEqual, "1")
+			So(s.TagIndex, ShouldEqual, 1)
+			So(s.InstrumentModel, ShouldEqual, "NovaSeqX")
+			So(s.RunComplete.Equal(time.Date(2025, 1, 15, 10, 30, 0, 0, time.UTC)), ShouldBeTrue)
+			So(s.ReadLength, ShouldEqual, 151)
+			So(s.PipelineIDLims, ShouldEqual, "Custom")
+
+			So(byLane["DMS_sample2:50001:1"].ManualQC, ShouldEqual, "0")
+
+			pending := byLane["DMS_sample3:50002:1"]
+			So(pending, ShouldNotBeNil)
+			So(pending.ManualQC, ShouldBeBlank)
+			So(pending.InstrumentModel, ShouldBeBlank)
+			So(pending.RunComplete.IsZero(), ShouldBeTrue)
+			So(pending.PipelineIDLims, ShouldBeBlank)
+		})
+
+		Convey("You can get info about samples in given studies, or with given names", func() {
+			samples, err := m.SamplesForStudies(ctx, []string{"8000"})
+			So(err, ShouldBeNil)
+			So(samples, ShouldHaveLength, 2)
+			So(samples[0].SampleName, ShouldEqual, "Collab_sample4")
+			So(samples[1].SampleName, ShouldEqual, "Collab_sample5")
+			So(samples[1].PipelineIDLims, ShouldEqual, "Standard")
+			So(samples[1].TagIndex, ShouldEqual, 0)
+
+			samples, err = m.SamplesByName(ctx, []string{"DMS_sample1", "Collab_sample4"})
+			So(err, ShouldBeNil)
+			So(samples, ShouldHaveLength, 3)
+		})
+
+		Convey("Seeding again replaces the existing data", func() {
+			err = m.Seed(ctx, &Fixture{Studies: f.Studies[:1]})
+			So(err, ShouldBeNil)
+
+			samples, err := m.SamplesForSponsor(ctx, sponsor)
+			So(err, ShouldBeNil)
+			So(samples, ShouldBeEmpty)
+		})
+	})
+}
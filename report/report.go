@@ -0,0 +1,440 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Authors:
+ *	- Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+// Package report writes the sample runs of types.Libraries in various formats,
+// for people or other programs to read.
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/wtsi-hgi/dimsum-automation/types"
+)
+
+type Error string
+
+func (e Error) Error() string { return string(e) }
+
+const (
+	ErrInvalidFormat = Error("invalid format")
+	ErrInvalidColumn = Error("invalid column")
+
+	tsvSeparator  = "\t"
+	listSeparator = ","
+
+	tableMinWidth = 0
+	tableTabWidth = 8
+	tablePadding  = 2
+)
+
+// Format is a way of writing Libraries.
+type Format string
+
+const (
+	// FormatJSON writes a single JSON array of the libraries, or of the sample
+	// runs if columns are chosen.
+	FormatJSON Format = "json"
+
+	// FormatJSONL writes one JSON object per line for each library, or for each
+	// sample run if columns are chosen.
+	FormatJSONL Format = "jsonl"
+
+	// FormatTSV writes a header line and then one line per sample run, with
+	// the columns of their library and experiment.
+	FormatTSV Format = "tsv"
+
+	// FormatTable writes an aligned table of sample runs, followed by a
+	// summary.
+	FormatTable Format = "table"
+)
+
+// Formats are all the valid Formats.
+var Formats = []Format{FormatJSON, FormatJSONL, FormatTSV, FormatTable} //nolint:gochecknoglobals
+
+// StringToFormat converts a string to a Format. Blank strings are treated as
+// FormatJSON.
+func StringToFormat(s string) (Format, error) {
+	if s == "" {
+		return FormatJSON, nil
+	}
+
+	if !slices.Contains(Formats, Format(s)) {
+		return "", fmt.Errorf("%w: %s", ErrInvalidFormat, s)
+	}
+
+	return Format(s), nil
+}
+
+// Row is a sample run along with the experiment and library it belongs to.
+type Row struct {
+	Library    *types.Library
+	Experiment *types.Experiment
+	Sample     *types.Sample
+}
+
+// Rows flattens the given libraries in to one Row per sample run.
+func Rows(libs types.Libraries) []*Row {
+	var rows []*Row
+
+	for _, lib := range libs {
+		for _, exp := range lib.Experiments {
+			for _, s := range exp.Samples {
+				rows = append(rows, &Row{Library: lib, Experiment: exp, Sample: s})
+			}
+		}
+	}
+
+	return rows
+}
+
+// column is a named value of a Row.
+type column struct {
+	name  string
+	value func(r *Row) any
+}
+
+var columns = []column{ //nolint:gochecknoglobals
+	{"library_id", func(r *Row) any { return r.Library.LibraryID }},
+	{"study_id", func(r *Row) any { return r.Sample.StudyID }},
+	{"study_name", func(r *Row) any { return r.Sample.StudyName }},
+	{"experiment_id", func(r *Row) any { return r.Experiment.ExperimentID }},
+	{"assay", func(r *Row) any { return r.Experiment.Assay }},
+	{"sample_name", func(r *Row) any { return r.Sample.SampleName }},
+	{"sample_id", func(r *Row) any { return r.Sample.SampleID }},
+	{"run_id", func(r *Row) any { return r.Sample.RunID }},
+	{"manual_qc", func(r *Row) any { return r.Sample.QCState() }},
+	{"lanes", func(r *Row) any { return r.Sample.Lanes }},
+	{"tag_index", func(r *Row) any { return r.Sample.TagIndex }},
+	{"instrument_model", func(r *Row) any { return r.Sample.InstrumentModel }},
+	{"run_complete", func(r *Row) any { return formatTime(r.Sample.RunComplete) }},
+	{"read_length", func(r *Row) any { return r.Sample.ReadLength }},
+	{"pipeline_id_lims", func(r *Row) any { return r.Sample.PipelineIDLims }},
+	{"selection", func(r *Row) any { return string(r.Sample.Selection) }},
+	{"experiment_replicate", func(r *Row) any { return r.Sample.ExperimentReplicate }},
+	{"technical_replicate", func(r *Row) any { return r.Sample.TechnicalReplicate }},
+	{"selection_time", func(r *Row) any { return r.Sample.SelectionTime }},
+	{"cell_density", func(r *Row) any { return r.Sample.CellDensity }},
+}
+
+// DefaultTableColumns are the columns shown by FormatTable if none are chosen.
+var DefaultTableColumns = []string{ //nolint:gochecknoglobals
+	"library_id", "experiment_id", "sample_name", "run_id", "manual_qc", "selection", "experiment_replicate",
+}
+
+// Columns returns the names of all the columns that can be chosen.
+func Columns() []string {
+	names := make([]string, len(columns))
+
+	for i, c := range columns {
+		names[i] = c.name
+	}
+
+	return names
+}
+
+// chooseColumns returns the columns with the given names, in the given order,
+// or ErrInvalidColumn if any are unknown.
+func chooseColumns(names []string) ([]column, error) {
+	chosen := make([]column, len(names))
+
+	for i, name := range names {
+		j := slices.IndexFunc(columns, func(c column) bool { return c.name == name })
+		if j < 0 {
+			return nil, fmt.Errorf("%w: %s (valid: %s)", ErrInvalidColumn, name, strings.Join(Columns(), ", "))
+		}
+
+		chosen[i] = columns[j]
+	}
+
+	return chosen, nil
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.Format(time.RFC3339)
+}
+
+// Writer writes Libraries in a particular Format.
+type Writer struct {
+	format  Format
+	columns []column
+	chosen  bool
+}
+
+// NewWriter returns a Writer for the given format and column names. If no
+// columns are given, FormatJSON and FormatJSONL write whole libraries,
+// FormatTSV writes all Columns() and FormatTable writes DefaultTableColumns.
+// Returns an error if the format or any of the columns are invalid.
+func NewWriter(format string, columnNames []string) (*Writer, error) {
+	f, err := StringToFormat(format)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Writer{format: f, chosen: len(columnNames) > 0}
+
+	switch {
+	case w.chosen:
+	case f == FormatTable:
+		columnNames = DefaultTableColumns
+	default:
+		columnNames = Columns()
+	}
+
+	w.columns, err = chooseColumns(columnNames)
+
+	return w, err
+}
+
+// Write writes the given libraries to out in our format.
+func (w *Writer) Write(out io.Writer, libs types.Libraries) error {
+	switch w.format {
+	case FormatJSONL:
+		return w.writeJSONL(out, libs)
+	case FormatTSV:
+		return w.writeTSV(out, libs)
+	case FormatTable:
+		return w.writeTable(out, libs)
+	default:
+		return w.writeJSON(out, libs)
+	}
+}
+
+func (w *Writer) writeJSON(out io.Writer, libs types.Libraries) error {
+	var (
+		data []byte
+		err  error
+	)
+
+	if w.chosen {
+		data, err = w.rowsJSON(libs, "  ")
+	} else {
+		if libs == nil {
+			libs = types.Libraries{}
+		}
+
+		data, err = json.MarshalIndent(libs, "", "  ")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(out, "%s\n", data)
+
+	return err
+}
+
+// rowsJSON returns a JSON array of objects with our columns for each sample
+// run, each on its own line after the given indent.
+func (w *Writer) rowsJSON(libs types.Libraries, indent string) ([]byte, error) {
+	var buf bytes.Buffer
+
+	buf.WriteString("[")
+
+	for i, r := range Rows(libs) {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+
+		obj, err := w.rowJSON(r)
+		if err != nil {
+			return nil, err
+		}
+
+		buf.WriteString("\n" + indent)
+		buf.Write(obj)
+	}
+
+	if buf.Len() > 1 {
+		buf.WriteString("\n")
+	}
+
+	buf.WriteString("]")
+
+	return buf.Bytes(), nil
+}
+
+// rowJSON returns a JSON object with our columns for the given Row, with keys
+// in column order.
+func (w *Writer) rowJSON(r *Row) ([]byte, error) {
+	var buf bytes.Buffer
+
+	buf.WriteString("{")
+
+	for i, c := range w.columns {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+
+		v, err := json.Marshal(c.value(r))
+		if err != nil {
+			return nil, err
+		}
+
+		fmt.Fprintf(&buf, "%q:%s", c.name, v)
+	}
+
+	buf.WriteString("}")
+
+	return buf.Bytes(), nil
+}
+
+func (w *Writer) writeJSONL(out io.Writer, libs types.Libraries) error {
+	if w.chosen {
+		for _, r := range Rows(libs) {
+			obj, err := w.rowJSON(r)
+			if err != nil {
+				return err
+			}
+
+			if _, err = fmt.Fprintf(out, "%s\n", obj); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	enc := json.NewEncoder(out)
+
+	for _, lib := range libs {
+		if err := enc.Encode(lib); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *Writer) writeTSV(out io.Writer, libs types.Libraries) error {
+	if _, err := fmt.Fprintln(out, strings.Join(w.header(), tsvSeparator)); err != nil {
+		return err
+	}
+
+	for _, r := range Rows(libs) {
+		if _, err := fmt.Fprintln(out, strings.Join(w.fields(r), tsvSeparator)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *Writer) header() []string {
+	names := make([]string, len(w.columns))
+
+	for i, c := range w.columns {
+		names[i] = c.name
+	}
+
+	return names
+}
+
+// fields returns the text of our columns for the given Row, with any tabs or
+// newlines replaced with spaces.
+func (w *Writer) fields(r *Row) []string {
+	fields := make([]string, len(w.columns))
+
+	for i, c := range w.columns {
+		fields[i] = strings.Map(func(r rune) rune {
+			if r == '\t' || r == '\n' || r == '\r' {
+				return ' '
+			}
+
+			return r
+		}, text(c.value(r)))
+	}
+
+	return fields
+}
+
+// text returns the given column value as text, with lists of numbers
+// comma-separated.
+func text(v any) string {
+	ints, ok := v.([]int)
+	if !ok {
+		return fmt.Sprint(v)
+	}
+
+	strs := make([]string, len(ints))
+
+	for i, n := range ints {
+		strs[i] = strconv.Itoa(n)
+	}
+
+	return strings.Join(strs, listSeparator)
+}
+
+// writeTable writes an aligned table of our columns for each sample run,
+// followed by a count of the libraries, experiments and sample runs, and how
+// many sample runs have not passed QC.
+func (w *Writer) writeTable(out io.Writer, libs types.Libraries) error {
+	tw := tabwriter.NewWriter(out, tableMinWidth, tableTabWidth, tablePadding, ' ', 0)
+
+	fmt.Fprintln(tw, strings.Join(w.header(), tsvSeparator))
+
+	rows := Rows(libs)
+	notPassed := 0
+
+	for _, r := range rows {
+		fmt.Fprintln(tw, strings.Join(w.fields(r), tsvSeparator))
+
+		if !r.Sample.QCPassed() {
+			notPassed++
+		}
+	}
+
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(out, "\n%d libraries, %d experiments, %d sample runs (%d not QC passed)\n",
+		len(libs), countExperiments(libs), len(rows), notPassed)
+
+	return err
+}
+
+func countExperiments(libs types.Libraries) int {
+	n := 0
+
+	for _, lib := range libs {
+		n += len(lib.Experiments)
+	}
+
+	return n
+}
@@ -0,0 +1,165 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Authors:
+ *	- Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/wtsi-hgi/dimsum-automation/types"
+)
+
+func TestReport(t *testing.T) {
+	Convey("Given some libraries", t, func() {
+		complete := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+		libs := types.Libraries{
+			{
+				LibraryID: "lib1",
+				Experiments: []*types.Experiment{
+					{
+						ExperimentID: "exp1",
+						Assay:        "abundance",
+						Samples: []*types.Sample{
+							{
+								SampleName: "s1", RunID: "100", ManualQC: types.ManualQCPassed, StudyID: "st1",
+								Lanes: []int{1, 2}, RunComplete: complete, Selection: types.SelectionInput,
+								ExperimentReplicate: 1,
+							},
+							{
+								SampleName: "s2\tx", RunID: "101", ManualQC: types.ManualQCFailed,
+								Selection: types.SelectionOutput, ExperimentReplicate: 1,
+							},
+						},
+					},
+				},
+			},
+			{
+				LibraryID: "lib2",
+				Experiments: []*types.Experiment{
+					{ExperimentID: "exp2", Samples: []*types.Sample{{SampleName: "s3", RunID: "102"}}},
+				},
+			},
+		}
+
+		write := func(format string, cols ...string) string {
+			w, err := NewWriter(format, cols)
+			So(err, ShouldBeNil)
+
+			var buf bytes.Buffer
+			So(w.Write(&buf, libs), ShouldBeNil)
+
+			return buf.String()
+		}
+
+		Convey("Rows() flattens them to one per sample run", func() {
+			rows := Rows(libs)
+			So(rows, ShouldHaveLength, 3)
+			So(rows[2].Library.LibraryID, ShouldEqual, "lib2")
+			So(rows[2].Experiment.ExperimentID, ShouldEqual, "exp2")
+			So(rows[2].Sample.SampleName, ShouldEqual, "s3")
+		})
+
+		Convey("JSON is a single document of the libraries", func() {
+			out := write("")
+
+			var decoded types.Libraries
+			So(json.Unmarshal([]byte(out), &decoded), ShouldBeNil)
+			So(decoded, ShouldHaveLength, 2)
+			So(decoded[0].Experiments[0].Samples[1].RunID, ShouldEqual, "101")
+
+			libs = nil
+			So(write("json"), ShouldEqual, "[]\n")
+		})
+
+		Convey("JSON with columns is a single document of sample runs", func() {
+			out := write("json", "run_id", "lanes", "tag_index")
+			So(out, ShouldEqual, `[
+  {"run_id":"100","lanes":[1,2],"tag_index":0},
+  {"run_id":"101","lanes":null,"tag_index":0},
+  {"run_id":"102","lanes":null,"tag_index":0}
+]
+`)
+
+			var decoded []map[string]any
+			So(json.Unmarshal([]byte(out), &decoded), ShouldBeNil)
+			So(decoded, ShouldHaveLength, 3)
+		})
+
+		Convey("JSONL has one library, or sample run, per line", func() {
+			lines := strings.Split(strings.TrimSpace(write("jsonl")), "\n")
+			So(lines, ShouldHaveLength, 2)
+
+			var lib types.Library
+			So(json.Unmarshal([]byte(lines[1]), &lib), ShouldBeNil)
+			So(lib.LibraryID, ShouldEqual, "lib2")
+
+			So(write("jsonl", "library_id", "manual_qc"), ShouldEqual,
+				`{"library_id":"lib1","manual_qc":"pass"}
+{"library_id":"lib1","manual_qc":"fail"}
+{"library_id":"lib2","manual_qc":"pending"}
+`)
+		})
+
+		Convey("TSV has a row per sample run with all columns by default", func() {
+			lines := strings.Split(strings.TrimSpace(write("tsv")), "\n")
+			So(lines, ShouldHaveLength, 4)
+			So(lines[0], ShouldEqual, strings.Join(Columns(), "\t"))
+			So(strings.Split(lines[1], "\t"), ShouldHaveLength, len(Columns()))
+			So(lines[1], ShouldStartWith, "lib1\tst1\t\texp1\tabundance\ts1\t\t100\tpass\t1,2\t0\t\t2025-01-02T03:04:05Z")
+
+			So(write("tsv", "sample_name", "selection"), ShouldEqual,
+				"sample_name\tselection\ns1\tinput\ns2 x\toutput\ns3\t\n")
+		})
+
+		Convey("Table aligns columns and summarises", func() {
+			out := write("table", "library_id", "run_id")
+			So(out, ShouldEqual, `library_id  run_id
+lib1        100
+lib1        101
+lib2        102
+
+2 libraries, 2 experiments, 3 sample runs (2 not QC passed)
+`)
+
+			So(write("table"), ShouldStartWith, strings.Join(DefaultTableColumns, "  "))
+		})
+
+		Convey("Invalid formats and columns are errors", func() {
+			_, err := NewWriter("xml", nil)
+			So(errors.Is(err, ErrInvalidFormat), ShouldBeTrue)
+
+			_, err = NewWriter("tsv", []string{"run_id", "colour"})
+			So(errors.Is(err, ErrInvalidColumn), ShouldBeTrue)
+			So(err.Error(), ShouldContainSubstring, "colour")
+		})
+	})
+}
@@ -0,0 +1,115 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Authors:
+ *	- Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package samples
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/wtsi-hgi/dimsum-automation/mlwh"
+)
+
+// Query specifies which samples in MLWH to get libraries for. Exactly one of
+// its fields should be set.
+type Query struct {
+	// Sponsor is the faculty sponsor of the studies the samples belong to.
+	Sponsor string
+
+	// StudyIDs are the IDs of the studies the samples belong to.
+	StudyIDs []string
+
+	// SampleNames are the names of the desired samples.
+	SampleNames []string
+}
+
+// SponsorQuery returns a Query for the samples of the given sponsor.
+func SponsorQuery(sponsor string) Query {
+	return Query{Sponsor: sponsor}
+}
+
+// StudiesQuery returns a Query for the samples in the given studies.
+func StudiesQuery(ids []string) Query {
+	return Query{StudyIDs: ids}
+}
+
+// SampleNamesQuery returns a Query for the samples with the given names.
+func SampleNamesQuery(names []string) Query {
+	return Query{SampleNames: names}
+}
+
+// validate returns an error unless exactly one of our fields is set.
+func (q Query) validate() error {
+	set := 0
+
+	for _, isSet := range []bool{q.Sponsor != "", len(q.StudyIDs) > 0, len(q.SampleNames) > 0} {
+		if isSet {
+			set++
+		}
+	}
+
+	if set != 1 {
+		return ErrInvalidQuery
+	}
+
+	return nil
+}
+
+// key returns a string that uniquely identifies this query, for caching
+// purposes. The order of (and repeats in) StudyIDs and SampleNames doesn't
+// matter.
+func (q Query) key() string {
+	switch {
+	case len(q.StudyIDs) > 0:
+		return "studies:" + sortedJoin(q.StudyIDs)
+	case len(q.SampleNames) > 0:
+		return "samples:" + sortedJoin(q.SampleNames)
+	default:
+		return "sponsor:" + q.Sponsor
+	}
+}
+
+// sortedJoin returns the unique values of a sorted copy of the given values,
+// joined with commas.
+func sortedJoin(values []string) string {
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+
+	return strings.Join(slices.Compact(sorted), ",")
+}
+
+// fetch gets the MLWH samples for this query using the given MLWHClient.
+func (q Query) fetch(ctx context.Context, mc MLWHClient) ([]*mlwh.Sample, error) {
+	switch {
+	case len(q.StudyIDs) > 0:
+		return mc.SamplesForStudies(ctx, q.StudyIDs)
+	case len(q.SampleNames) > 0:
+		return mc.SamplesByName(ctx, q.SampleNames)
+	default:
+		return mc.SamplesForSponsor(ctx, q.Sponsor)
+	}
+}
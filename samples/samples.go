@@ -27,6 +27,8 @@
 package samples
 
 import (
+	"context"
+	"sort"
 	"sync"
 	"time"
 
@@ -42,13 +44,23 @@ const (
 	ErrInvalidNameRun              = Error("both name and run must be set")
 	ErrNoNameRun                   = Error("no name and run provided")
 	ErrNameRunsNotFound            = Error("no samples found for given names and runs")
-	ErrExpSamplesInMultipleStudies = Error("experiment has samples in multiple studies")
+	ErrExpSamplesInMultipleStudies = Error("library has samples in multiple studies")
+	ErrInvalidStudyConflictPolicy  = Error("invalid study conflict policy")
+	ErrInvalidQuery                = Error("exactly one of sponsor, study IDs or sample names must be queried")
 )
 
 type MLWHClient interface {
 	// SamplesForSponsor returns all samples for the given sponsor, including
 	// study and run information.
-	SamplesForSponsor(sponsor string) ([]*mlwh.Sample, error)
+	SamplesForSponsor(ctx context.Context, sponsor string) ([]*mlwh.Sample, error)
+
+	// SamplesForStudies returns all samples in the given studies, including
+	// study and run information.
+	SamplesForStudies(ctx context.Context, ids []string) ([]*mlwh.Sample, error)
+
+	// SamplesByName returns all samples with the given names, including study
+	// and run information.
+	SamplesByName(ctx context.Context, names []string) ([]*mlwh.Sample, error)
 
 	// Close closes the connection to the MLWH database.
 	Close() error
@@ -62,43 +74,70 @@ type SheetsClient interface {
 	DimSumMetaData(sheetID string) (types.Libraries, error)
 }
 
+// result is what we cache for a query.
+type result struct {
+	libs       types.Libraries
+	conflicts  []*StudyConflict
+	fetched    time.Time
+	prefetched bool
+}
+
+// expired returns true if this result was fetched longer than lifetime ago.
+func (r *result) expired(lifetime time.Duration) bool {
+	return !r.fetched.Add(lifetime).After(time.Now())
+}
+
+// cache holds results by query key. Each result expires lifetime after it was
+// fetched; expired results are evicted when new ones are stored, except for
+// prefetched ones, which are kept until replaced by the next prefetch.
 type cache struct {
-	libs       map[string]types.Libraries
-	lastUpdate time.Time
-	lifetime   time.Duration
-	mu         sync.RWMutex
+	results      map[string]*result
+	lastPrefetch time.Time
+	lifetime     time.Duration
+	mu           sync.RWMutex
 }
 
 func newCache(lifetime time.Duration) *cache {
 	return &cache{
-		libs:     make(map[string]types.Libraries),
+		results:  make(map[string]*result),
 		lifetime: lifetime,
 	}
 }
 
-func (c *cache) getData(sponsor string) (bool, types.Libraries) {
+func (c *cache) getData(key string) (bool, *result) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
-	cached := c.lastUpdate.Add(c.lifetime).After(time.Now())
-	data := c.libs[sponsor]
+	data, ok := c.results[key]
+	cached := ok && !data.expired(c.lifetime)
 
 	return cached, data
 }
 
-func (c *cache) storeData(sponsor string, data types.Libraries) {
+func (c *cache) storeData(key string, data *result) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	c.libs[sponsor] = data
-	c.lastUpdate = time.Now()
+	data.fetched = time.Now()
+
+	if data.prefetched {
+		c.lastPrefetch = data.fetched
+	}
+
+	for k, r := range c.results {
+		if !r.prefetched && r.expired(c.lifetime) {
+			delete(c.results, k)
+		}
+	}
+
+	c.results[key] = data
 }
 
-func (c *cache) lastUpdated() time.Time {
+func (c *cache) lastPrefetched() time.Time {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
-	return c.lastUpdate
+	return c.lastPrefetch
 }
 
 // Client can connect to MLWH and Google Sheets to get sample information.
@@ -106,9 +145,12 @@ type Client struct {
 	mc      MLWHClient
 	sc      SheetsClient
 	sheetID string
+	qc      types.QCPolicy
+	studies StudyConflictPolicy
 	cache   *cache
 
 	stopCh chan struct{}
+	cancel context.CancelFunc
 	stopMu sync.RWMutex
 
 	err   error
@@ -123,34 +165,54 @@ type ClientOptions struct {
 	// CacheLifetime is the maximum age of cached results.
 	CacheLifetime time.Duration
 
+	// QCPolicy determines which samples are returned based on their manual QC
+	// state. Defaults to types.QCPolicyPassOnly.
+	QCPolicy types.QCPolicy
+
+	// StudyConflictPolicy determines what happens to libraries that have
+	// samples in more than one study. Defaults to StudyConflictExclude.
+	StudyConflictPolicy StudyConflictPolicy
+
 	// Prefetch fetches ForSponsor() results for the given sponsors every
 	// CacheLifetime so that you never have to wait for a query and they're as
 	// fresh as possible. Errors are not returned, but can be checked with
-	// Err().
+	// Err(). This is for long-running processes; one-off queries should not
+	// prefetch.
 	Prefetch []string
 }
 
 // New returns a new Client that can connect to MLWH and the google sheet with
 // the given id to retrieve sample information.
-func New(mc MLWHClient, sc SheetsClient, opts ClientOptions) *Client {
+//
+// If prefetching, the first prefetch is done before returning, and it and all
+// later prefetches are cancelled when the given context is, or when you call
+// Close().
+func New(ctx context.Context, mc MLWHClient, sc SheetsClient, opts ClientOptions) *Client {
 	c := &Client{
 		mc:      mc,
 		sc:      sc,
 		sheetID: opts.SheetID,
+		qc:      opts.QCPolicy,
+		studies: opts.StudyConflictPolicy,
 		cache:   newCache(opts.CacheLifetime),
 	}
 
 	if len(opts.Prefetch) > 0 && opts.CacheLifetime > 0 {
-		c.asyncForSponsors(opts.Prefetch)
-		go c.prefetch(opts.CacheLifetime, opts.Prefetch)
+		ctx, c.cancel = context.WithCancel(ctx)
+		c.stopCh = make(chan struct{})
+
+		c.asyncForSponsors(ctx, opts.Prefetch)
+		go c.prefetch(ctx, c.stopCh, opts.CacheLifetime, opts.Prefetch)
 	}
 
 	return c
 }
 
-func (c *Client) asyncForSponsors(sponsors []string) {
+func (c *Client) asyncForSponsors(ctx context.Context, sponsors []string) {
 	for _, sponsor := range sponsors {
-		result, err := c.freshForSponsorQuery(sponsor)
+		q := SponsorQuery(sponsor)
+
+		result, err := c.freshQuery(ctx, q)
 
 		c.errMu.Lock()
 		c.err = err
@@ -160,25 +222,23 @@ func (c *Client) asyncForSponsors(sponsors []string) {
 			return
 		}
 
-		c.cache.storeData(sponsor, result)
+		result.prefetched = true
+		c.cache.storeData(q.key(), result)
 	}
 }
 
-func (c *Client) prefetch(sleepTime time.Duration, sponsors []string) {
-	c.stopMu.Lock()
-	stopCh := make(chan struct{})
-	c.stopCh = stopCh
-	c.stopMu.Unlock()
-
+func (c *Client) prefetch(ctx context.Context, stopCh chan struct{}, sleepTime time.Duration, sponsors []string) {
 	ticker := time.NewTicker(sleepTime)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ticker.C:
-			c.asyncForSponsors(sponsors)
+			c.asyncForSponsors(ctx, sponsors)
 		case <-stopCh:
 			return
+		case <-ctx.Done():
+			return
 		}
 	}
 }
@@ -196,40 +256,90 @@ func (c *Client) Err() error {
 // LastPrefetchSuccess returns the time of the last successful prefetch. If no
 // prefetch has succeeded yet, the zero time is returned.
 func (c *Client) LastPrefetchSuccess() time.Time {
-	return c.cache.lastUpdated()
+	return c.cache.lastPrefetched()
 }
 
 // ForSponsor returns all libraries for the given sponsor that have experiements
-// that have samples where manual_qc is 1 and where there is corresponding
-// metadata in our google sheet. It caches database queries, so results can be
-// up to CacheLifetime old.
+// that have samples acceptable to our QCPolicy (by default, only those where
+// manual_qc is 1) and where there is corresponding metadata in our google
+// sheet. It caches database queries, so results can be up to CacheLifetime
+// old.
+//
+// If you have prefetching enabled for this sponsor, this always returns
+// immediately with the result of the last successful prefetch, which might have
+// been longer than CacheLifetime ago, if the last actual prefetch failed (see
+// Err()).
 //
-// If you have prefetching enabled, this always returns immediately with the
-// result of the last successful prefetch, which might have been longer than
-// CacheLifetime ago, if the last actual prefetch failed (see Err()).
-func (c *Client) ForSponsor(sponsor string) (types.Libraries, error) {
-	cached, result := c.cache.getData(sponsor)
+// The given context bounds any MLWH queries made. If MLWH couldn't be reached,
+// the error will satisfy errors.Is(err, mlwh.ErrUnavailable).
+func (c *Client) ForSponsor(ctx context.Context, sponsor string) (types.Libraries, error) {
+	return c.Libraries(ctx, SponsorQuery(sponsor))
+}
+
+// ForStudies is like ForSponsor(), but for the samples in the studies with the
+// given IDs, regardless of their sponsor.
+func (c *Client) ForStudies(ctx context.Context, ids []string) (types.Libraries, error) {
+	return c.Libraries(ctx, StudiesQuery(ids))
+}
+
+// ForSampleNames is like ForSponsor(), but for the samples with the given
+// names, regardless of their study or sponsor.
+func (c *Client) ForSampleNames(ctx context.Context, names []string) (types.Libraries, error) {
+	return c.Libraries(ctx, SampleNamesQuery(names))
+}
+
+// Libraries is like ForSponsor(), but for the samples specified by the given
+// Query.
+func (c *Client) Libraries(ctx context.Context, q Query) (types.Libraries, error) {
+	r, err := c.query(ctx, q)
+	if err != nil || r == nil {
+		return nil, err
+	}
+
+	return r.libs, nil
+}
+
+// StudyConflicts returns details of the libraries for the given Query that had
+// samples in more than one study, as found by the last query or prefetch.
+// Depending on our StudyConflictPolicy, these libraries will have been excluded
+// from, split in, or left alone in the Libraries() results.
+func (c *Client) StudyConflicts(ctx context.Context, q Query) ([]*StudyConflict, error) {
+	r, err := c.query(ctx, q)
+	if err != nil || r == nil {
+		return nil, err
+	}
+
+	return r.conflicts, nil
+}
+
+func (c *Client) query(ctx context.Context, q Query) (*result, error) {
+	if err := q.validate(); err != nil {
+		return nil, err
+	}
+
+	key := q.key()
+	cached, r := c.cache.getData(key)
 
 	c.stopMu.RLock()
 	stopCh := c.stopCh
 	c.stopMu.RUnlock()
 
-	if !cached && stopCh == nil {
+	if !cached && (stopCh == nil || r == nil || !r.prefetched) {
 		var err error
 
-		result, err = c.freshForSponsorQuery(sponsor)
+		r, err = c.freshQuery(ctx, q)
 		if err != nil {
 			return nil, err
 		}
 
-		c.cache.storeData(sponsor, result)
+		c.cache.storeData(key, r)
 	}
 
-	return result, nil
+	return r, nil
 }
 
-func (c *Client) freshForSponsorQuery(sponsor string) (types.Libraries, error) {
-	samples, err := c.mc.SamplesForSponsor(sponsor)
+func (c *Client) freshQuery(ctx context.Context, q Query) (*result, error) {
+	samples, err := q.fetch(ctx, c.mc)
 	if err != nil {
 		return nil, err
 	}
@@ -239,45 +349,27 @@ func (c *Client) freshForSponsorQuery(sponsor string) (types.Libraries, error) {
 		return nil, err
 	}
 
-	mlwhSampleLookup := make(map[string][]int, len(samples))
+	mlwhSampleLookup := make(map[string][]*mlwh.Sample, len(samples))
 
-	for i, s := range samples {
-		indexes, ok := mlwhSampleLookup[s.SampleName]
-		if !ok {
-			indexes = make([]int, 0, 1)
-		}
+	for _, s := range samples {
+		mlwhSampleLookup[s.SampleName] = append(mlwhSampleLookup[s.SampleName], s)
+	}
 
-		indexes = append(indexes, i)
-		mlwhSampleLookup[s.SampleName] = indexes
+	for _, runs := range mlwhSampleLookup {
+		sortSampleRuns(runs)
 	}
 
-	filteredLibs := make(types.Libraries, 0, len(libs))
+	r := &result{libs: make(types.Libraries, 0, len(libs))}
 
 	for _, lib := range libs {
 		goodExps := make([]*types.Experiment, 0, len(lib.Experiments))
-		studies := make(map[string]string, 1)
 
 		for _, exp := range lib.Experiments {
 			goodSamples := make([]*types.Sample, 0, len(exp.Samples))
 
 			for _, sample := range exp.Samples {
-				indexes, ok := mlwhSampleLookup[sample.SampleName]
-				if !ok {
-					continue
-				}
-
-				for i, index := range indexes {
-					mlwhSample := samples[index]
-					studies[mlwhSample.StudyID] = mlwhSample.StudyName
-
-					thisSample := sample.Clone()
-					thisSample.SampleID = mlwhSample.SampleID
-					thisSample.RunID = mlwhSample.RunID
-					thisSample.ManualQC = mlwhSample.ManualQC
-					thisSample.TechnicalReplicate = i + 1
-
-					goodSamples = append(goodSamples, thisSample)
-				}
+				goodSamples = append(goodSamples,
+					c.sampleRuns(sample, mlwhSampleLookup[sample.SampleName])...)
 			}
 
 			if len(goodSamples) > 0 {
@@ -287,37 +379,137 @@ func (c *Client) freshForSponsorQuery(sponsor string) (types.Libraries, error) {
 		}
 
 		if len(goodExps) > 0 {
-			if len(studies) != 1 {
-				return nil, ErrExpSamplesInMultipleStudies
-			}
+			lib.Experiments = goodExps
+			c.addLibrary(r, lib)
+		}
+	}
 
-			var sid, sname string
+	return r, nil
+}
 
-			for k, v := range studies {
-				sid, sname = k, v
-			}
+// sortSampleRuns sorts the given MLWH rows for a single sample by run ID, then
+// lane, so that technical replicates can be assigned deterministically.
+func sortSampleRuns(runs []*mlwh.Sample) {
+	sort.SliceStable(runs, func(i, j int) bool {
+		if c := types.CompareRunIDs(runs[i].RunID, runs[j].RunID); c != 0 {
+			return c < 0
+		}
 
-			lib.StudyID = sid
-			lib.StudyName = sname
-			lib.Experiments = goodExps
-			filteredLibs = append(filteredLibs, lib)
+		return runs[i].Lane < runs[j].Lane
+	})
+}
+
+// sampleRuns returns a clone of the given sheet sample for each of the given
+// MLWH runs of that sample (which must have been sorted with sortSampleRuns())
+// that are acceptable to our QCPolicy.
+//
+// Multiple lanes of the same run are treated as a single sample run, with the
+// details of the first lane, all the Lanes, and a ManualQC of all of them (see
+// runManualQC()). Technical replicates are
+// numbered in run order, considering all runs regardless of their QC state, so
+// that they remain stable. If the sheet sample has a RunID, only that run is
+// returned, and if it has a TechnicalReplicate, that is used instead of the
+// numbering.
+func (c *Client) sampleRuns(sample *types.Sample, runs []*mlwh.Sample) []*types.Sample {
+	groups := groupLanes(runs)
+	sampleRuns := make([]*types.Sample, 0, len(groups))
+
+	for i, lanes := range groups {
+		if sample.RunID != "" && sample.RunID != lanes[0].RunID {
+			continue
+		}
+
+		thisSample := mergeSample(sample, lanes)
+		if !c.qc.Accepts(thisSample) {
+			continue
 		}
+
+		if thisSample.TechnicalReplicate == 0 {
+			thisSample.TechnicalReplicate = i + 1
+		}
+
+		sampleRuns = append(sampleRuns, thisSample)
 	}
 
-	return filteredLibs, nil
+	return sampleRuns
 }
 
-// Close closes database connections and stops prefetching.
-func (c *Client) Close() error {
-	err := c.mc.Close()
+// groupLanes groups the given sorted MLWH rows for a single sample by run,
+// returning a slice of the lanes of each run.
+func groupLanes(runs []*mlwh.Sample) [][]*mlwh.Sample {
+	var groups [][]*mlwh.Sample
+
+	for i, mlwhSample := range runs {
+		if i > 0 && mlwhSample.RunID == runs[i-1].RunID {
+			groups[len(groups)-1] = append(groups[len(groups)-1], mlwhSample)
 
+			continue
+		}
+
+		groups = append(groups, []*mlwh.Sample{mlwhSample})
+	}
+
+	return groups
+}
+
+// runManualQC returns the ManualQC of a run with the given lanes: failed if any
+// lane failed, passed if every lane passed, otherwise pending.
+func runManualQC(lanes []*mlwh.Sample) string {
+	qc := types.ManualQCPassed
+
+	for _, lane := range lanes {
+		switch {
+		case lane.QCFailed():
+			return types.ManualQCFailed
+		case !lane.QCPassed():
+			qc = types.ManualQCPending
+		}
+	}
+
+	return qc
+}
+
+// mergeSample returns a clone of the given sheet sample with the MLWH details
+// of the first of the given lanes, the Lanes of all of them, and their
+// combined runManualQC().
+func mergeSample(sample *types.Sample, lanes []*mlwh.Sample) *types.Sample {
+	first := lanes[0]
+
+	thisSample := sample.Clone()
+	thisSample.SampleID = first.SampleID
+	thisSample.StudyID = first.StudyID
+	thisSample.StudyName = first.StudyName
+	thisSample.RunID = first.RunID
+	thisSample.ManualQC = runManualQC(lanes)
+	thisSample.TagIndex = first.TagIndex
+	thisSample.InstrumentModel = first.InstrumentModel
+	thisSample.RunComplete = first.RunComplete
+	thisSample.ReadLength = first.ReadLength
+	thisSample.PipelineIDLims = first.PipelineIDLims
+	thisSample.Lanes = make([]int, len(lanes))
+
+	for i, lane := range lanes {
+		thisSample.Lanes[i] = lane.Lane
+	}
+
+	return thisSample
+}
+
+// Close stops prefetching, cancelling any prefetch in progress, and closes
+// database connections.
+func (c *Client) Close() error {
 	c.stopMu.Lock()
-	defer c.stopMu.Unlock()
+
+	if c.cancel != nil {
+		c.cancel()
+	}
 
 	if c.stopCh != nil {
 		close(c.stopCh)
 		c.stopCh = nil
 	}
 
-	return err
+	c.stopMu.Unlock()
+
+	return c.mc.Close()
 }
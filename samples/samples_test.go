@@ -27,6 +27,10 @@
 package samples
 
 import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
 	"sync"
 	"testing"
 	"time"
@@ -50,8 +54,12 @@ type mockMLWH struct {
 	mu        sync.RWMutex
 }
 
-func (m *mockMLWH) SamplesForSponsor(sponsor string) ([]*mlwh.Sample, error) {
-	time.Sleep(m.queryTime)
+func (m *mockMLWH) SamplesForSponsor(ctx context.Context, _ string) ([]*mlwh.Sample, error) {
+	select {
+	case <-time.After(m.queryTime):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
 
 	m.mu.RLock()
 	defer m.mu.RUnlock()
@@ -59,6 +67,31 @@ func (m *mockMLWH) SamplesForSponsor(sponsor string) ([]*mlwh.Sample, error) {
 	return m.msamples, m.err
 }
 
+func (m *mockMLWH) SamplesForStudies(ctx context.Context, ids []string) ([]*mlwh.Sample, error) {
+	return m.samplesMatching(ctx, func(s *mlwh.Sample) bool { return slices.Contains(ids, s.StudyID) })
+}
+
+func (m *mockMLWH) SamplesByName(ctx context.Context, names []string) ([]*mlwh.Sample, error) {
+	return m.samplesMatching(ctx, func(s *mlwh.Sample) bool { return slices.Contains(names, s.SampleName) })
+}
+
+func (m *mockMLWH) samplesMatching(ctx context.Context, keep func(*mlwh.Sample) bool) ([]*mlwh.Sample, error) {
+	all, err := m.SamplesForSponsor(ctx, sponsor)
+	if err != nil {
+		return nil, err
+	}
+
+	var matching []*mlwh.Sample
+
+	for _, s := range all {
+		if keep(s) {
+			matching = append(matching, s)
+		}
+	}
+
+	return matching, nil
+}
+
 func (m *mockMLWH) setSamples(samples []*mlwh.Sample) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -106,10 +139,13 @@ func (m *mockSheets) DimSumMetaData(sheetID string) (types.Libraries, error) {
 
 func TestSamplesMock(t *testing.T) {
 	Convey("Given mock mlwh and sheets connections", t, func() {
+		ctx := context.Background()
+
 		msamples := []*mlwh.Sample{
 			{
 				StudyID:   "studyID1",
 				StudyName: "study1",
+				Lane:      1,
 				Sample: types.Sample{
 					SampleID:   "sampleID1a",
 					SampleName: "sample1",
@@ -120,6 +156,7 @@ func TestSamplesMock(t *testing.T) {
 			{
 				StudyID:   "studyID1",
 				StudyName: "study1",
+				Lane:      1,
 				Sample: types.Sample{
 					SampleID:   "sampleID1b",
 					SampleName: "sample1",
@@ -130,6 +167,7 @@ func TestSamplesMock(t *testing.T) {
 			{
 				StudyID:   "studyID1",
 				StudyName: "study1",
+				Lane:      1,
 				Sample: types.Sample{
 					SampleID:   "sampleID2",
 					SampleName: "sample2",
@@ -140,6 +178,7 @@ func TestSamplesMock(t *testing.T) {
 			{
 				StudyID:   "studyID1",
 				StudyName: "study1",
+				Lane:      1,
 				Sample: types.Sample{
 					SampleID:   "sampleID3",
 					SampleName: "sample3",
@@ -150,6 +189,7 @@ func TestSamplesMock(t *testing.T) {
 			{
 				StudyID:   "studyID1",
 				StudyName: "study1",
+				Lane:      1,
 				Sample: types.Sample{
 					SampleID:   "sampleID4",
 					SampleName: "sample4",
@@ -160,6 +200,7 @@ func TestSamplesMock(t *testing.T) {
 			{
 				StudyID:   "studyID2",
 				StudyName: "study2",
+				Lane:      1,
 				Sample: types.Sample{
 					SampleID:   "sampleID5",
 					SampleName: "sample5",
@@ -167,6 +208,16 @@ func TestSamplesMock(t *testing.T) {
 					ManualQC:   "1",
 				},
 			},
+			{
+				StudyID:   "studyID1",
+				StudyName: "study1",
+				Lane:      1,
+				Sample: types.Sample{
+					SampleID:   "sampleID6",
+					SampleName: "sample6",
+					RunID:      "run6",
+				},
+			},
 		}
 		mlwhQueryTime := 100 * time.Millisecond
 		mclient := &mockMLWH{msamples: msamples, queryTime: mlwhQueryTime}
@@ -220,7 +271,7 @@ func TestSamplesMock(t *testing.T) {
 		sclient := &mockSheets{smeta: []*types.Library{lib1, lib2}}
 
 		allowedAge := 2 * mlwhQueryTime
-		c := New(mclient, sclient, ClientOptions{
+		c := New(ctx, mclient, sclient, ClientOptions{
 			SheetID:       "sheetID",
 			CacheLifetime: allowedAge,
 			Prefetch:      []string{sponsor},
@@ -234,7 +285,7 @@ func TestSamplesMock(t *testing.T) {
 		Convey("You can get info about samples belonging to a given sponsor", func() {
 			start := time.Now()
 
-			mergedLibs, err := c.ForSponsor(sponsor)
+			mergedLibs, err := c.ForSponsor(ctx, sponsor)
 			So(err, ShouldBeNil)
 			So(len(mergedLibs), ShouldEqual, 2)
 
@@ -250,39 +301,35 @@ func TestSamplesMock(t *testing.T) {
 								{
 									SampleName:          "sample1",
 									SampleID:            "sampleID1a",
+									StudyID:             "studyID1",
+									StudyName:           "study1",
 									RunID:               "run1a",
 									ExperimentReplicate: 1,
 									TechnicalReplicate:  1,
 									ManualQC:            "1",
+									Lanes:               []int{1},
 								},
 								{
 									SampleName:          "sample1",
 									SampleID:            "sampleID1b",
+									StudyID:             "studyID1",
+									StudyName:           "study1",
 									RunID:               "run1b",
 									ExperimentReplicate: 1,
 									TechnicalReplicate:  2,
 									ManualQC:            "1",
+									Lanes:               []int{1},
 								},
 								{
 									SampleName:          "sample3",
 									SampleID:            "sampleID3",
+									StudyID:             "studyID1",
+									StudyName:           "study1",
 									RunID:               "run3",
 									ExperimentReplicate: 2,
 									TechnicalReplicate:  1,
 									ManualQC:            "1",
-								},
-							},
-						},
-						{
-							ExperimentID: "exp2",
-							Samples: []*types.Sample{
-								{
-									SampleName:          "sample4",
-									SampleID:            "sampleID4",
-									RunID:               "run4",
-									ExperimentReplicate: 3,
-									TechnicalReplicate:  1,
-									ManualQC:            "0",
+									Lanes:               []int{1},
 								},
 							},
 						},
@@ -299,10 +346,13 @@ func TestSamplesMock(t *testing.T) {
 								{
 									SampleName:          "sample5",
 									SampleID:            "sampleID5",
+									StudyID:             "studyID2",
+									StudyName:           "study2",
 									RunID:               "run5",
 									ExperimentReplicate: 5,
 									TechnicalReplicate:  1,
 									ManualQC:            "1",
+									Lanes:               []int{1},
 								},
 							},
 						},
@@ -319,7 +369,7 @@ func TestSamplesMock(t *testing.T) {
 				time.Sleep(mlwhQueryTime / 2)
 
 				start = time.Now()
-				cachedLibs, err := c.ForSponsor(sponsor)
+				cachedLibs, err := c.ForSponsor(ctx, sponsor)
 				So(err, ShouldBeNil)
 				So(cachedLibs, ShouldResemble, mergedLibs)
 
@@ -331,7 +381,7 @@ func TestSamplesMock(t *testing.T) {
 					time.Sleep(allowedAge * 2)
 
 					start = time.Now()
-					freshLibs, err := c.ForSponsor(sponsor)
+					freshLibs, err := c.ForSponsor(ctx, sponsor)
 					So(err, ShouldBeNil)
 					So(len(freshLibs), ShouldEqual, 1)
 					So(freshLibs[0], ShouldResemble, &types.Library{
@@ -345,10 +395,13 @@ func TestSamplesMock(t *testing.T) {
 									{
 										SampleName:          "sample1",
 										SampleID:            "sampleID1a",
+										StudyID:             "studyID1",
+										StudyName:           "study1",
 										RunID:               "run1a",
 										ExperimentReplicate: 1,
 										TechnicalReplicate:  1,
 										ManualQC:            "1",
+										Lanes:               []int{1},
 									},
 								},
 							},
@@ -367,7 +420,7 @@ func TestSamplesMock(t *testing.T) {
 
 					So(c.Err(), ShouldEqual, errMock)
 
-					freshLibs, err := c.ForSponsor(sponsor)
+					freshLibs, err := c.ForSponsor(ctx, sponsor)
 					So(err, ShouldBeNil)
 					So(len(freshLibs), ShouldEqual, 2)
 					So(c.Err(), ShouldEqual, errMock)
@@ -375,6 +428,38 @@ func TestSamplesMock(t *testing.T) {
 				})
 			})
 
+			Convey("Only QC passed samples are returned by default, but you can choose a different QCPolicy", func() {
+				So(mergedLibs[0].Experiments, ShouldHaveLength, 1)
+
+				for _, policy := range []types.QCPolicy{types.QCPolicyIncludeFailed, types.QCPolicyIncludePending} {
+					pc := New(ctx, mclient, sclient, ClientOptions{
+						SheetID:  "sheetID",
+						QCPolicy: policy,
+					})
+
+					libs, err := pc.ForSponsor(ctx, sponsor)
+					So(err, ShouldBeNil)
+					So(libs, ShouldHaveLength, 2)
+					So(libs[0].Experiments, ShouldHaveLength, 2)
+
+					exp2 := libs[0].Experiments[1]
+					So(exp2.ExperimentID, ShouldEqual, "exp2")
+					So(exp2.Samples, ShouldHaveLength, 1)
+
+					if policy == types.QCPolicyIncludeFailed {
+						So(exp2.Samples[0].SampleName, ShouldEqual, "sample4")
+						So(exp2.Samples[0].QCFailed(), ShouldBeTrue)
+					} else {
+						So(exp2.Samples[0].SampleName, ShouldEqual, "sample6")
+						So(exp2.Samples[0].QCPending(), ShouldBeTrue)
+					}
+
+					So(exp2.Samples[0].TechnicalReplicate, ShouldEqual, 1)
+
+					pc.Close()
+				}
+			})
+
 			Convey("You can filter those for desired samples", func() {
 				subset, err := mergedLibs.Subset([]*types.Sample{
 					{SampleName: msamples[0].SampleName, RunID: msamples[0].RunID},
@@ -396,19 +481,334 @@ func TestSamplesMock(t *testing.T) {
 				So(samples[1].RunID, ShouldEqual, msamples[3].RunID)
 			})
 		})
+
+		Convey("You can get info about samples in given studies, or with given names", func() {
+			libs, err := c.ForStudies(ctx, []string{"studyID2"})
+			So(err, ShouldBeNil)
+			So(libs, ShouldHaveLength, 1)
+			So(libs[0].LibraryID, ShouldEqual, "lib2")
+			So(libs[0].Experiments[0].Samples[0].SampleName, ShouldEqual, "sample5")
+
+			libs, err = c.ForSampleNames(ctx, []string{"sample1", "sample5"})
+			So(err, ShouldBeNil)
+			So(libs, ShouldHaveLength, 2)
+			So(libs[0].Experiments[0].Samples, ShouldHaveLength, 2)
+			So(libs[0].Experiments[0].Samples[0].SampleName, ShouldEqual, "sample1")
+			So(libs[1].Experiments[0].Samples[0].SampleName, ShouldEqual, "sample5")
+
+			libs, err = c.ForStudies(ctx, []string{"unknown"})
+			So(err, ShouldBeNil)
+			So(libs, ShouldBeEmpty)
+
+			_, err = c.Libraries(ctx, Query{})
+			So(err, ShouldEqual, ErrInvalidQuery)
+
+			_, err = c.Libraries(ctx, Query{Sponsor: sponsor, StudyIDs: []string{"studyID2"}})
+			So(err, ShouldEqual, ErrInvalidQuery)
+
+			Convey("Which expire independently of the prefetched results", func() {
+				_, err = c.ForStudies(ctx, []string{"studyID2"})
+				So(err, ShouldBeNil)
+
+				mclient.setSamples(msamples[0:1])
+
+				libs, err = c.ForStudies(ctx, []string{"studyID2"})
+				So(err, ShouldBeNil)
+				So(libs, ShouldHaveLength, 1)
+
+				time.Sleep(allowedAge * 2)
+
+				libs, err = c.ForStudies(ctx, []string{"studyID2"})
+				So(err, ShouldBeNil)
+				So(libs, ShouldBeEmpty)
+				So(c.LastPrefetchSuccess(), ShouldHappenAfter, createTime)
+			})
+		})
+	})
+
+	Convey("Queries for the same studies or samples in any order share a cache key", t, func() {
+		studies := []string{"studyB", "studyA"}
+		q := StudiesQuery(studies)
+		So(q.key(), ShouldEqual, StudiesQuery([]string{"studyA", "studyB", "studyA"}).key())
+		So(q.key(), ShouldNotEqual, StudiesQuery([]string{"studyA"}).key())
+		So(studies, ShouldResemble, []string{"studyB", "studyA"})
+
+		So(SampleNamesQuery([]string{"s2", "s1"}).key(), ShouldEqual, SampleNamesQuery([]string{"s1", "s2"}).key())
+		So(SampleNamesQuery([]string{"s1"}).key(), ShouldNotEqual, StudiesQuery([]string{"s1"}).key())
+	})
+
+	Convey("Cached results expire individually and are evicted once expired", t, func() {
+		lifetime := 50 * time.Millisecond
+		c := newCache(lifetime)
+
+		c.storeData("a", &result{})
+		time.Sleep(lifetime / 2)
+		c.storeData("b", &result{})
+		c.storeData("p", &result{prefetched: true})
+
+		cached, _ := c.getData("a")
+		So(cached, ShouldBeTrue)
+
+		time.Sleep(lifetime / 2)
+
+		cached, r := c.getData("a")
+		So(cached, ShouldBeFalse)
+		So(r, ShouldNotBeNil)
+
+		cached, _ = c.getData("b")
+		So(cached, ShouldBeTrue)
+
+		time.Sleep(lifetime)
+		c.storeData("c", &result{})
+
+		So(c.results, ShouldHaveLength, 2)
+		So(c.results, ShouldContainKey, "c")
+		So(c.results, ShouldContainKey, "p")
+
+		cached, _ = c.getData("p")
+		So(cached, ShouldBeFalse)
+		So(c.lastPrefetched(), ShouldHappenBefore, time.Now().Add(-lifetime))
+	})
+}
+
+func TestPrefetchCancellation(t *testing.T) {
+	Convey("Prefetches are cancelled with New's context", t, func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		c := New(ctx, &mockMLWH{queryTime: time.Hour}, &mockSheets{}, ClientOptions{
+			CacheLifetime: time.Hour,
+			Prefetch:      []string{sponsor},
+		})
+
+		defer c.Close()
+
+		So(time.Since(start), ShouldBeLessThan, time.Second)
+		So(errors.Is(c.Err(), context.DeadlineExceeded), ShouldBeTrue)
+		So(c.LastPrefetchSuccess().IsZero(), ShouldBeTrue)
+	})
+
+	Convey("Close cancels an in-progress prefetch", t, func() {
+		queryTime := 200 * time.Millisecond
+		c := New(context.Background(), &mockMLWH{queryTime: queryTime}, &mockSheets{}, ClientOptions{
+			CacheLifetime: queryTime / 10,
+			Prefetch:      []string{sponsor},
+		})
+		So(c.Err(), ShouldBeNil)
+
+		time.Sleep(queryTime / 5)
+		So(c.Close(), ShouldBeNil)
+
+		time.Sleep(queryTime / 10)
+		So(errors.Is(c.Err(), context.Canceled), ShouldBeTrue)
+	})
+}
+
+func TestTechnicalReplicates(t *testing.T) {
+	Convey("Given a sample sequenced in multiple runs and lanes", t, func() {
+		ctx := context.Background()
+
+		newRow := func(runID string, lane int) *mlwh.Sample {
+			return &mlwh.Sample{
+				StudyID:   "studyID1",
+				StudyName: "study1",
+				Lane:      lane,
+				Sample: types.Sample{
+					SampleID:   "sampleID1",
+					SampleName: "sample1",
+					RunID:      runID,
+					ManualQC:   types.ManualQCPassed,
+				},
+			}
+		}
+
+		rows := []*mlwh.Sample{
+			newRow("100", 2),
+			newRow("9", 1),
+			newRow("100", 1),
+			newRow("20", 1),
+		}
+
+		sheetSample := &types.Sample{SampleName: "sample1", ExperimentReplicate: 1}
+		sclient := &mockSheets{smeta: []*types.Library{{
+			LibraryID: "lib1",
+			Experiments: []*types.Experiment{{
+				ExperimentID: "exp1",
+				Samples:      []*types.Sample{sheetSample},
+			}},
+		}}}
+
+		techReps := func(msamples []*mlwh.Sample) map[string]int {
+			c := New(ctx, &mockMLWH{msamples: msamples}, sclient, ClientOptions{SheetID: "sheetID"})
+			defer c.Close()
+
+			libs, err := c.ForSponsor(ctx, sponsor)
+			So(err, ShouldBeNil)
+			So(libs, ShouldHaveLength, 1)
+
+			reps := make(map[string]int)
+
+			for _, s := range libs[0].Experiments[0].Samples {
+				reps[s.RunID] = s.TechnicalReplicate
+			}
+
+			return reps
+		}
+
+		expected := map[string]int{"9": 1, "20": 2, "100": 3}
+
+		Convey("Technical replicates are assigned by run ID then lane, regardless of row order", func() {
+			So(techReps(rows), ShouldResemble, expected)
+
+			rows[2].InstrumentModel = "NovaSeq"
+			rows[2].TagIndex = 3
+
+			c := New(ctx, &mockMLWH{msamples: rows}, sclient, ClientOptions{SheetID: "sheetID"})
+			defer c.Close()
+
+			libs, err := c.ForSponsor(ctx, sponsor)
+			So(err, ShouldBeNil)
+
+			run100 := libs[0].Experiments[0].Samples[2]
+			So(run100.RunID, ShouldEqual, "100")
+			So(run100.Lanes, ShouldResemble, []int{1, 2})
+			So(run100.InstrumentModel, ShouldEqual, "NovaSeq")
+			So(run100.TagIndex, ShouldEqual, 3)
+
+			reversed := make([]*mlwh.Sample, len(rows))
+			for i, row := range rows {
+				reversed[len(rows)-1-i] = row
+			}
+
+			So(techReps(reversed), ShouldResemble, expected)
+			So(techReps([]*mlwh.Sample{rows[3], rows[0], rows[1], rows[2]}), ShouldResemble, expected)
+		})
+
+		Convey("Technical replicates ignore QC state, so remain stable when runs fail QC", func() {
+			failed := newRow("20", 1)
+			failed.ManualQC = types.ManualQCFailed
+
+			So(techReps([]*mlwh.Sample{rows[0], rows[1], rows[2], failed}), ShouldResemble,
+				map[string]int{"9": 1, "100": 3})
+		})
+
+		Convey("A run's QC state comes from all of its lanes", func() {
+			qcStates := func(policy types.QCPolicy) map[string]string {
+				c := New(ctx, &mockMLWH{msamples: rows}, sclient, ClientOptions{SheetID: "sheetID", QCPolicy: policy})
+				defer c.Close()
+
+				libs, err := c.ForSponsor(ctx, sponsor)
+				So(err, ShouldBeNil)
+
+				states := make(map[string]string)
+
+				for _, s := range libs[0].Experiments[0].Samples {
+					states[s.RunID] = s.QCState()
+				}
+
+				return states
+			}
+
+			rows[0].ManualQC = types.ManualQCFailed
+
+			So(qcStates(types.QCPolicyPassOnly), ShouldResemble,
+				map[string]string{"9": types.QCStatePassed, "20": types.QCStatePassed})
+			So(qcStates(types.QCPolicyIncludeFailed)["100"], ShouldEqual, types.QCStateFailed)
+
+			rows[0].ManualQC = types.ManualQCPassed
+			rows[2].ManualQC = types.ManualQCFailed
+
+			So(qcStates(types.QCPolicyPassOnly), ShouldNotContainKey, "100")
+			So(qcStates(types.QCPolicyIncludeFailed)["100"], ShouldEqual, types.QCStateFailed)
+
+			rows[2].ManualQC = types.ManualQCPending
+
+			So(qcStates(types.QCPolicyIncludeFailed), ShouldNotContainKey, "100")
+			So(qcStates(types.QCPolicyIncludePending)["100"], ShouldEqual, types.QCStatePending)
+
+			rows[0].ManualQC = types.ManualQCFailed
+
+			So(qcStates(types.QCPolicyIncludePending), ShouldNotContainKey, "100")
+			So(qcStates(types.QCPolicyIncludeFailed)["100"], ShouldEqual, types.QCStateFailed)
+		})
+
+		Convey("The sheet can restrict a sample to a run and override its technical replicate", func() {
+			sheetSample.RunID = "20"
+			sheetSample.TechnicalReplicate = 5
+
+			So(techReps(rows), ShouldResemble, map[string]int{"20": 5})
+
+			sheetSample.TechnicalReplicate = 0
+
+			So(techReps(rows), ShouldResemble, map[string]int{"20": 2})
+		})
+	})
+}
+
+func TestSamplesSQLite(t *testing.T) {
+	Convey("Given a SQLite mlwh seeded from a fixture, and mock sheets", t, func() {
+		ctx := context.Background()
+
+		f, err := mlwh.LoadFixture(filepath.Join("..", "mlwh", "testdata", "mlwh.json"))
+		So(err, ShouldBeNil)
+
+		mclient, err := mlwh.NewSQLite(":memory:")
+		So(err, ShouldBeNil)
+
+		err = mclient.Seed(ctx, f)
+		So(err, ShouldBeNil)
+
+		sclient := &mockSheets{smeta: []*types.Library{{
+			LibraryID: "lib1",
+			Experiments: []*types.Experiment{{
+				ExperimentID: "exp1",
+				Samples: []*types.Sample{
+					{SampleName: "DMS_sample1", ExperimentReplicate: 1},
+					{SampleName: "DMS_sample2", ExperimentReplicate: 2},
+				},
+			}},
+		}}}
+
+		c := New(ctx, mclient, sclient, ClientOptions{SheetID: "sheetID"})
+		defer c.Close()
+
+		Convey("You can get merged info about samples belonging to a given sponsor", func() {
+			libs, err := c.ForSponsor(ctx, sponsor)
+			So(err, ShouldBeNil)
+			So(libs, ShouldHaveLength, 1)
+			So(libs[0].StudyID, ShouldEqual, "7000")
+
+			samples := libs[0].Experiments[0].Samples
+			So(samples, ShouldHaveLength, 1)
+			So(samples[0].SampleName, ShouldEqual, "DMS_sample1")
+			So(samples[0].RunID, ShouldEqual, "50001")
+			So(samples[0].Lanes, ShouldResemble, []int{1, 2})
+			So(samples[0].InstrumentModel, ShouldEqual, "NovaSeqX")
+		})
+
+		Convey("Queries stop if the context is cancelled", func() {
+			cctx, cancel := context.WithCancel(ctx)
+			cancel()
+
+			_, err := c.ForStudies(cctx, []string{"7000"})
+			So(errors.Is(err, context.Canceled), ShouldBeTrue)
+		})
 	})
 }
 
 func TestSamplesReal(t *testing.T) {
 	c, err := config.FromEnv("..")
-	if err != nil {
-		SkipConvey("skipping real samples tests without DIMSUM_AUTOMATION_* set", t, func() {})
+	if err != nil || c.SQLitePath != "" || c.SheetPath != "" {
+		SkipConvey("skipping real samples tests without DIMSUM_AUTOMATION_SQL_* set", t, func() {})
 
 		return
 	}
 
 	Convey("Given mlwh and sheets connections", t, func() {
-		mlwh, err := mlwh.New(mlwh.MySQLConfigFromConfig(c))
+		ctx := context.Background()
+
+		mlwh, err := mlwh.New(ctx, mlwh.MySQLConfigFromConfig(c), mlwh.Options{})
 		So(err, ShouldBeNil)
 
 		sc, err := sheets.ServiceCredentialsFromConfig(c)
@@ -417,14 +817,14 @@ func TestSamplesReal(t *testing.T) {
 		s, err := sheets.New(sc)
 		So(err, ShouldBeNil)
 
-		c := New(mlwh, s, ClientOptions{
+		c := New(ctx, mlwh, s, ClientOptions{
 			SheetID:       c.SheetID,
 			CacheLifetime: 1 * time.Minute,
 		})
 
 		Convey("You can get un-cached, un-prefetched info about samples belonging to a given sponsor", func() {
 			start := time.Now()
-			libs, err := c.ForSponsor(sponsor)
+			libs, err := c.ForSponsor(ctx, sponsor)
 			So(err, ShouldBeNil)
 			So(len(libs), ShouldBeGreaterThan, 0)
 
@@ -459,7 +859,7 @@ func TestSamplesReal(t *testing.T) {
 
 			Convey("Which is then cached and filterable", func() {
 				start = time.Now()
-				cachedLibs, err := c.ForSponsor(sponsor)
+				cachedLibs, err := c.ForSponsor(ctx, sponsor)
 				So(err, ShouldBeNil)
 				So(cachedLibs, ShouldResemble, libs)
 				So(time.Since(start), ShouldBeLessThan, 100*time.Millisecond)
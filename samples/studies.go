@@ -0,0 +1,192 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Authors:
+ *	- Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package samples
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/wtsi-hgi/dimsum-automation/types"
+)
+
+// StudyConflictPolicy determines what happens to a library that has samples in
+// more than one study.
+type StudyConflictPolicy string
+
+const (
+	// StudyConflictExclude excludes the library from results.
+	StudyConflictExclude StudyConflictPolicy = "exclude"
+
+	// StudyConflictSplit splits the library in to one library per study, each
+	// containing only the experiments and samples belonging to that study.
+	StudyConflictSplit StudyConflictPolicy = "split"
+
+	// StudyConflictAllow keeps the library as-is, with a blank StudyID and
+	// StudyName. Its samples retain their own StudyID and StudyName.
+	StudyConflictAllow StudyConflictPolicy = "allow"
+)
+
+// studyConflictOutcomes describes what each StudyConflictPolicy did to a
+// library.
+var studyConflictOutcomes = map[StudyConflictPolicy]string{
+	StudyConflictExclude: "excluded",
+	StudyConflictSplit:   "split",
+	StudyConflictAllow:   "allowed",
+}
+
+// StringToStudyConflictPolicy converts a string to a StudyConflictPolicy.
+// Blank strings are treated as StudyConflictExclude.
+func StringToStudyConflictPolicy(s string) (StudyConflictPolicy, error) {
+	switch StudyConflictPolicy(s) {
+	case StudyConflictExclude, StudyConflictPolicy(""):
+		return StudyConflictExclude, nil
+	case StudyConflictSplit:
+		return StudyConflictSplit, nil
+	case StudyConflictAllow:
+		return StudyConflictAllow, nil
+	default:
+		return "", ErrInvalidStudyConflictPolicy
+	}
+}
+
+// StudyConflict describes a library that has samples in more than one study.
+// It is an error that wraps ErrExpSamplesInMultipleStudies.
+type StudyConflict struct {
+	// LibraryID is the ID of the library with the conflict.
+	LibraryID string
+
+	// Experiments are the library's experiments, containing all of its samples
+	// (with their StudyID and StudyName set).
+	Experiments []*types.Experiment
+
+	// Policy is the StudyConflictPolicy that was applied to the library.
+	Policy StudyConflictPolicy
+}
+
+// Error describes the conflict, listing the offending samples in each
+// experiment with their studies.
+func (s *StudyConflict) Error() string {
+	exps := make([]string, len(s.Experiments))
+
+	for i, exp := range s.Experiments {
+		samples := make([]string, len(exp.Samples))
+
+		for j, sample := range exp.Samples {
+			samples[j] = fmt.Sprintf("%s:%s (study %s %q)",
+				sample.SampleName, sample.RunID, sample.StudyID, sample.StudyName)
+		}
+
+		exps[i] = fmt.Sprintf("experiment %s: %s", exp.ExperimentID, strings.Join(samples, ", "))
+	}
+
+	return fmt.Sprintf("%s: library %s [%s]; %s",
+		ErrExpSamplesInMultipleStudies, s.LibraryID, studyConflictOutcomes[s.Policy], strings.Join(exps, "; "))
+}
+
+// Unwrap returns ErrExpSamplesInMultipleStudies.
+func (s *StudyConflict) Unwrap() error {
+	return ErrExpSamplesInMultipleStudies
+}
+
+// addLibrary adds the given library, which should contain only our desired
+// samples, to the result, dealing with libraries that have samples in
+// multiple studies according to our StudyConflictPolicy.
+func (c *Client) addLibrary(r *result, lib *types.Library) {
+	studies := lib.Studies()
+
+	if len(studies) == 1 {
+		setStudy(lib)
+		r.libs = append(r.libs, lib)
+
+		return
+	}
+
+	policy := c.studies
+	if policy == "" {
+		policy = StudyConflictExclude
+	}
+
+	r.conflicts = append(r.conflicts, &StudyConflict{
+		LibraryID:   lib.LibraryID,
+		Experiments: lib.Experiments,
+		Policy:      policy,
+	})
+
+	switch policy {
+	case StudyConflictSplit:
+		r.libs = append(r.libs, splitLibrary(lib, studies)...)
+	case StudyConflictAllow:
+		r.libs = append(r.libs, lib)
+	default:
+	}
+}
+
+// setStudy sets the library's StudyID and StudyName to that of its first
+// sample.
+func setStudy(lib *types.Library) {
+	for _, exp := range lib.Experiments {
+		for _, s := range exp.Samples {
+			lib.StudyID = s.StudyID
+			lib.StudyName = s.StudyName
+
+			return
+		}
+	}
+}
+
+// splitLibrary returns a clone of the given library for each of the given
+// studies, containing only the experiments and samples of that study.
+func splitLibrary(lib *types.Library, studies []string) types.Libraries {
+	libs := make(types.Libraries, 0, len(studies))
+
+	sort.Strings(studies)
+
+	for _, study := range studies {
+		studyLib := *lib
+		studyLib.Experiments = nil
+
+		for _, exp := range lib.Experiments {
+			var samples []*types.Sample
+
+			for _, s := range exp.Samples {
+				if s.StudyID == study {
+					samples = append(samples, s)
+				}
+			}
+
+			if len(samples) > 0 {
+				studyLib.Experiments = append(studyLib.Experiments, exp.Clone(samples))
+			}
+		}
+
+		setStudy(&studyLib)
+		libs = append(libs, &studyLib)
+	}
+
+	return libs
+}
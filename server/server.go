@@ -0,0 +1,436 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Authors:
+ *	- Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+// Package server provides an HTTP API for browsing the libraries, experiments
+// and samples known to a samples.Client, and for submitting runs of the
+// workflow steps.
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/inconshreveable/log15"
+	"github.com/wtsi-hgi/dimsum-automation/dimsum"
+	"github.com/wtsi-hgi/dimsum-automation/itl"
+	"github.com/wtsi-hgi/dimsum-automation/mlwh"
+	"github.com/wtsi-hgi/dimsum-automation/samples"
+	"github.com/wtsi-hgi/dimsum-automation/types"
+)
+
+type Error string
+
+func (e Error) Error() string { return string(e) }
+
+const (
+	ErrLibraryNotFound    = Error("library not found")
+	ErrExperimentNotFound = Error("experiment not found")
+
+	// HealthOK, HealthStale and HealthError are the Status values of Health.
+	HealthOK    = "ok"
+	HealthStale = "stale"
+	HealthError = "error"
+
+	// DefaultMaxStaleness is the default Options.MaxStaleness.
+	DefaultMaxStaleness = 30 * time.Minute
+
+	contentTypeHeader = "Content-Type"
+	jsonContentType   = "application/json"
+)
+
+// Options are options for creating a new Server.
+type Options struct {
+	// Sponsor is the faculty sponsor whose libraries are served. The Client
+	// should be prefetching for them.
+	Sponsor string
+
+	// MaxStaleness is how old the last successful prefetch can be before health
+	// reports the data as stale. Defaults to DefaultMaxStaleness.
+	MaxStaleness time.Duration
+
+	// Runner does submitted runs. If nil, runs can't be submitted.
+	Runner Runner
+
+	// Token, if set, must be given as a bearer token in the Authorization
+	// header of requests to submit runs.
+	Token string
+
+	// RunArgs are extra arguments given to every run, before the run type's
+	// own, eg. --qc and --study-conflicts options that match the Client's
+	// policies.
+	RunArgs []string
+
+	// FastqDir is the directory that fastq runs retrieve fastqs to (and dimsum
+	// runs read them from) by default. Runs can only use directories within
+	// it for fastqs, so fastq and dimsum runs can't be submitted if it isn't
+	// set. If set, samples say if they have fastqs in it.
+	FastqDir string
+
+	// OutputDir is the directory that dimsum runs write to by default. Dimsum
+	// runs can only output within it, so can't be submitted if it isn't set.
+	// If set, the history of dimsum runs is read from the manifests in it.
+	OutputDir string
+
+	// Logger is used to log requests and runs. Defaults to discarding them.
+	Logger log15.Logger
+}
+
+// Server is an http.Handler that serves our API.
+type Server struct {
+	client *samples.Client
+	opts   Options
+	mux    *http.ServeMux
+	runs   *runQueue
+	log    log15.Logger
+}
+
+// New returns a Server that serves data from the given client. Call Close()
+// when you're done with it to stop doing runs.
+//
+// The API is:
+//
+//	GET  /api/health           prefetch staleness and run counts
+//	GET  /api/settings         our Settings
+//	GET  /api/libraries        all libraries, with their experiments and samples
+//	GET  /api/libraries/{id}   a single library
+//	GET  /api/experiments      all experiments, with their library_id
+//	GET  /api/experiments/{id} a single experiment
+//	GET  /api/samples          all sample runs, optionally ?library= and/or ?experiment=
+//	GET  /api/history          manifests of the dimsum runs in our OutputDir
+//	GET  /api/runs             all submitted runs
+//	GET  /api/runs/{id}        a single submitted run
+//	POST /api/runs/fastq       submit a fastq retrieval run (see RunRequest)
+//	POST /api/runs/dimsum      submit a DiMSum run (see RunRequest)
+//
+// POSTs must have a JSON Content-Type, can't come from other sites, and need
+// our Options.Token, if any.
+func New(client *samples.Client, opts Options) *Server {
+	if opts.MaxStaleness == 0 {
+		opts.MaxStaleness = DefaultMaxStaleness
+	}
+
+	if opts.Logger == nil {
+		opts.Logger = log15.New()
+		opts.Logger.SetHandler(log15.DiscardHandler())
+	}
+
+	s := &Server{
+		client: client,
+		opts:   opts,
+		mux:    http.NewServeMux(),
+		log:    opts.Logger,
+	}
+
+	s.runs = newRunQueue(opts.Runner, s.log)
+
+	s.mux.HandleFunc("GET /api/health", s.health)
+	s.mux.HandleFunc("GET /api/settings", s.settings)
+	s.mux.HandleFunc("GET /api/libraries", s.libraries)
+	s.mux.HandleFunc("GET /api/libraries/{id}", s.library)
+	s.mux.HandleFunc("GET /api/experiments", s.experiments)
+	s.mux.HandleFunc("GET /api/experiments/{id}", s.experiment)
+	s.mux.HandleFunc("GET /api/samples", s.samples)
+	s.mux.HandleFunc("GET /api/history", s.history)
+	s.mux.HandleFunc("GET /api/runs", s.listRuns)
+	s.mux.HandleFunc("GET /api/runs/{id}", s.getRun)
+	s.mux.HandleFunc("POST /api/runs/{type}", s.checkWrite(s.submitRun))
+
+	return s
+}
+
+// Handle registers an additional handler for the given pattern, eg. to serve a
+// web frontend.
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.log.Debug("request", "method", r.Method, "path", r.URL.Path)
+	s.mux.ServeHTTP(w, r)
+}
+
+// Close stops doing runs, waiting for any current run to finish.
+func (s *Server) Close() {
+	s.runs.close()
+}
+
+// Health describes how fresh our data is.
+type Health struct {
+	Status       string         `json:"status"`
+	LastPrefetch *time.Time     `json:"last_prefetch"`
+	AgeSeconds   float64        `json:"age_seconds"`
+	MaxStaleness float64        `json:"max_staleness_seconds"`
+	Error        string         `json:"error,omitempty"`
+	Runs         map[string]int `json:"runs"`
+}
+
+// Health returns the current Health of our data.
+func (s *Server) Health() Health {
+	h := Health{
+		Status:       HealthOK,
+		MaxStaleness: s.opts.MaxStaleness.Seconds(),
+		Runs:         s.runs.counts(),
+	}
+
+	last := s.client.LastPrefetchSuccess()
+	if !last.IsZero() {
+		h.LastPrefetch = &last
+		h.AgeSeconds = time.Since(last).Seconds()
+	}
+
+	if last.IsZero() || time.Since(last) > s.opts.MaxStaleness {
+		h.Status = HealthStale
+	}
+
+	if err := s.client.Err(); err != nil {
+		h.Status = HealthError
+		h.Error = err.Error()
+	}
+
+	return h
+}
+
+func (s *Server) health(w http.ResponseWriter, _ *http.Request) {
+	h := s.Health()
+
+	status := http.StatusOK
+	if h.Status != HealthOK {
+		status = http.StatusServiceUnavailable
+	}
+
+	writeJSON(w, status, h)
+}
+
+// Settings describes how we are configured, for clients deciding what to
+// submit.
+type Settings struct {
+	Sponsor       string   `json:"sponsor"`
+	FastqDir      string   `json:"fastq_dir"`
+	OutputDir     string   `json:"output_dir"`
+	Runs          bool     `json:"runs"`
+	TokenRequired bool     `json:"token_required"`
+	DimSumOptions []string `json:"dimsum_options"`
+	SweepParams   []string `json:"sweep_params"`
+}
+
+func (s *Server) settings(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, Settings{
+		Sponsor:       s.opts.Sponsor,
+		FastqDir:      s.opts.FastqDir,
+		OutputDir:     s.opts.OutputDir,
+		Runs:          s.opts.Runner != nil,
+		TokenRequired: s.opts.Token != "",
+		DimSumOptions: DimSumOptions,
+		SweepParams:   dimsum.SweepParams(),
+	})
+}
+
+// libs returns the libraries of our sponsor, writing an error response and
+// returning false if they couldn't be got.
+func (s *Server) libs(w http.ResponseWriter, r *http.Request) (types.Libraries, bool) {
+	libs, err := s.client.ForSponsor(r.Context(), s.opts.Sponsor)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, mlwh.ErrUnavailable) {
+			status = http.StatusServiceUnavailable
+		}
+
+		s.writeError(w, status, err)
+
+		return nil, false
+	}
+
+	if libs == nil {
+		libs = types.Libraries{}
+	}
+
+	return libs, true
+}
+
+func (s *Server) libraries(w http.ResponseWriter, r *http.Request) {
+	if libs, ok := s.libs(w, r); ok {
+		writeJSON(w, http.StatusOK, libs)
+	}
+}
+
+func (s *Server) library(w http.ResponseWriter, r *http.Request) {
+	libs, ok := s.libs(w, r)
+	if !ok {
+		return
+	}
+
+	id := r.PathValue("id")
+
+	for _, lib := range libs {
+		if lib.LibraryID == id {
+			writeJSON(w, http.StatusOK, lib)
+
+			return
+		}
+	}
+
+	s.writeError(w, http.StatusNotFound, ErrLibraryNotFound)
+}
+
+// Experiment is a types.Experiment along with the ID of its library.
+type Experiment struct {
+	LibraryID string
+	*types.Experiment
+}
+
+func (s *Server) experiments(w http.ResponseWriter, r *http.Request) {
+	libs, ok := s.libs(w, r)
+	if !ok {
+		return
+	}
+
+	exps := []Experiment{}
+
+	for _, lib := range libs {
+		for _, exp := range lib.Experiments {
+			exps = append(exps, Experiment{LibraryID: lib.LibraryID, Experiment: exp})
+		}
+	}
+
+	writeJSON(w, http.StatusOK, exps)
+}
+
+func (s *Server) experiment(w http.ResponseWriter, r *http.Request) {
+	libs, ok := s.libs(w, r)
+	if !ok {
+		return
+	}
+
+	id := r.PathValue("id")
+
+	for _, lib := range libs {
+		for _, exp := range lib.Experiments {
+			if exp.ExperimentID == id {
+				writeJSON(w, http.StatusOK, Experiment{LibraryID: lib.LibraryID, Experiment: exp})
+
+				return
+			}
+		}
+	}
+
+	s.writeError(w, http.StatusNotFound, ErrExperimentNotFound)
+}
+
+// Sample is a types.Sample along with the IDs of its library and experiment,
+// its QCState(), and whether its fastqs are in our FastqDir (nil if we don't
+// have one).
+type Sample struct {
+	LibraryID    string
+	ExperimentID string
+	QCState      string
+	HasFastqs    *bool
+	*types.Sample
+}
+
+func (s *Server) samples(w http.ResponseWriter, r *http.Request) {
+	libs, ok := s.libs(w, r)
+	if !ok {
+		return
+	}
+
+	libID := r.URL.Query().Get("library")
+	expID := r.URL.Query().Get("experiment")
+	samples := []Sample{}
+
+	for _, lib := range libs {
+		if libID != "" && lib.LibraryID != libID {
+			continue
+		}
+
+		for _, exp := range lib.Experiments {
+			if expID != "" && exp.ExperimentID != expID {
+				continue
+			}
+
+			for _, sample := range exp.Samples {
+				samples = append(samples, s.sample(lib, exp, sample))
+			}
+		}
+	}
+
+	writeJSON(w, http.StatusOK, samples)
+}
+
+func (s *Server) sample(lib *types.Library, exp *types.Experiment, sample *types.Sample) Sample {
+	ss := Sample{
+		LibraryID:    lib.LibraryID,
+		ExperimentID: exp.ExperimentID,
+		QCState:      sample.QCState(),
+		Sample:       sample,
+	}
+
+	if s.opts.FastqDir != "" {
+		has := itl.HasFastqs(sample, s.opts.FastqDir)
+		ss.HasFastqs = &has
+	}
+
+	return ss
+}
+
+func (s *Server) history(w http.ResponseWriter, _ *http.Request) {
+	ms := []*dimsum.Manifest{}
+
+	if s.opts.OutputDir != "" {
+		var err error
+
+		ms, err = dimsum.ReadManifests(s.opts.OutputDir)
+		if err != nil {
+			s.writeError(w, http.StatusInternalServerError, err)
+
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, ms)
+}
+
+// errorResponse is the body of our error responses.
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func (s *Server) writeError(w http.ResponseWriter, status int, err error) {
+	if status >= http.StatusInternalServerError {
+		s.log.Error("request failed", "err", err)
+	}
+
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set(contentTypeHeader, jsonContentType)
+	w.WriteHeader(status)
+
+	json.NewEncoder(w).Encode(v) //nolint:errcheck,errchkjson
+}
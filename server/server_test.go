@@ -0,0 +1,418 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Authors:
+ *	- Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/wtsi-hgi/dimsum-automation/dimsum"
+	"github.com/wtsi-hgi/dimsum-automation/itl"
+	"github.com/wtsi-hgi/dimsum-automation/mlwh"
+	"github.com/wtsi-hgi/dimsum-automation/samples"
+	"github.com/wtsi-hgi/dimsum-automation/types"
+)
+
+const (
+	testSponsor = "sponsor"
+	errFake     = Error("fake error")
+)
+
+type fakeMLWH struct {
+	samples []*mlwh.Sample
+	err     error
+	mu      sync.RWMutex
+}
+
+func (f *fakeMLWH) SamplesForSponsor(_ context.Context, _ string) ([]*mlwh.Sample, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return f.samples, f.err
+}
+
+func (f *fakeMLWH) SamplesForStudies(ctx context.Context, _ []string) ([]*mlwh.Sample, error) {
+	return f.SamplesForSponsor(ctx, testSponsor)
+}
+
+func (f *fakeMLWH) SamplesByName(ctx context.Context, _ []string) ([]*mlwh.Sample, error) {
+	return f.SamplesForSponsor(ctx, testSponsor)
+}
+
+func (f *fakeMLWH) setError(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.err = err
+}
+
+func (f *fakeMLWH) Close() error {
+	return nil
+}
+
+type fakeSheets struct{}
+
+func (fakeSheets) DimSumMetaData(_ string) (types.Libraries, error) {
+	return types.Libraries{
+		{
+			LibraryID: "lib1",
+			Experiments: []*types.Experiment{
+				{
+					ExperimentID: "exp1",
+					Samples: []*types.Sample{
+						{SampleName: "s1", Selection: types.SelectionInput, ExperimentReplicate: 1},
+					},
+				},
+				{
+					ExperimentID: "exp2",
+					Samples: []*types.Sample{
+						{SampleName: "s2", Selection: types.SelectionOutput, ExperimentReplicate: 1},
+					},
+				},
+			},
+		},
+	}, nil
+}
+
+func newFakeMLWH() *fakeMLWH {
+	return &fakeMLWH{samples: []*mlwh.Sample{
+		{
+			StudyID: "study1", StudyName: "Study 1", Lane: 1,
+			Sample: types.Sample{SampleName: "s1", SampleID: "s1_id", RunID: "100", ManualQC: "1"},
+		},
+		{
+			StudyID: "study1", StudyName: "Study 1", Lane: 1,
+			Sample: types.Sample{SampleName: "s2", SampleID: "s2_id", RunID: "200", ManualQC: "1"},
+		},
+	}}
+}
+
+// newTestServer returns a Server using a prefetching samples.Client with fake
+// MLWH and Sheets clients, along with the fake MLWH and an httptest.Server
+// serving it.
+func newTestServer(t *testing.T, opts Options) (*Server, *fakeMLWH, *httptest.Server) {
+	t.Helper()
+
+	mc := newFakeMLWH()
+	client := samples.New(context.Background(), mc, fakeSheets{}, samples.ClientOptions{
+		CacheLifetime: time.Hour,
+		Prefetch:      []string{testSponsor},
+	})
+
+	opts.Sponsor = testSponsor
+	s := New(client, opts)
+	ts := httptest.NewServer(s)
+
+	t.Cleanup(func() {
+		ts.Close()
+		s.Close()
+		client.Close()
+	})
+
+	return s, mc, ts
+}
+
+// getJSON gets the given path from the given server, decoding the JSON
+// response in to v, and returning the status code.
+func getJSON(ts *httptest.Server, path string, v interface{}) int {
+	resp, err := http.Get(ts.URL + path) //nolint:noctx
+	So(err, ShouldBeNil)
+
+	defer resp.Body.Close()
+
+	So(resp.Header.Get(contentTypeHeader), ShouldEqual, jsonContentType)
+	So(json.NewDecoder(resp.Body).Decode(v), ShouldBeNil)
+
+	return resp.StatusCode
+}
+
+// postJSON posts the given body to the given path of the given server,
+// decoding the JSON response in to v, and returning the status code.
+func postJSON(ts *httptest.Server, path, body string, v interface{}) int {
+	return post(ts, path, body, http.Header{contentTypeHeader: {jsonContentType}}, v)
+}
+
+// post is like postJSON, but lets you choose the headers.
+func post(ts *httptest.Server, path, body string, header http.Header, v interface{}) int {
+	req, err := http.NewRequest(http.MethodPost, ts.URL+path, strings.NewReader(body)) //nolint:noctx
+	So(err, ShouldBeNil)
+
+	req.Header = header
+
+	resp, err := http.DefaultClient.Do(req)
+	So(err, ShouldBeNil)
+
+	defer resp.Body.Close()
+
+	So(json.NewDecoder(resp.Body).Decode(v), ShouldBeNil)
+
+	return resp.StatusCode
+}
+
+func TestServer(t *testing.T) {
+	Convey("Given a server with fake MLWH and Sheets clients", t, func() {
+		_, mc, ts := newTestServer(t, Options{})
+
+		Convey("You can get all the libraries", func() {
+			var libs types.Libraries
+
+			So(getJSON(ts, "/api/libraries", &libs), ShouldEqual, http.StatusOK)
+			So(libs, ShouldHaveLength, 1)
+			So(libs[0].LibraryID, ShouldEqual, "lib1")
+			So(libs[0].StudyID, ShouldEqual, "study1")
+			So(libs[0].Experiments, ShouldHaveLength, 2)
+			So(libs[0].Experiments[0].Samples[0].RunID, ShouldEqual, "100")
+		})
+
+		Convey("You can get a single library", func() {
+			var lib types.Library
+
+			So(getJSON(ts, "/api/libraries/lib1", &lib), ShouldEqual, http.StatusOK)
+			So(lib.LibraryID, ShouldEqual, "lib1")
+
+			var errResp errorResponse
+
+			So(getJSON(ts, "/api/libraries/foo", &errResp), ShouldEqual, http.StatusNotFound)
+			So(errResp.Error, ShouldEqual, ErrLibraryNotFound.Error())
+		})
+
+		Convey("You can get experiments with their library ID", func() {
+			var exps []Experiment
+
+			So(getJSON(ts, "/api/experiments", &exps), ShouldEqual, http.StatusOK)
+			So(exps, ShouldHaveLength, 2)
+			So(exps[1].LibraryID, ShouldEqual, "lib1")
+			So(exps[1].ExperimentID, ShouldEqual, "exp2")
+
+			var exp Experiment
+
+			So(getJSON(ts, "/api/experiments/exp1", &exp), ShouldEqual, http.StatusOK)
+			So(exp.ExperimentID, ShouldEqual, "exp1")
+			So(exp.Samples, ShouldHaveLength, 1)
+
+			var errResp errorResponse
+
+			So(getJSON(ts, "/api/experiments/foo", &errResp), ShouldEqual, http.StatusNotFound)
+			So(errResp.Error, ShouldEqual, ErrExperimentNotFound.Error())
+		})
+
+		Convey("You can get sample runs, optionally filtered", func() {
+			var ss []Sample
+
+			So(getJSON(ts, "/api/samples", &ss), ShouldEqual, http.StatusOK)
+			So(ss, ShouldHaveLength, 2)
+			So(ss[0].LibraryID, ShouldEqual, "lib1")
+			So(ss[0].ExperimentID, ShouldEqual, "exp1")
+			So(ss[0].SampleName, ShouldEqual, "s1")
+			So(ss[0].SampleID, ShouldEqual, "s1_id")
+
+			So(getJSON(ts, "/api/samples?experiment=exp2", &ss), ShouldEqual, http.StatusOK)
+			So(ss, ShouldHaveLength, 1)
+			So(ss[0].SampleName, ShouldEqual, "s2")
+
+			So(getJSON(ts, "/api/samples?library=other", &ss), ShouldEqual, http.StatusOK)
+			So(ss, ShouldBeEmpty)
+		})
+
+		Convey("Samples have their QC state, but no fastq availability without a FastqDir", func() {
+			var ss []Sample
+
+			So(getJSON(ts, "/api/samples", &ss), ShouldEqual, http.StatusOK)
+			So(ss[0].QCState, ShouldEqual, types.QCStatePassed)
+			So(ss[0].HasFastqs, ShouldBeNil)
+		})
+
+		Convey("You can get settings", func() {
+			var settings Settings
+
+			So(getJSON(ts, "/api/settings", &settings), ShouldEqual, http.StatusOK)
+			So(settings.Sponsor, ShouldEqual, testSponsor)
+			So(settings.Runs, ShouldBeFalse)
+			So(settings.DimSumOptions, ShouldResemble, DimSumOptions)
+			So(settings.SweepParams, ShouldResemble, dimsum.SweepParams())
+		})
+
+		Convey("History is empty without an OutputDir", func() {
+			var ms []*dimsum.Manifest
+
+			So(getJSON(ts, "/api/history", &ms), ShouldEqual, http.StatusOK)
+			So(ms, ShouldNotBeNil)
+			So(ms, ShouldBeEmpty)
+		})
+
+		Convey("Health reports ok after a successful prefetch", func() {
+			var h Health
+
+			So(getJSON(ts, "/api/health", &h), ShouldEqual, http.StatusOK)
+			So(h.Status, ShouldEqual, HealthOK)
+			So(h.LastPrefetch, ShouldNotBeNil)
+			So(h.AgeSeconds, ShouldBeLessThan, 60)
+			So(h.MaxStaleness, ShouldEqual, DefaultMaxStaleness.Seconds())
+			So(h.Error, ShouldBeBlank)
+			So(h.Runs[RunStateQueued], ShouldEqual, 0)
+		})
+
+		Convey("Unknown paths are not found", func() {
+			resp, err := http.Get(ts.URL + "/api/foo") //nolint:noctx
+			So(err, ShouldBeNil)
+			resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusNotFound)
+		})
+
+		Convey("Library endpoints report MLWH errors when there's nothing cached", func() {
+			mc.setError(mlwh.ErrUnavailable)
+
+			client := samples.New(context.Background(), mc, fakeSheets{}, samples.ClientOptions{})
+			defer client.Close()
+
+			s := New(client, Options{Sponsor: testSponsor})
+			defer s.Close()
+
+			ts2 := httptest.NewServer(s)
+			defer ts2.Close()
+
+			var errResp errorResponse
+
+			So(getJSON(ts2, "/api/libraries", &errResp), ShouldEqual, http.StatusServiceUnavailable)
+			So(errResp.Error, ShouldContainSubstring, mlwh.ErrUnavailable.Error())
+
+			mc.setError(errFake)
+
+			So(getJSON(ts2, "/api/samples", &errResp), ShouldEqual, http.StatusInternalServerError)
+			So(errResp.Error, ShouldEqual, errFake.Error())
+		})
+	})
+
+	Convey("Health reports stale data", t, func() {
+		_, _, ts := newTestServer(t, Options{MaxStaleness: time.Nanosecond})
+
+		var h Health
+
+		So(getJSON(ts, "/api/health", &h), ShouldEqual, http.StatusServiceUnavailable)
+		So(h.Status, ShouldEqual, HealthStale)
+		So(h.LastPrefetch, ShouldNotBeNil)
+	})
+
+	Convey("Health reports prefetch errors", t, func() {
+		mc := newFakeMLWH()
+		mc.setError(errFake)
+
+		client := samples.New(context.Background(), mc, fakeSheets{}, samples.ClientOptions{
+			CacheLifetime: time.Hour,
+			Prefetch:      []string{testSponsor},
+		})
+		defer client.Close()
+
+		s := New(client, Options{Sponsor: testSponsor})
+		defer s.Close()
+
+		ts := httptest.NewServer(s)
+		defer ts.Close()
+
+		var h Health
+
+		So(getJSON(ts, "/api/health", &h), ShouldEqual, http.StatusServiceUnavailable)
+		So(h.Status, ShouldEqual, HealthError)
+		So(h.Error, ShouldEqual, errFake.Error())
+		So(h.LastPrefetch, ShouldBeNil)
+	})
+
+	Convey("Given a server with fastq and output directories", t, func() {
+		fastqDir := t.TempDir()
+		outputDir := t.TempDir()
+		_, _, ts := newTestServer(t, Options{FastqDir: fastqDir, OutputDir: outputDir})
+
+		Convey("Samples say if their fastqs have been retrieved", func() {
+			for _, suffix := range []string{itl.FastqPair1Suffix, itl.FastqPair2Suffix} {
+				path := filepath.Join(fastqDir, "s1_id.100"+suffix)
+				So(os.WriteFile(path, []byte("@r"), 0600), ShouldBeNil)
+			}
+
+			var ss []Sample
+
+			So(getJSON(ts, "/api/samples", &ss), ShouldEqual, http.StatusOK)
+			So(ss, ShouldHaveLength, 2)
+			So(ss[0].HasFastqs, ShouldNotBeNil)
+			So(*ss[0].HasFastqs, ShouldBeTrue)
+			So(ss[1].HasFastqs, ShouldNotBeNil)
+			So(*ss[1].HasFastqs, ShouldBeFalse)
+		})
+
+		Convey("You can get the history of dimsum runs", func() {
+			started := time.Now().Add(-time.Minute).UTC().Truncate(time.Second)
+
+			for i, exp := range []string{"exp1", "exp2"} {
+				_, err := dimsum.WriteManifest(outputDir, &dimsum.Manifest{
+					ExperimentID: exp,
+					Samples:      []string{"s1:100"},
+					OutputDir:    filepath.Join(outputDir, exp),
+					State:        "complete",
+					Started:      started.Add(time.Duration(i) * time.Second),
+					Finished:     started.Add(time.Minute),
+				})
+				So(err, ShouldBeNil)
+			}
+
+			var ms []*dimsum.Manifest
+
+			So(getJSON(ts, "/api/history", &ms), ShouldEqual, http.StatusOK)
+			So(ms, ShouldHaveLength, 2)
+			So(ms[0].ExperimentID, ShouldEqual, "exp2")
+			So(ms[1].ExperimentID, ShouldEqual, "exp1")
+
+			var settings Settings
+
+			So(getJSON(ts, "/api/settings", &settings), ShouldEqual, http.StatusOK)
+			So(settings.FastqDir, ShouldEqual, fastqDir)
+			So(settings.OutputDir, ShouldEqual, outputDir)
+		})
+	})
+
+	Convey("You can add extra handlers", t, func() {
+		s, _, ts := newTestServer(t, Options{})
+
+		s.Handle("GET /", http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Write([]byte("hello")) //nolint:errcheck
+		}))
+
+		resp, err := http.Get(ts.URL + "/") //nolint:noctx
+		So(err, ShouldBeNil)
+
+		defer resp.Body.Close()
+
+		So(resp.StatusCode, ShouldEqual, http.StatusOK)
+	})
+}
@@ -0,0 +1,334 @@
+"use strict";
+
+// selected holds the sampleName:runID of the ticked samples.
+const selected = new Set();
+
+let settings = {};
+
+// tokenKey is where the token needed to submit runs is remembered.
+const tokenKey = "dimsum-automation-token";
+
+// takeTokenFromURL remembers a token given in the URL as #token=..., then
+// removes it from the URL.
+function takeTokenFromURL() {
+  const token = new URLSearchParams(location.hash.slice(1)).get("token");
+  if (!token) {
+    return;
+  }
+
+  localStorage.setItem(tokenKey, token);
+  history.replaceState(null, "", location.pathname + location.search);
+}
+
+async function api(path, options) {
+  const resp = await fetch(path, options);
+  const body = await resp.json();
+
+  if (!resp.ok) {
+    throw new Error(body.error || resp.statusText);
+  }
+
+  return body;
+}
+
+function el(tag, attrs, ...children) {
+  const e = document.createElement(tag);
+
+  for (const [k, v] of Object.entries(attrs || {})) {
+    if (k === "class") {
+      e.className = v;
+    } else {
+      e.setAttribute(k, v);
+    }
+  }
+
+  for (const c of children) {
+    e.append(c === undefined || c === null ? "" : c);
+  }
+
+  return e;
+}
+
+function formatTime(t) {
+  if (!t || t.startsWith("0001-")) {
+    return "";
+  }
+
+  return new Date(t).toLocaleString();
+}
+
+function sampleKey(s) {
+  return s.SampleName + ":" + s.RunID;
+}
+
+async function loadHealth() {
+  const banner = document.getElementById("health");
+
+  try {
+    const resp = await fetch("/api/health");
+    const h = await resp.json();
+    let msg = "Sample info last fetched " + formatTime(h.last_prefetch);
+
+    if (h.error) {
+      msg += " (" + h.error + ")";
+    }
+
+    banner.className = "banner " + h.status;
+    banner.textContent = h.status === "ok" ? "" : msg;
+  } catch (err) {
+    banner.className = "banner error";
+    banner.textContent = "Server unreachable: " + err.message;
+  }
+}
+
+async function loadSettings() {
+  settings = await api("/api/settings");
+
+  document.getElementById("fastq-dir").value = settings.fastq_dir;
+  document.getElementById("output-dir").value = settings.output_dir;
+  document.getElementById("sweep-params").textContent = settings.sweep_params.join(", ");
+
+  const opts = document.getElementById("dimsum-options");
+  opts.replaceChildren();
+
+  for (const name of settings.dimsum_options) {
+    opts.append(el("label", {}, name + " ", el("input", { type: "text", size: "8", "data-option": name })));
+  }
+
+  for (const id of ["get-fastqs", "run-dimsum"]) {
+    document.getElementById(id).disabled = !settings.runs;
+  }
+
+  const token = document.getElementById("token");
+  token.value = localStorage.getItem(tokenKey) || "";
+  document.getElementById("token-fieldset").hidden = !settings.token_required;
+}
+
+// groupSamples groups samples by library and then experiment, preserving
+// their order.
+function groupSamples(samples) {
+  const libs = new Map();
+
+  for (const s of samples) {
+    if (!libs.has(s.LibraryID)) {
+      libs.set(s.LibraryID, new Map());
+    }
+
+    const exps = libs.get(s.LibraryID);
+
+    if (!exps.has(s.ExperimentID)) {
+      exps.set(s.ExperimentID, []);
+    }
+
+    exps.get(s.ExperimentID).push(s);
+  }
+
+  return libs;
+}
+
+function fastqCell(s) {
+  if (s.HasFastqs === null || s.HasFastqs === undefined) {
+    return el("td", {}, "?");
+  }
+
+  return el("td", { class: s.HasFastqs ? "pass" : "pending" }, s.HasFastqs ? "yes" : "no");
+}
+
+function sampleCheckbox(s) {
+  const key = sampleKey(s);
+  const box = el("input", { type: "checkbox", "data-sample": key });
+
+  box.checked = selected.has(key);
+  box.addEventListener("change", () => {
+    if (box.checked) {
+      selected.add(key);
+    } else {
+      selected.delete(key);
+    }
+
+    updateSelectedCount();
+  });
+
+  return box;
+}
+
+function experimentTable(samples) {
+  const body = el("tbody");
+
+  for (const s of samples) {
+    body.append(el("tr", {},
+      el("td", {}, sampleCheckbox(s)),
+      el("td", {}, s.SampleName),
+      el("td", {}, s.RunID),
+      el("td", {}, s.Selection + s.ExperimentReplicate),
+      el("td", { class: s.QCState }, s.QCState),
+      fastqCell(s),
+    ));
+  }
+
+  const all = el("input", { type: "checkbox", title: "select all" });
+  all.addEventListener("change", () => {
+    for (const box of body.querySelectorAll("input[data-sample]")) {
+      box.checked = all.checked;
+      box.dispatchEvent(new Event("change"));
+    }
+  });
+
+  return el("table", {},
+    el("thead", {}, el("tr", {},
+      el("th", {}, all), el("th", {}, "Sample"), el("th", {}, "Run"),
+      el("th", {}, "Selection"), el("th", {}, "QC"), el("th", {}, "Fastqs"),
+    )),
+    body,
+  );
+}
+
+async function loadSamples() {
+  const container = document.getElementById("libraries");
+
+  try {
+    const libs = groupSamples(await api("/api/samples"));
+
+    container.replaceChildren();
+
+    for (const [libID, exps] of libs) {
+      const lib = el("details", { open: "" }, el("summary", {}, "Library " + libID));
+
+      for (const [expID, samples] of exps) {
+        lib.append(el("details", {},
+          el("summary", {}, "Experiment " + expID + " (" + samples.length + " samples)"),
+          experimentTable(samples),
+        ));
+      }
+
+      container.append(lib);
+    }
+  } catch (err) {
+    container.replaceChildren(el("p", { class: "banner error" }, "Could not load samples: " + err.message));
+  }
+}
+
+function updateSelectedCount() {
+  document.getElementById("selected-count").textContent = selected.size;
+}
+
+function dimsumOptions() {
+  const options = {};
+
+  for (const input of document.querySelectorAll("input[data-option]")) {
+    if (input.value.trim() !== "") {
+      options[input.dataset.option] = input.value.trim();
+    }
+  }
+
+  return options;
+}
+
+function sweeps() {
+  return document.getElementById("sweep").value.split("\n").map((l) => l.trim()).filter((l) => l !== "");
+}
+
+async function submit(type) {
+  const result = document.getElementById("submit-result");
+  const req = {
+    samples: [...selected],
+    output_dir: document.getElementById(type === "dimsum" ? "output-dir" : "fastq-dir").value.trim(),
+  };
+
+  if (type === "dimsum") {
+    req.fastq_dir = document.getElementById("fastq-dir").value.trim();
+    req.options = dimsumOptions();
+    req.sweep = sweeps();
+  }
+
+  const headers = { "Content-Type": "application/json" };
+  const token = document.getElementById("token").value.trim();
+
+  if (token !== "") {
+    headers.Authorization = "Bearer " + token;
+    localStorage.setItem(tokenKey, token);
+  }
+
+  try {
+    const run = await api("/api/runs/" + type, {
+      method: "POST",
+      headers: headers,
+      body: JSON.stringify(req),
+    });
+
+    result.className = "banner ok";
+    result.textContent = "Submitted run " + run.id + ".";
+    loadRuns();
+  } catch (err) {
+    result.className = "banner error";
+    result.textContent = "Could not submit: " + err.message;
+  }
+}
+
+async function loadRuns() {
+  const body = document.querySelector("#runs tbody");
+
+  try {
+    const runs = await api("/api/runs");
+
+    body.replaceChildren();
+
+    for (const r of runs.slice().reverse()) {
+      body.append(el("tr", {},
+        el("td", {}, r.id),
+        el("td", {}, r.type),
+        el("td", {}, r.request.samples.join(", ")),
+        el("td", { class: r.state }, r.state),
+        el("td", {}, formatTime(r.submitted)),
+        el("td", {}, formatTime(r.finished)),
+        el("td", {}, r.error),
+      ));
+    }
+  } catch (err) {
+    body.replaceChildren(el("tr", {}, el("td", { colspan: "7", class: "error" }, err.message)));
+  }
+}
+
+async function loadHistory() {
+  const body = document.querySelector("#history tbody");
+
+  try {
+    const manifests = await api("/api/history");
+
+    body.replaceChildren();
+
+    for (const m of manifests) {
+      const sweep = Object.entries(m.sweep || {}).map(([k, v]) => k + "=" + v).join(", ");
+
+      body.append(el("tr", {},
+        el("td", {}, m.experiment_id),
+        el("td", {}, m.samples.join(", ")),
+        el("td", {}, sweep),
+        el("td", { class: m.state, title: m.error || "" }, m.state),
+        el("td", {}, formatTime(m.started)),
+        el("td", {}, formatTime(m.finished)),
+        el("td", {}, m.output_dir),
+      ));
+    }
+  } catch (err) {
+    body.replaceChildren(el("tr", {}, el("td", { colspan: "7", class: "error" }, err.message)));
+  }
+}
+
+const pollInterval = 5000;
+
+document.getElementById("get-fastqs").addEventListener("click", () => submit("fastq"));
+document.getElementById("run-dimsum").addEventListener("click", () => submit("dimsum"));
+
+takeTokenFromURL();
+loadHealth();
+loadSettings();
+loadSamples();
+loadRuns();
+loadHistory();
+
+setInterval(() => {
+  loadHealth();
+  loadRuns();
+  loadHistory();
+}, pollInterval);
@@ -0,0 +1,70 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Authors:
+ *	- Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package server
+
+import (
+	"io"
+	"net/http"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestUI(t *testing.T) {
+	Convey("A server can serve the embedded UI alongside the API", t, func() {
+		s, _, ts := newTestServer(t, Options{})
+		s.Handle("GET /", UI())
+
+		for _, test := range []struct {
+			path, contentType, contains string
+		}{
+			{"/", "text/html", `<script src="app.js">`},
+			{"/app.js", "text/javascript", "/api/samples"},
+			{"/style.css", "text/css", "body"},
+		} {
+			resp, err := http.Get(ts.URL + test.path) //nolint:noctx
+			So(err, ShouldBeNil)
+
+			body, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			So(err, ShouldBeNil)
+
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(resp.Header.Get("Content-Type"), ShouldStartWith, test.contentType)
+			So(string(body), ShouldContainSubstring, test.contains)
+		}
+
+		var settings Settings
+
+		So(getJSON(ts, "/api/settings", &settings), ShouldEqual, http.StatusOK)
+
+		resp, err := http.Get(ts.URL + "/missing.js") //nolint:noctx
+		So(err, ShouldBeNil)
+		resp.Body.Close()
+		So(resp.StatusCode, ShouldEqual, http.StatusNotFound)
+	})
+}
@@ -28,7 +28,6 @@ package sheets
 
 import (
 	"encoding/json"
-	"os"
 
 	"github.com/wtsi-hgi/dimsum-automation/config"
 	"golang.org/x/oauth2/jwt"
@@ -55,11 +54,53 @@ func ServiceCredentialsFromConfig(c *config.Config) (*ServiceCredentials, error)
 	return ServiceCredentialsFromFile(c.CredentialsPath)
 }
 
+// FromConfig returns Files for the Config's SheetPath if set, otherwise Sheets
+// using the service credentials at the Config's CredentialsPath. If the Config
+// has a LayoutPath, the Layout in that file is used.
+func FromConfig(c *config.Config) (MetaDataReader, error) {
+	var layout *Layout
+
+	if c.LayoutPath != "" {
+		var err error
+
+		layout, err = LoadLayout(c.LayoutPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if c.SheetPath != "" {
+		f, err := NewFiles(c.SheetPath)
+		if err != nil {
+			return nil, err
+		}
+
+		f.SetLayout(layout)
+
+		return f, nil
+	}
+
+	sc, err := ServiceCredentialsFromConfig(c)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := New(sc)
+	if err != nil {
+		return nil, err
+	}
+
+	s.SetLayout(layout)
+
+	return s, nil
+}
+
 // ServiceCredentialsFromFile reads the given JSON file from (as retrieved from
 // https://console.developers.google.com for a service account) and parses it
-// in to a form usable by New().
+// in to a form usable by New(). Since it contains a private key, the file must
+// not be world-readable (see config.ReadSecretFile).
 func ServiceCredentialsFromFile(path string) (*ServiceCredentials, error) {
-	data, err := os.ReadFile(path)
+	data, err := config.ReadSecretFile(path)
 	if err != nil {
 		return nil, err
 	}
@@ -70,14 +111,12 @@ func ServiceCredentialsFromFile(path string) (*ServiceCredentials, error) {
 	return sc, err
 }
 
-func (sc *ServiceCredentials) toJWTConfig() *jwt.Config {
+func (sc *ServiceCredentials) toJWTConfig(scope string) *jwt.Config {
 	return &jwt.Config{
 		Email:        sc.ClientEmail,
 		PrivateKey:   []byte(sc.PrivateKey),
 		PrivateKeyID: sc.PrivateKeyID,
 		TokenURL:     sc.TokenURI,
-		Scopes: []string{
-			"https://www.googleapis.com/auth/spreadsheets.readonly",
-		},
+		Scopes:       []string{scope},
 	}
 }
@@ -27,6 +27,7 @@
 package sheets
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
@@ -38,6 +39,7 @@ import (
 
 const (
 	userPerms       = 0600
+	readablePerms   = 0644
 	credentialsJSON = `{
     "type": "service_account",
     "project_id": "projectID",
@@ -75,7 +77,7 @@ func TestConfig(t *testing.T) {
 			ClientX509CertURL:       "https://www.googleapis.com/robot/v1/metadata/x509/project.iam.gserviceaccount.com",
 		})
 
-		c := sc.toJWTConfig()
+		c := sc.toJWTConfig(scopeReadOnly)
 		So(c, ShouldResemble, &jwt.Config{
 			Email:        "user@project.iam.gserviceaccount.com",
 			PrivateKey:   []byte("keyContent\n"),
@@ -86,6 +88,9 @@ func TestConfig(t *testing.T) {
 			},
 		})
 
+		c = sc.toJWTConfig(scopeReadWrite)
+		So(c.Scopes, ShouldResemble, []string{"https://www.googleapis.com/auth/spreadsheets"})
+
 		Convey("You can make a ServiceCredentials from a Config", func() {
 			c := &config.Config{
 				CredentialsPath: credPath,
@@ -96,5 +101,12 @@ func TestConfig(t *testing.T) {
 			So(err, ShouldBeNil)
 			So(sc2, ShouldResemble, sc)
 		})
+
+		Convey("A world-readable credentials file is refused", func() {
+			So(os.Chmod(credPath, readablePerms), ShouldBeNil)
+
+			_, err := ServiceCredentialsFromFile(credPath)
+			So(errors.Is(err, config.ErrSecretFileReadable), ShouldBeTrue)
+		})
 	})
 }
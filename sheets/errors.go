@@ -0,0 +1,128 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Authors:
+ *	- Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package sheets
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// CellError describes a problem with the value of a particular cell in a sheet.
+type CellError struct {
+	// Sheet is the name of the sheet (tab) the cell is in.
+	Sheet string
+
+	// Row is the row number of the cell as shown in the spreadsheet, where
+	// the header row is row 1.
+	Row int
+
+	// Column is the header of the cell's column, as written in the sheet.
+	Column string
+
+	// Value is the offending value.
+	Value string
+
+	// Expected describes what the value should have been.
+	Expected string
+
+	// Err is the underlying error.
+	Err error
+}
+
+// Error says where the cell is, what its value was and what was expected.
+func (e *CellError) Error() string {
+	return fmt.Sprintf("sheet %q row %d column %q: %q is not %s", e.Sheet, e.Row, e.Column, e.Value, e.Expected)
+}
+
+// Unwrap returns the underlying error.
+func (e *CellError) Unwrap() error {
+	return e.Err
+}
+
+// CellErrors is a list of problems found in the cells of a sheet.
+type CellErrors []*CellError
+
+// Error lists every problem, one per line.
+func (e CellErrors) Error() string {
+	lines := make([]string, len(e))
+
+	for i, ce := range e {
+		lines[i] = ce.Error()
+	}
+
+	return fmt.Sprintf("%d problems in sheet data:\n%s", len(e), strings.Join(lines, "\n"))
+}
+
+// Unwrap returns the individual CellErrors.
+func (e CellErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+
+	for i, ce := range e {
+		errs[i] = ce
+	}
+
+	return errs
+}
+
+// sorted sorts these CellErrors by sheet, in the given order (that
+// DimSumMetaData() reads them in), then by row, and returns them.
+func (e CellErrors) sorted(order []string) CellErrors {
+	sheetOrder := make(map[string]int, len(order))
+
+	for i, name := range order {
+		sheetOrder[name] = i
+	}
+
+	slices.SortStableFunc(e, func(a, b *CellError) int {
+		if c := cmp.Compare(sheetOrder[a.Sheet], sheetOrder[b.Sheet]); c != 0 {
+			return c
+		}
+
+		return cmp.Compare(a.Row, b.Row)
+	})
+
+	return e
+}
+
+// has returns true if we already have a CellError for the same cell as the
+// given one.
+func (e CellErrors) has(ce *CellError) bool {
+	return slices.ContainsFunc(e, func(other *CellError) bool {
+		return other.Sheet == ce.Sheet && other.Row == ce.Row && other.Column == ce.Column
+	})
+}
+
+// orNil returns these CellErrors as an error, or nil if there are none.
+func (e CellErrors) orNil() error {
+	if len(e) == 0 {
+		return nil
+	}
+
+	return e
+}
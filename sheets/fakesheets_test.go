@@ -0,0 +1,272 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Authors:
+ *	- Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package sheets
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"google.golang.org/api/option"
+)
+
+// fakeSheetsServer is a local stand-in for the Google Sheets API, supporting
+// just the spreadsheet get (with grid data) and values update and append calls
+// we make, for a single spreadsheet. Cells can be strings, float64s or bools,
+// like unformatted values from the real API, which are shown as cellToString()
+// would convert them, or fakeCells.
+type fakeSheetsServer struct {
+	*httptest.Server
+	sheetID string
+
+	mu       sync.Mutex
+	tabs     map[string][][]any
+	requests int
+}
+
+// fakeCell is a cell with a value that is shown differently, as if it had a
+// number format.
+type fakeCell struct {
+	value          any
+	formatted      string
+	dateTimeFormat bool
+}
+
+var (
+	fakeValuesPath      = regexp.MustCompile(`^/v4/spreadsheets/([^/]+)/values/(.+?)(:append)?$`)
+	fakeSpreadsheetPath = regexp.MustCompile(`^/v4/spreadsheets/([^/]+)$`)
+)
+
+// newFakeSheetsServer starts a fakeSheetsServer for a spreadsheet with the
+// given ID and tabs.
+func newFakeSheetsServer(sheetID string, tabs map[string][][]any) *fakeSheetsServer {
+	f := &fakeSheetsServer{sheetID: sheetID, tabs: tabs}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.handle))
+
+	return f
+}
+
+// sheets returns a Sheets that talks to this server.
+func (f *fakeSheetsServer) sheets() (*Sheets, error) {
+	return newSheets(context.Background(), option.WithEndpoint(f.URL+"/"), option.WithoutAuthentication())
+}
+
+// values returns the current cells of the given tab.
+func (f *fakeSheetsServer) values(tab string) [][]any {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.tabs[tab]
+}
+
+// requestCount returns the number of requests made to this server.
+func (f *fakeSheetsServer) requestCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.requests
+}
+
+func (f *fakeSheetsServer) handle(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.requests++
+	f.mu.Unlock()
+
+	if m := fakeSpreadsheetPath.FindStringSubmatch(r.URL.Path); m != nil && m[1] == f.sheetID &&
+		r.Method == http.MethodGet {
+		f.getSpreadsheet(w, r)
+
+		return
+	}
+
+	m := fakeValuesPath.FindStringSubmatch(r.URL.Path)
+	if m == nil || m[1] != f.sheetID {
+		http.NotFound(w, r)
+
+		return
+	}
+
+	tab, row := parseFakeRange(m[2])
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	rows, ok := f.tabs[tab]
+	if !ok {
+		http.Error(w, `{"error":{"code":400,"message":"Unable to parse range"}}`, http.StatusBadRequest)
+
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodPut && row > 0:
+		f.tabs[tab] = replaceFakeRows(rows, row, decodeFakeValues(r))
+		writeFakeJSON(w, map[string]any{"spreadsheetId": f.sheetID})
+	case r.Method == http.MethodPost && m[3] != "":
+		f.tabs[tab] = append(rows, decodeFakeValues(r)...)
+		writeFakeJSON(w, map[string]any{"spreadsheetId": f.sheetID})
+	default:
+		http.Error(w, "unsupported", http.StatusMethodNotAllowed)
+	}
+}
+
+// getSpreadsheet responds with the grid data of the tabs in the requested
+// ranges, in the order of our tabs' names.
+func (f *fakeSheetsServer) getSpreadsheet(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	requested := make(map[string]bool)
+
+	for _, rangeStr := range r.URL.Query()["ranges"] {
+		tab, _ := parseFakeRange(rangeStr)
+
+		if _, ok := f.tabs[tab]; !ok {
+			http.Error(w, `{"error":{"code":400,"message":"Unable to parse range"}}`, http.StatusBadRequest)
+
+			return
+		}
+
+		requested[tab] = true
+	}
+
+	titles := make([]string, 0, len(requested))
+
+	for tab := range requested {
+		titles = append(titles, tab)
+	}
+
+	slices.Sort(titles)
+
+	sheets := make([]map[string]any, len(titles))
+
+	for i, tab := range titles {
+		rowData := make([]map[string]any, len(f.tabs[tab]))
+
+		for j, row := range f.tabs[tab] {
+			cells := make([]map[string]any, len(row))
+
+			for k, cell := range row {
+				cells[k] = fakeCellData(cell)
+			}
+
+			rowData[j] = map[string]any{"values": cells}
+		}
+
+		sheets[i] = map[string]any{
+			"properties": map[string]any{"title": tab},
+			"data":       []map[string]any{{"rowData": rowData}},
+		}
+	}
+
+	writeFakeJSON(w, map[string]any{"spreadsheetId": f.sheetID, "sheets": sheets})
+}
+
+// fakeCellData returns the CellData the real API would return for the given
+// cell.
+func fakeCellData(cell any) map[string]any {
+	fc, ok := cell.(fakeCell)
+	if !ok {
+		fc = fakeCell{value: cell, formatted: cellToString(cell)}
+	}
+
+	if fc.value == nil {
+		return map[string]any{}
+	}
+
+	data := map[string]any{"formattedValue": fc.formatted}
+
+	switch v := fc.value.(type) {
+	case float64:
+		data["effectiveValue"] = map[string]any{"numberValue": v}
+	case bool:
+		data["effectiveValue"] = map[string]any{"boolValue": v}
+	default:
+		data["effectiveValue"] = map[string]any{"stringValue": v}
+	}
+
+	if fc.dateTimeFormat {
+		data["effectiveFormat"] = map[string]any{"numberFormat": map[string]any{"type": "DATE_TIME"}}
+	}
+
+	return data
+}
+
+// parseFakeRange splits a range like "tab!A3" or "'my tab'!A3" in to the tab
+// name and row number, which is 0 when the range is just a tab name.
+func parseFakeRange(rangeStr string) (string, int) {
+	tab, cells, found := strings.Cut(rangeStr, "!")
+	if len(tab) > 1 && strings.HasPrefix(tab, "'") && strings.HasSuffix(tab, "'") {
+		tab = strings.ReplaceAll(tab[1:len(tab)-1], "''", "'")
+	}
+
+	if !found {
+		return tab, 0
+	}
+
+	row, err := strconv.Atoi(strings.TrimLeft(cells, "ABCDEFGHIJKLMNOPQRSTUVWXYZ"))
+	if err != nil {
+		return tab, 0
+	}
+
+	return tab, row
+}
+
+func decodeFakeValues(r *http.Request) [][]any {
+	var vr struct {
+		Values [][]any `json:"values"`
+	}
+
+	json.NewDecoder(r.Body).Decode(&vr) //nolint:errcheck
+
+	return vr.Values
+}
+
+// replaceFakeRows replaces rows starting at the given 1-based row number.
+func replaceFakeRows(rows [][]any, rowNumber int, replacements [][]any) [][]any {
+	for i, replacement := range replacements {
+		idx := rowNumber - 1 + i
+		for len(rows) <= idx {
+			rows = append(rows, nil)
+		}
+
+		rows[idx] = replacement
+	}
+
+	return rows
+}
+
+func writeFakeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v) //nolint:errcheck,errchkjson
+}
@@ -0,0 +1,185 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Authors:
+ *	- Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package sheets
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/wtsi-hgi/dimsum-automation/types"
+)
+
+const (
+	ErrSheetNotFound = Error("sheet not found")
+
+	xlsxExt = ".xlsx"
+	csvExt  = ".csv"
+	tsvExt  = ".tsv"
+	bom     = "\ufeff"
+)
+
+// Files allows the retrieval of sheets from local files, as an alternative to
+// Sheets for when Google docs can't be accessed. The files could be an XLSX
+// workbook, or a directory of CSV or TSV files, one per sheet, named after the
+// sheet (eg. libraries.csv, experiments.csv and samples.csv).
+type Files struct {
+	path   string
+	layout *Layout
+}
+
+// NewFiles returns a Files that reads sheets from the given XLSX workbook or
+// directory of CSV/TSV files.
+func NewFiles(path string) (*Files, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() && !strings.EqualFold(filepath.Ext(path), xlsxExt) {
+		return nil, fmt.Errorf("%w: %s is neither a directory nor an XLSX file", ErrSheetNotFound, path)
+	}
+
+	return &Files{path: path}, nil
+}
+
+// Read retrieves the contents of the given sheet, which is matched
+// case-insensitively against the sheet names of our XLSX workbook, or the
+// basenames of the CSV/TSV files in our directory. The sheetID is ignored.
+func (f *Files) Read(_, sheetName string) (*Sheet, error) {
+	var (
+		values [][]string
+		err    error
+	)
+
+	if strings.EqualFold(filepath.Ext(f.path), xlsxExt) {
+		values, err = readXLSXSheet(f.path, sheetName)
+	} else {
+		values, err = f.readDelimitedSheet(sheetName)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSheet(values), nil
+}
+
+// DimSumMetaData is like Sheets.DimSumMetaData(), but reads our files. The
+// sheetID is ignored.
+func (f *Files) DimSumMetaData(sheetID string) (types.Libraries, error) {
+	return dimSumMetaData(f, sheetID, f.layout, nil)
+}
+
+// Validate is like Sheets.Validate(), but reads our files. The sheetID is
+// ignored.
+func (f *Files) Validate(sheetID string, pathExists func(string) bool) error {
+	return validate(f, sheetID, f.layout, pathExists)
+}
+
+// SetLayout is like Sheets.SetLayout().
+func (f *Files) SetLayout(l *Layout) {
+	f.layout = l
+}
+
+// readDelimitedSheet finds and reads the CSV or TSV file in our directory for
+// the given sheet.
+func (f *Files) readDelimitedSheet(sheetName string) ([][]string, error) {
+	entries, err := os.ReadDir(f.path)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		ext := strings.ToLower(filepath.Ext(name))
+
+		if entry.IsDir() || (ext != csvExt && ext != tsvExt) ||
+			!strings.EqualFold(strings.TrimSuffix(name, filepath.Ext(name)), sheetName) {
+			continue
+		}
+
+		return readDelimited(filepath.Join(f.path, name), ext == tsvExt)
+	}
+
+	return nil, fmt.Errorf("%w: no %s.csv or %s.tsv in %s", ErrSheetNotFound, sheetName, sheetName, f.path)
+}
+
+// readDelimited reads all the records in the given CSV file, or TSV file if tsv
+// is true. Blank lines are returned as empty records, so that records are at
+// the same index as the spreadsheet rows they were exported from.
+func readDelimited(path string, tsv bool) ([][]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	r := csv.NewReader(file)
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+
+	if tsv {
+		r.Comma = '\t'
+	}
+
+	var records [][]string
+
+	nextLine := 1
+
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		line, _ := r.FieldPos(0)
+		for ; nextLine < line; nextLine++ {
+			records = append(records, nil)
+		}
+
+		last := len(record) - 1
+		lastLine, _ := r.FieldPos(last)
+		nextLine = lastLine + strings.Count(record[last], "\n") + 1
+
+		records = append(records, record)
+	}
+
+	if len(records) > 0 && len(records[0]) > 0 {
+		records[0][0] = strings.TrimPrefix(records[0][0], bom)
+	}
+
+	return records, nil
+}
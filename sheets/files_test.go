@@ -0,0 +1,322 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Authors:
+ *	- Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package sheets
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/wtsi-hgi/dimsum-automation/types"
+)
+
+const testMetadataDir = "testdata/metadata"
+
+var sheetNames = []string{"libraries", "experiments", "samples"} //nolint:gochecknoglobals
+
+func TestFiles(t *testing.T) {
+	Convey("Given a directory of CSV files, you can get DimSum metadata", t, func() {
+		f, err := NewFiles(testMetadataDir)
+		So(err, ShouldBeNil)
+
+		libs, err := f.DimSumMetaData("")
+		So(err, ShouldBeNil)
+		So(libs, ShouldHaveLength, 2)
+
+		lib1 := libs[0]
+		So(lib1.LibraryID, ShouldEqual, "lib1")
+		So(lib1.WildtypeSequence, ShouldEqual, "AAAA")
+		So(lib1.MaxSubstitutions, ShouldEqual, 2)
+		So(lib1.Experiments, ShouldHaveLength, 2)
+
+		exp1 := lib1.Experiments[0]
+		So(exp1.ExperimentID, ShouldEqual, "exp1")
+		So(exp1.WildtypeSequence, ShouldEqual, "AAAA")
+		So(exp1.MaxSubstitutions, ShouldEqual, 2)
+		So(exp1.CutadaptErrorRate, ShouldEqual, "0.2")
+		So(exp1.SequenceType, ShouldEqual, types.SequenceTypeC)
+		So(exp1.Paired, ShouldBeTrue)
+		So(exp1.Samples, ShouldHaveLength, 2)
+		So(exp1.Samples[1].SampleName, ShouldEqual, "DMS_sample2")
+		So(exp1.Samples[1].Selection, ShouldEqual, types.SelectionOutput)
+		So(exp1.Samples[1].RunID, ShouldEqual, "12345")
+		So(exp1.Samples[1].TechnicalReplicate, ShouldEqual, 2)
+
+		exp2 := lib1.Experiments[1]
+		So(exp2.WildtypeSequence, ShouldEqual, "TTTT")
+		So(exp2.MaxSubstitutions, ShouldEqual, 3)
+
+		So(libs[1].Experiments[0].Samples[0].SampleName, ShouldEqual, "Collab_sample4")
+
+		Convey("TSV files and differently cased names work the same", func() {
+			dir := t.TempDir()
+
+			for _, name := range sheetNames {
+				records, err := readDelimited(filepath.Join(testMetadataDir, name+csvExt), false)
+				So(err, ShouldBeNil)
+
+				writeDelimited(filepath.Join(dir, strings.ToUpper(name[:1])+name[1:]+tsvExt), records, '\t')
+			}
+
+			tf, err := NewFiles(dir)
+			So(err, ShouldBeNil)
+
+			tsvLibs, err := tf.DimSumMetaData("")
+			So(err, ShouldBeNil)
+			So(tsvLibs, ShouldResemble, libs)
+		})
+
+		Convey("An XLSX workbook with the same content gives the same metadata", func() {
+			sheets := make(map[string][][]string, len(sheetNames))
+
+			for _, name := range sheetNames {
+				records, err := readDelimited(filepath.Join(testMetadataDir, name+csvExt), false)
+				So(err, ShouldBeNil)
+
+				sheets[name] = records
+			}
+
+			path := filepath.Join(t.TempDir(), "metadata.xlsx")
+			writeXLSX(path, sheets)
+
+			xf, err := NewFiles(path)
+			So(err, ShouldBeNil)
+
+			xlsxLibs, err := xf.DimSumMetaData("")
+			So(err, ShouldBeNil)
+			So(xlsxLibs, ShouldResemble, libs)
+
+			_, err = xf.Read("", "missing")
+			So(errors.Is(err, ErrSheetNotFound), ShouldBeTrue)
+		})
+
+		Convey("Every invalid cell is reported", func() {
+			dir := t.TempDir()
+
+			for _, name := range sheetNames {
+				records, err := readDelimited(filepath.Join(testMetadataDir, name+csvExt), false)
+				So(err, ShouldBeNil)
+
+				switch name {
+				case "experiments":
+					records[2][0] = "lib9"
+					records[3][40] = "ture"
+				case "samples":
+					records[1][2] = "inptu"
+					records[4][3] = "first"
+				}
+
+				writeDelimited(filepath.Join(dir, name+csvExt), records, ',')
+			}
+
+			bf, err := NewFiles(dir)
+			So(err, ShouldBeNil)
+
+			_, err = bf.DimSumMetaData("")
+			So(errors.Is(err, ErrMissingLibrary), ShouldBeTrue)
+
+			var cellErrs CellErrors
+			So(errors.As(err, &cellErrs), ShouldBeTrue)
+			So(cellErrs, ShouldHaveLength, 5)
+			So(cellErrs[0].Error(), ShouldEqual,
+				`sheet "experiments" row 3 column "library_id": "lib9" is not a library_id in the libraries sheet`)
+			So(cellErrs[1].Error(), ShouldEqual, `sheet "experiments" row 4 column "paired": "ture" is not TRUE or FALSE`)
+			So(cellErrs[2].Error(), ShouldEqual,
+				`sheet "samples" row 2 column "selection": "inptu" is not one of input, output`)
+			So(cellErrs[3].Error(), ShouldEqual,
+				`sheet "samples" row 4 column "experiment_id": "exp2" is not an experiment_id in the experiments sheet`)
+			So(cellErrs[4].Error(), ShouldEqual,
+				`sheet "samples" row 5 column "experiment_replicate": "first" is not a whole number`)
+		})
+
+		Convey("Blank rows are skipped, but invalid cells are reported with their real row numbers", func() {
+			dir := t.TempDir()
+			sheets := make(map[string][][]string, len(sheetNames))
+
+			for _, name := range sheetNames {
+				records, err := readDelimited(filepath.Join(testMetadataDir, name+csvExt), false)
+				So(err, ShouldBeNil)
+
+				if name == "samples" {
+					records[4][3] = "first"
+					records = slices.Insert(records, 2, []string{}, []string{})
+				}
+
+				sheets[name] = records
+				writeDelimited(filepath.Join(dir, name+csvExt), records, ',')
+			}
+
+			xlsxPath := filepath.Join(t.TempDir(), "metadata.xlsx")
+			writeXLSX(xlsxPath, sheets)
+
+			for _, path := range []string{dir, xlsxPath} {
+				bf, err := NewFiles(path)
+				So(err, ShouldBeNil)
+
+				_, err = bf.DimSumMetaData("")
+
+				var cellErrs CellErrors
+				So(errors.As(err, &cellErrs), ShouldBeTrue)
+				So(cellErrs, ShouldHaveLength, 1)
+				So(cellErrs[0].Error(), ShouldEqual,
+					`sheet "samples" row 7 column "experiment_replicate": "first" is not a whole number`)
+			}
+		})
+
+		Convey("Missing sheets and files are errors", func() {
+			_, err := f.Read("", "missing")
+			So(errors.Is(err, ErrSheetNotFound), ShouldBeTrue)
+
+			_, err = NewFiles(filepath.Join(testMetadataDir, "libraries.csv"))
+			So(errors.Is(err, ErrSheetNotFound), ShouldBeTrue)
+
+			_, err = NewFiles(filepath.Join(testMetadataDir, "missing"))
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func writeDelimited(path string, records [][]string, comma rune) {
+	file, err := os.Create(path)
+	So(err, ShouldBeNil)
+
+	w := csv.NewWriter(file)
+	w.Comma = comma
+
+	So(w.WriteAll(records), ShouldBeNil)
+	So(file.Close(), ShouldBeNil)
+}
+
+// writeXLSX writes a minimal XLSX workbook with the given sheets, using shared
+// strings for the first sheet's text and inline strings for the others, and
+// numeric and boolean cells where values look like numbers or booleans.
+func writeXLSX(path string, sheets map[string][][]string) {
+	file, err := os.Create(path)
+	So(err, ShouldBeNil)
+
+	zw := zip.NewWriter(file)
+
+	var workbook, rels strings.Builder
+
+	shared := []string{}
+
+	for i, name := range sheetNames {
+		id := strconv.Itoa(i + 1)
+		fmt.Fprintf(&workbook, `<sheet name="%s" sheetId="%s" r:id="rId%s"/>`, name, id, id)
+		fmt.Fprintf(&rels, `<Relationship Id="rId%s" Target="worksheets/sheet%s.xml"/>`, id, id)
+
+		writeZipFile(zw, "xl/worksheets/sheet"+id+".xml", xlsxSheetXML(sheets[name], i == 0, &shared))
+	}
+
+	writeZipFile(zw, xlsxWorkbookPath, `<workbook xmlns:r="http://schemas.openxmlformats.org/`+
+		`officeDocument/2006/relationships"><sheets>`+workbook.String()+`</sheets></workbook>`)
+	writeZipFile(zw, xlsxWorkbookRelsPath, `<Relationships>`+rels.String()+`</Relationships>`)
+
+	var sst strings.Builder
+
+	for _, s := range shared {
+		fmt.Fprintf(&sst, `<si><r><t>%s</t></r><r><t></t></r></si>`, html.EscapeString(s))
+	}
+
+	writeZipFile(zw, xlsxSharedStringsPath, `<sst>`+sst.String()+`</sst>`)
+
+	So(zw.Close(), ShouldBeNil)
+	So(file.Close(), ShouldBeNil)
+}
+
+func xlsxSheetXML(records [][]string, useShared bool, shared *[]string) string {
+	var sb strings.Builder
+
+	sb.WriteString(`<worksheet><sheetData>`)
+
+	for r, record := range records {
+		if len(record) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(&sb, `<row r="%d">`, r+1)
+
+		for c, val := range record {
+			if val == "" {
+				continue
+			}
+
+			ref := fmt.Sprintf("%s%d", xlsxColumnName(c), r+1)
+
+			switch {
+			case val == "TRUE" || val == "FALSE":
+				fmt.Fprintf(&sb, `<c r="%s" t="b"><v>%d</v></c>`, ref, map[bool]int{true: 1}[val == "TRUE"])
+			case isNumber(val):
+				fmt.Fprintf(&sb, `<c r="%s"><v>%s</v></c>`, ref, val)
+			case useShared:
+				*shared = append(*shared, val)
+				fmt.Fprintf(&sb, `<c r="%s" t="s"><v>%d</v></c>`, ref, len(*shared)-1)
+			default:
+				fmt.Fprintf(&sb, `<c r="%s" t="inlineStr"><is><t>%s</t></is></c>`, ref, html.EscapeString(val))
+			}
+		}
+
+		sb.WriteString(`</row>`)
+	}
+
+	sb.WriteString(`</sheetData></worksheet>`)
+
+	return sb.String()
+}
+
+func xlsxColumnName(i int) string {
+	name := ""
+
+	for i++; i > 0; i = (i - 1) / lettersInAlphabet {
+		name = string(rune('A'+(i-1)%lettersInAlphabet)) + name
+	}
+
+	return name
+}
+
+func isNumber(val string) bool {
+	_, err := strconv.ParseFloat(val, floatBits)
+
+	return err == nil
+}
+
+func writeZipFile(zw *zip.Writer, name, content string) {
+	w, err := zw.Create(name)
+	So(err, ShouldBeNil)
+
+	_, err = w.Write([]byte(content))
+	So(err, ShouldBeNil)
+}
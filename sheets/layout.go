@@ -0,0 +1,219 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Authors:
+ *	- Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package sheets
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+
+	"github.com/wtsi-hgi/dimsum-automation/types"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	ErrUnknownLayoutColumn = Error("unknown column in sheet layout")
+	ErrDuplicateLayoutTab  = Error("tab used more than once in sheet layout")
+	ErrAmbiguousLayout     = Error("column configured more than once in sheet layout")
+
+	defaultLibrariesTab   = "libraries"
+	defaultExperimentsTab = "experiments"
+	defaultSamplesTab     = "samples"
+)
+
+// TabLayout says which tab of a spreadsheet holds a kind of metadata, and what
+// its columns are called.
+type TabLayout struct {
+	// Tab is the name of the tab.
+	Tab string `yaml:"tab"`
+
+	// AdditionalTabs are the names of further tabs with the same columns as
+	// Tab, whose rows are read after Tab's (eg. if samples are split over a
+	// tab per year).
+	AdditionalTabs []string `yaml:"additional_tabs"`
+
+	// Columns maps our column headers (the "sheet" struct tag headers in the
+	// types package, eg. "mlwh_sample_name") to the headers used in these tabs
+	// instead. Our own headers and their aliases are still accepted.
+	Columns map[string]string `yaml:"columns"`
+}
+
+// tabs returns Tab followed by AdditionalTabs.
+func (t *TabLayout) tabs() []string {
+	return append([]string{t.Tab}, t.AdditionalTabs...)
+}
+
+// header returns the configured header for the given field, and true, if
+// there is one. checkColumns() ensures there is at most one.
+func (t *TabLayout) header(f field) (string, bool) {
+	if t == nil {
+		return "", false
+	}
+
+	for ours, theirs := range t.Columns {
+		if f.hasHeader(ours) {
+			return theirs, true
+		}
+	}
+
+	return "", false
+}
+
+// checkColumns returns an error if any of our Columns keys don't correspond to
+// a field of the given schema, or if more than one of them corresponds to the
+// same field (eg. a header and its alias), since we wouldn't know which to use.
+func (t *TabLayout) checkColumns(s schema) error {
+	for ours := range t.Columns {
+		if !slices.ContainsFunc(s, func(f field) bool { return f.hasHeader(ours) }) {
+			return fmt.Errorf("%w: %q in %s", ErrUnknownLayoutColumn, ours, t.Tab)
+		}
+	}
+
+	for _, f := range s {
+		var matched []string
+
+		for ours := range t.Columns {
+			if f.hasHeader(ours) {
+				matched = append(matched, ours)
+			}
+		}
+
+		if len(matched) > 1 {
+			slices.Sort(matched)
+
+			return fmt.Errorf("%w: %q in %s", ErrAmbiguousLayout, matched, t.Tab)
+		}
+	}
+
+	return nil
+}
+
+// Layout describes the tabs and columns of a spreadsheet that DimSumMetaData()
+// reads from.
+type Layout struct {
+	Libraries   TabLayout `yaml:"libraries"`
+	Experiments TabLayout `yaml:"experiments"`
+	Samples     TabLayout `yaml:"samples"`
+}
+
+// DefaultLayout returns the Layout of the GenGen spreadsheet: tabs
+// "libraries", "experiments" and "samples", with columns named as in the
+// "sheet" struct tags of the types package.
+func DefaultLayout() *Layout {
+	return &Layout{
+		Libraries:   TabLayout{Tab: defaultLibrariesTab},
+		Experiments: TabLayout{Tab: defaultExperimentsTab},
+		Samples:     TabLayout{Tab: defaultSamplesTab},
+	}
+}
+
+// LoadLayout reads a Layout from the given YAML file, which looks like:
+//
+//	libraries:
+//	  tab: Library list
+//	  columns:
+//	    library_id: Library name
+//	samples:
+//	  tab: Samples 2025
+//	  additional_tabs: [Samples 2024]
+//	  columns:
+//	    mlwh_sample_name: Sanger sample name
+//
+// Anything not specified is as in DefaultLayout(). Returns an error if a
+// column isn't one we know about or is configured more than once (eg. by its
+// alias), or a tab is used for more than one kind of metadata.
+func LoadLayout(path string) (*Layout, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	l := DefaultLayout()
+
+	if err = yaml.Unmarshal(data, l); err != nil {
+		return nil, err
+	}
+
+	l.fillDefaults()
+
+	return l, l.check()
+}
+
+// fillDefaults sets blank tab names to their defaults.
+func (l *Layout) fillDefaults() {
+	d := DefaultLayout()
+
+	for _, pair := range [][2]*TabLayout{
+		{&l.Libraries, &d.Libraries},
+		{&l.Experiments, &d.Experiments},
+		{&l.Samples, &d.Samples},
+	} {
+		if pair[0].Tab == "" {
+			pair[0].Tab = pair[1].Tab
+		}
+	}
+}
+
+func (l *Layout) check() error {
+	for t, s := range map[*TabLayout]schema{
+		&l.Libraries:   schemaFor(reflect.TypeFor[types.Library]()),
+		&l.Experiments: schemaFor(reflect.TypeFor[experimentRow]()),
+		&l.Samples:     schemaFor(reflect.TypeFor[sampleRow]()),
+	} {
+		if err := t.checkColumns(s); err != nil {
+			return err
+		}
+	}
+
+	tabs := l.tabOrder()
+	seen := make(map[string]bool, len(tabs))
+
+	for _, tab := range tabs {
+		if seen[normaliseHeader(tab)] {
+			return fmt.Errorf("%w: %s", ErrDuplicateLayoutTab, tab)
+		}
+
+		seen[normaliseHeader(tab)] = true
+	}
+
+	return nil
+}
+
+// tabOrder returns all our tab names in the order DimSumMetaData() reads them.
+func (l *Layout) tabOrder() []string {
+	return slices.Concat(l.Libraries.tabs(), l.Experiments.tabs(), l.Samples.tabs())
+}
+
+// orDefault returns l, or DefaultLayout() if l is nil.
+func (l *Layout) orDefault() *Layout {
+	if l == nil {
+		return DefaultLayout()
+	}
+
+	return l
+}
@@ -0,0 +1,160 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Authors:
+ *	- Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package sheets
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/wtsi-hgi/dimsum-automation/config"
+)
+
+const testLayout = `libraries:
+  tab: Library list
+  columns:
+    library_id: Library name
+samples:
+  tab: Samples 2025
+  additional_tabs: [Samples 2024]
+  columns:
+    mlwh_sample_name: Sanger sample name
+    experiment_id: Experiment
+`
+
+func TestLayout(t *testing.T) {
+	Convey("You can load a Layout from a YAML file", t, func() {
+		dir := t.TempDir()
+		layoutPath := filepath.Join(dir, "layout.yml")
+		writeLayout(layoutPath, testLayout)
+
+		l, err := LoadLayout(layoutPath)
+		So(err, ShouldBeNil)
+		So(l.Libraries.Tab, ShouldEqual, "Library list")
+		So(l.Experiments.Tab, ShouldEqual, "experiments")
+		So(l.Samples.AdditionalTabs, ShouldResemble, []string{"Samples 2024"})
+		So(l.tabOrder(), ShouldResemble, []string{"Library list", "experiments", "Samples 2025", "Samples 2024"})
+
+		Convey("Unknown columns and reused tabs are errors", func() {
+			writeLayout(layoutPath, "samples:\n  columns:\n    sample_colour: Colour\n")
+
+			_, err = LoadLayout(layoutPath)
+			So(errors.Is(err, ErrUnknownLayoutColumn), ShouldBeTrue)
+
+			writeLayout(layoutPath, "samples:\n  tab: Experiments\n")
+
+			_, err = LoadLayout(layoutPath)
+			So(errors.Is(err, ErrDuplicateLayoutTab), ShouldBeTrue)
+		})
+
+		Convey("Configuring a column and its alias is an error", func() {
+			writeLayout(layoutPath, "samples:\n  columns:\n    mlwh_sample_name: Sanger name\n    sample_name: Name\n")
+
+			_, err = LoadLayout(layoutPath)
+			So(errors.Is(err, ErrAmbiguousLayout), ShouldBeTrue)
+			So(err.Error(), ShouldContainSubstring, `["mlwh_sample_name" "sample_name"]`)
+
+			writeLayout(layoutPath, "samples:\n  columns:\n    sample_name: Name\n    Sample_Name: Name\n")
+
+			_, err = LoadLayout(layoutPath)
+			So(errors.Is(err, ErrAmbiguousLayout), ShouldBeTrue)
+		})
+
+		Convey("Files with that layout give the same metadata as the default layout", func() {
+			expected, err := NewFiles(testMetadataDir)
+			So(err, ShouldBeNil)
+
+			expectedLibs, err := expected.DimSumMetaData("")
+			So(err, ShouldBeNil)
+
+			dataDir := filepath.Join(dir, "data")
+			So(os.Mkdir(dataDir, dirPerms), ShouldBeNil)
+
+			for _, name := range sheetNames {
+				records, err := readDelimited(filepath.Join(testMetadataDir, name+csvExt), false)
+				So(err, ShouldBeNil)
+
+				switch name {
+				case "libraries":
+					records[0][0] = "Library name"
+					writeDelimited(filepath.Join(dataDir, "Library list"+csvExt), records, ',')
+				case "experiments":
+					writeDelimited(filepath.Join(dataDir, name+csvExt), records, ',')
+				case "samples":
+					records[0][0] = "Experiment"
+					records[0][1] = "Sanger sample name"
+					writeDelimited(filepath.Join(dataDir, "Samples 2025"+csvExt), records[:3], ',')
+					writeDelimited(filepath.Join(dataDir, "Samples 2024"+csvExt),
+						append([][]string{records[0]}, records[3:]...), ',')
+				}
+			}
+
+			c := &config.Config{SheetPath: dataDir, LayoutPath: layoutPath}
+
+			f, err := FromConfig(c)
+			So(err, ShouldBeNil)
+
+			libs, err := f.DimSumMetaData("")
+			So(err, ShouldBeNil)
+			So(libs, ShouldResemble, expectedLibs)
+
+			df, err := NewFiles(dataDir)
+			So(err, ShouldBeNil)
+
+			_, err = df.DimSumMetaData("")
+			So(err, ShouldNotBeNil)
+
+			Convey("Problems are reported against the configured tabs", func() {
+				err = f.Validate("", nil)
+
+				var cellErrs CellErrors
+				So(errors.As(err, &cellErrs), ShouldBeTrue)
+				So(cellErrs[len(cellErrs)-1].Sheet, ShouldEqual, "experiments")
+
+				writeDelimited(filepath.Join(dataDir, "Samples 2024"+csvExt),
+					[][]string{
+						{"Experiment", "Sanger sample name", "selection", "experiment_replicate", "selection_time", "cell_density"},
+						{"exp9", "s", "input", "1", "", ""},
+					}, ',')
+
+				_, err = f.DimSumMetaData("")
+				So(errors.As(err, &cellErrs), ShouldBeTrue)
+				So(cellErrs, ShouldHaveLength, 1)
+				So(cellErrs[0].Error(), ShouldEqual, `sheet "Samples 2024" row 2 column "Experiment": `+
+					`"exp9" is not an experiment_id in the experiments sheet`)
+			})
+		})
+	})
+}
+
+const dirPerms = 0700
+
+func writeLayout(path, content string) {
+	So(os.WriteFile(path, []byte(content), userPerms), ShouldBeNil)
+}
@@ -26,7 +26,13 @@
 
 package sheets
 
-import "github.com/wtsi-hgi/dimsum-automation/types"
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/wtsi-hgi/dimsum-automation/types"
+)
 
 const (
 	ErrNoData            = Error("no data found in sheet")
@@ -34,249 +40,325 @@ const (
 	ErrMissingExperiment = Error("sample's experiment not found in experiments sheet")
 )
 
-// DimSumMetaData reads sheets "Libraries", "Experiments" and "Samples" from the
+// SheetReader is something that can Read() the named sheets of a spreadsheet.
+type SheetReader interface {
+	Read(sheetID, sheetName string) (*Sheet, error)
+}
+
+// MetaDataReader is a SheetReader that can also extract DimSum metadata from
+// the sheets it reads, like Sheets and Files.
+type MetaDataReader interface {
+	SheetReader
+	DimSumMetaData(sheetID string) (types.Libraries, error)
+	Validate(sheetID string, pathExists func(string) bool) error
+}
+
+// SetLayout makes DimSumMetaData() and Validate() read the tabs and columns
+// described by the given Layout, instead of DefaultLayout().
+func (s *Sheets) SetLayout(l *Layout) {
+	s.layout = l
+}
+
+// DimSumMetaData reads sheets "libraries", "experiments" and "samples" from the
 // sheet with the given id and extracts metadata for columns relevant to DimSum,
 // returning a slice of Library that each contain a slice of their Experiments,
-// that each contain a slice of their Samples.
+// that each contain a slice of their Samples. (Other tab and column names can
+// be used by calling SetLayout() first.) All the tabs are retrieved in a single
+// request.
+//
+// The "samples" sheet may optionally have "run_id" and "technical_replicate"
+// columns, which if filled in restrict a sample row to that MLWH run and
+// explicitly set its technical replicate number.
+//
+// If any cells have invalid values (or refer to libraries or experiments that
+// don't exist), a CellErrors listing every such problem is returned.
 func (s *Sheets) DimSumMetaData(sheetID string) (types.Libraries, error) {
-	libs, libLookup, err := s.getLibraryMetaData(sheetID)
+	return dimSumMetaData(s, sheetID, s.layout, nil)
+}
+
+// Validate reads the metadata in the given spreadsheet like DimSumMetaData(),
+// but also checks that the values would make sense to DiMSum (see the
+// Problems() methods of types.Library, types.Experiment and types.Sample). If
+// pathExists is not nil, it is used to check that the paths in the experiments
+// sheet exist.
+//
+// Returns a CellErrors listing every problem found, or nil if there were none.
+func (s *Sheets) Validate(sheetID string, pathExists func(string) bool) error {
+	return validate(s, sheetID, s.layout, pathExists)
+}
+
+// validate implements Validate() for any SheetReader.
+func validate(s SheetReader, sheetID string, layout *Layout, pathExists func(string) bool) error {
+	_, err := dimSumMetaData(s, sheetID, layout, &validator{
+		pathExists: pathExists,
+		expRows:    make(map[*types.Experiment]*parsedRow[experimentRow]),
+	})
+
+	return err
+}
+
+// validator adds the Problems() of the values read from the sheets to
+// CellErrors, attributed to the rows the values came from.
+type validator struct {
+	pathExists func(string) bool
+	expRows    map[*types.Experiment]*parsedRow[experimentRow]
+}
+
+// addProblems adds the given problems with the given row to cellErrs, skipping
+// those for cells that already have a CellError.
+func addProblems[T any](cellErrs *CellErrors, row *parsedRow[T], ps []*types.Problem) {
+	for _, p := range ps {
+		ce := row.cellError(p.Column, p.Expected, p)
+		ce.Value = p.Value
+
+		if !cellErrs.has(ce) {
+			*cellErrs = append(*cellErrs, ce)
+		}
+	}
+}
+
+// checkExperiments adds the problems of every experiment read, which can only
+// be done once their samples have been read. Invalid wildtype sequences
+// inherited from a library are not repeated, since they're reported on the
+// library's row.
+func (v *validator) checkExperiments(cellErrs *CellErrors) {
+	for exp, row := range v.expRows {
+		ps := exp.Problems(v.pathExists)
+
+		if row.cell("wildtypeSequence") == "" {
+			ps = slices.DeleteFunc(ps, func(p *types.Problem) bool {
+				return errors.Is(p, types.ErrInvalidSequence)
+			})
+		}
+
+		addProblems(cellErrs, row, ps)
+	}
+}
+
+// multiSheetReader is a SheetReader that can read multiple sheets at once,
+// like Sheets.
+type multiSheetReader interface {
+	SheetReader
+	ReadMany(sheetID string, sheetNames ...string) ([]*Sheet, error)
+}
+
+// prefetchedReader is a SheetReader that returns already read sheets.
+type prefetchedReader map[string]*Sheet
+
+// Read returns the named sheet, or ErrSheetNotFound if it wasn't prefetched.
+func (p prefetchedReader) Read(_, sheetName string) (*Sheet, error) {
+	sheet, ok := p[sheetName]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrSheetNotFound, sheetName)
+	}
+
+	return sheet, nil
+}
+
+// prefetch returns a SheetReader for the given sheets. If s is a
+// multiSheetReader, the sheets are read with a single ReadMany() call now,
+// otherwise s is returned to read them one at a time.
+func prefetch(s SheetReader, sheetID string, sheetNames []string) (SheetReader, error) {
+	m, ok := s.(multiSheetReader)
+	if !ok {
+		return s, nil
+	}
+
+	sheets, err := m.ReadMany(sheetID, sheetNames...)
 	if err != nil {
 		return nil, err
 	}
 
-	exps, expLookup, err := s.getExperimentMetaData(sheetID, libs, libLookup)
+	p := make(prefetchedReader, len(sheetNames))
+
+	for i, name := range sheetNames {
+		p[name] = sheets[i]
+	}
+
+	return p, nil
+}
+
+// metaDataReading holds the state of a single dimSumMetaData() call.
+type metaDataReading struct {
+	s        SheetReader
+	sheetID  string
+	layout   *Layout
+	v        *validator
+	cellErrs CellErrors
+}
+
+// dimSumMetaData implements DimSumMetaData() for any SheetReader, reading the
+// tabs and columns described by the given Layout (nil meaning
+// DefaultLayout()). If there are problems with any cell values, all of them
+// are returned as CellErrors. If given a validator, problems with the sense of
+// the values are also returned.
+func dimSumMetaData(s SheetReader, sheetID string, layout *Layout, v *validator) (types.Libraries, error) {
+	layout = layout.orDefault()
+
+	s, err := prefetch(s, sheetID, layout.tabOrder())
 	if err != nil {
 		return nil, err
 	}
 
-	err = s.getSampleMetaData(sheetID, exps, expLookup)
+	r := &metaDataReading{s: s, sheetID: sheetID, layout: layout, v: v}
+
+	libs, libLookup, err := r.libraries()
 	if err != nil {
 		return nil, err
 	}
 
-	return libs, nil
-}
+	exps, expLookup, err := r.experiments(libs, libLookup)
+	if err != nil {
+		return nil, err
+	}
 
-func (s *Sheets) getLibraryMetaData(sheetID string) (types.Libraries, map[string]int, error) { //nolint:funlen
-	sheet, err := s.Read(sheetID, "libraries")
+	err = r.samples(exps, expLookup)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
-	if len(sheet.Rows) == 0 {
-		return nil, nil, ErrNoData
+	if v != nil {
+		v.checkExperiments(&r.cellErrs)
 	}
 
-	libRows, err := sheet.Columns(
-		"library_id",
-		"wildtypeSequence",
-		"maxSubstitutions",
-	)
-	if err != nil {
-		return nil, nil, err
+	if err = r.cellErrs.sorted(r.layout.tabOrder()).orNil(); err != nil {
+		return nil, err
 	}
 
-	libs := make(types.Libraries, len(libRows))
-	lookup := make(map[string]int, len(libRows))
+	return libs, nil
+}
+
+// experimentRow is a row of the experiments sheet.
+type experimentRow struct {
+	LibraryID string `sheet:"library_id|library"`
+	types.Experiment
+}
+
+// sampleRow is a row of the samples sheet.
+type sampleRow struct {
+	ExperimentID string `sheet:"experiment_id|experiment"`
+	types.Sample
+}
 
-	c := converter{}
+// readSheet reads the tabs of the given TabLayout and parses their rows in to
+// Ts, returning ErrNoData if they have no rows. Problems with cell values are
+// added to the reading's CellErrors.
+func readSheet[T any](r *metaDataReading, t *TabLayout) ([]*parsedRow[T], error) {
+	var rows []*parsedRow[T]
 
-	for i, row := range libRows {
-		libs[i] = &types.Library{
-			LibraryID:        row[0],
-			WildtypeSequence: row[1],
-			MaxSubstitutions: c.ToInt(row[2]),
+	for _, name := range t.tabs() {
+		sheet, err := r.s.Read(r.sheetID, name)
+		if err != nil {
+			return nil, err
 		}
 
-		lookup[row[0]] = i
+		if sheet == nil {
+			continue
+		}
+
+		tabRows, errs, err := parseSheet[T](sheet, name, t)
+		r.cellErrs = append(r.cellErrs, errs...)
+
+		if err != nil {
+			return nil, err
+		}
+
+		rows = append(rows, tabRows...)
+	}
+
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNoData, t.Tab)
 	}
 
-	return libs, lookup, c.Err
+	return rows, nil
 }
 
-func (s *Sheets) getExperimentMetaData( //nolint:gocognit,gocyclo,funlen
-	sheetID string, libs types.Libraries, libLookup map[string]int,
-) ([]*types.Experiment, map[string]int, error) {
-	sheet, err := s.Read(sheetID, "experiments")
+func (r *metaDataReading) libraries() (types.Libraries, map[string]int, error) {
+	rows, err := readSheet[types.Library](r, &r.layout.Libraries)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	if len(sheet.Rows) == 0 {
-		return nil, nil, ErrNoData
+	libs := make(types.Libraries, len(rows))
+	lookup := make(map[string]int, len(rows))
+
+	for i, row := range rows {
+		libs[i] = row.value
+		lookup[row.value.LibraryID] = i
+
+		if r.v != nil {
+			addProblems(&r.cellErrs, row, row.value.Problems())
+		}
 	}
 
-	expRows, err := sheet.Columns(
-		"library_id",
-		"experiment_id",
-		"Assay",
-		"startStage",
-		"stopStage",
-		"barcodeDesignPath",
-		"barcodeErrorRate",
-		"experimentDesignPairDuplicates",
-		"countPath",
-		"barcodeIdentityPath",
-		"cutadapt5First",
-		"cutadapt5Second",
-		"cutadaptMinLength",
-		"cutadaptErrorRate",
-		"cutadaptOverlap",
-		"cutadaptCut5First",
-		"cutadaptCut5Second",
-		"cutadaptCut3First",
-		"cutadaptCut3Second",
-		"vsearchMinQual",
-		"vsearchMaxQual",
-		"vsearchMaxee",
-		"vsearchMinovlen",
-		"reverseComplement",
-		"wildtypeSequence",
-		"permittedSequences",
-		"sequenceType",
-		"mutagenesisType",
-		"indels",
-		"maxSubstitutions",
-		"mixedSubstitutions",
-		"fitnessMinInputCountAll",
-		"fitnessMinInputCountAny",
-		"fitnessMinOutputCountAll",
-		"fitnessMinOutputCountAny",
-		"fitnessNormalise",
-		"fitnessErrorModel",
-		"fitnessDropoutPseudocount",
-		"retainedReplicates",
-		"stranded",
-		"paired",
-		"synonymSequencePath",
-		"transLibrary",
-		"transLibraryReverseComplement",
-	)
+	return libs, lookup, nil
+}
+
+func (r *metaDataReading) experiments(
+	libs types.Libraries, libLookup map[string]int,
+) ([]*types.Experiment, map[string]int, error) {
+	rows, err := readSheet[experimentRow](r, &r.layout.Experiments)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	exps := make([]*types.Experiment, len(expRows))
-	lookup := make(map[string]int, len(expRows))
-
-	c := converter{}
+	exps := make([]*types.Experiment, 0, len(rows))
+	lookup := make(map[string]int, len(rows))
 
-	for i, row := range expRows {
-		libI, ok := libLookup[row[0]]
+	for _, row := range rows {
+		libI, ok := libLookup[row.value.LibraryID]
 		if !ok {
-			return nil, nil, ErrMissingLibrary
+			r.cellErrs = append(r.cellErrs, row.cellError("library_id",
+				fmt.Sprintf("a library_id in the %s sheet", r.layout.Libraries.Tab), ErrMissingLibrary))
+
+			continue
 		}
 
 		lib := libs[libI]
+		exp := &row.value.Experiment
 
-		ws := row[24]
-		if ws == "" {
-			ws = lib.WildtypeSequence
+		if row.cell("wildtypeSequence") == "" {
+			exp.WildtypeSequence = lib.WildtypeSequence
 		}
 
-		ms := lib.MaxSubstitutions
-		if row[29] != "" {
-			ms = c.ToInt(row[29])
+		if row.cell("maxSubstitutions") == "" {
+			exp.MaxSubstitutions = lib.MaxSubstitutions
 		}
 
-		exps[i] = &types.Experiment{
-			ExperimentID:                   row[1],
-			Assay:                          row[2],
-			StartStage:                     c.ToInt(row[3]),
-			StopStage:                      c.ToInt(row[4]),
-			BarcodeDesignPath:              row[5],
-			BarcodeErrorRate:               c.ToFloatString(row[6]),
-			ExperimentDesignPairDuplicates: c.ToBool(row[7]),
-			CountPath:                      row[8],
-			BarcodeIdentityPath:            row[9],
-			Cutadapt5First:                 row[10],
-			Cutadapt5Second:                row[11],
-			CutadaptMinLength:              c.ToInt(row[12]),
-			CutadaptErrorRate:              c.ToFloatString(row[13]),
-			CutadaptOverlap:                c.ToInt(row[14]),
-			CutadaptCut5First:              row[15],
-			CutadaptCut5Second:             row[16],
-			CutadaptCut3First:              row[17],
-			CutadaptCut3Second:             row[18],
-			VsearchMinQual:                 c.ToInt(row[19]),
-			VsearchMaxQual:                 c.ToInt(row[20]),
-			VsearchMaxee:                   c.ToInt(row[21]),
-			VsearchMinovlen:                c.ToInt(row[22]),
-			ReverseComplement:              c.ToBool(row[23]),
-			WildtypeSequence:               ws,
-			PermittedSequences:             row[25],
-			SequenceType:                   c.ToSequenceType(row[26]),
-			MutagenesisType:                c.ToMutagenesisType(row[27]),
-			Indels:                         row[28],
-			MaxSubstitutions:               ms,
-			MixedSubstitutions:             c.ToBool(row[30]),
-			FitnessMinInputCountAll:        c.ToInt(row[31]),
-			FitnessMinInputCountAny:        c.ToInt(row[32]),
-			FitnessMinOutputCountAll:       c.ToInt(row[33]),
-			FitnessMinOutputCountAny:       c.ToInt(row[34]),
-			FitnessNormalise:               c.ToBool(row[35]),
-			FitnessErrorModel:              c.ToBool(row[36]),
-			FitnessDropoutPseudocount:      c.ToInt(row[37]),
-			RetainedReplicates:             row[38],
-			Stranded:                       c.ToBool(row[39]),
-			Paired:                         c.ToBool(row[40]),
-			SynonymSequencePath:            row[41],
-			TransLibrary:                   c.ToBool(row[42]),
-			TransLibraryReverseComplement:  c.ToBool(row[43]),
+		if r.v != nil {
+			r.v.expRows[exp] = row
 		}
 
-		lib.Experiments = append(lib.Experiments, exps[i])
-
-		lookup[row[1]] = i
+		lookup[exp.ExperimentID] = len(exps)
+		exps = append(exps, exp)
+		lib.Experiments = append(lib.Experiments, exp)
 	}
 
-	return exps, lookup, c.Err
+	return exps, lookup, nil
 }
 
-func (s *Sheets) getSampleMetaData( //nolint:funlen
-	sheetID string, exps []*types.Experiment, expLookup map[string]int) error {
-	sheet, err := s.Read(sheetID, "samples")
+func (r *metaDataReading) samples(exps []*types.Experiment, expLookup map[string]int) error {
+	rows, err := readSheet[sampleRow](r, &r.layout.Samples)
 	if err != nil {
 		return err
 	}
 
-	if len(sheet.Rows) == 0 {
-		return ErrNoData
-	}
-
-	sampleRows, err := sheet.Columns(
-		"experiment_id",
-		"mlwh_sample_name",
-		"selection",
-		"experiment_replicate",
-		"selection_time",
-		"cell_density",
-	)
-	if err != nil {
-		return err
-	}
-
-	samples := make([]*types.Sample, len(sampleRows))
-
-	c := converter{}
-
-	for i, row := range sampleRows {
-		expI, ok := expLookup[row[0]]
+	for _, row := range rows {
+		expI, ok := expLookup[row.value.ExperimentID]
 		if !ok {
-			return ErrMissingExperiment
-		}
+			r.cellErrs = append(r.cellErrs, row.cellError("experiment_id",
+				fmt.Sprintf("an experiment_id in the %s sheet", r.layout.Experiments.Tab), ErrMissingExperiment))
 
-		samples[i] = &types.Sample{
-			SampleName:          row[1],
-			Selection:           c.ToSelection(row[2]),
-			ExperimentReplicate: c.ToInt(row[3]),
-			SelectionTime:       c.ToFloatString(row[4]),
-			CellDensity:         c.ToFloatString(row[5]),
+			continue
 		}
 
 		exp := exps[expI]
-		exp.Samples = append(exp.Samples, samples[i])
+		exp.Samples = append(exp.Samples, &row.value.Sample)
+
+		if r.v != nil {
+			addProblems(&r.cellErrs, row, row.value.Problems())
+		}
 	}
 
-	return c.Err
+	return nil
 }
@@ -0,0 +1,104 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Authors:
+ *	- Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package sheets
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/wtsi-hgi/dimsum-automation/types"
+)
+
+func TestValidate(t *testing.T) {
+	Convey("Validate reports values that wouldn't make sense to DiMSum, by row", t, func() {
+		f, err := NewFiles(testMetadataDir)
+		So(err, ShouldBeNil)
+
+		err = f.Validate("", nil)
+		So(errors.Is(err, types.ErrNotCodons), ShouldBeTrue)
+
+		var cellErrs CellErrors
+		So(errors.As(err, &cellErrs), ShouldBeTrue)
+		So(cellErrs, ShouldHaveLength, 5)
+		So(cellErrs[0].Error(), ShouldEqual, `sheet "experiments" row 2 column "wildtypeSequence": `+
+			`"AAAA" is not a multiple of 3 bases long, since sequenceType is coding`)
+		So(cellErrs[2].Error(), ShouldEqual, `sheet "experiments" row 3 column "experiment_id": `+
+			`"exp2" is not an experiment with both input and output samples`)
+
+		_, err = f.DimSumMetaData("")
+		So(err, ShouldBeNil)
+
+		Convey("Including missing paths, and without repeating problems", func() {
+			dir := t.TempDir()
+
+			for _, name := range sheetNames {
+				records, err := readDelimited(filepath.Join(testMetadataDir, name+csvExt), false)
+				So(err, ShouldBeNil)
+
+				switch name {
+				case "libraries":
+					records[1][1] = "AAAX"
+				case "samples":
+					records[1][3] = "first"
+				}
+
+				writeDelimited(filepath.Join(dir, name+csvExt), records, ',')
+			}
+
+			bf, err := NewFiles(dir)
+			So(err, ShouldBeNil)
+
+			err = bf.Validate("", func(path string) bool { return path != "/path/to/bi.txt" })
+			So(errors.As(err, &cellErrs), ShouldBeTrue)
+
+			msgs := make([]string, len(cellErrs))
+			for i, ce := range cellErrs {
+				msgs[i] = ce.Error()
+			}
+
+			So(msgs, ShouldResemble, []string{
+				`sheet "libraries" row 2 column "wildtypeSequence": "AAAX" is not a sequence of A, C, G and T`,
+				`sheet "experiments" row 2 column "wildtypeSequence": ` +
+					`"AAAX" is not a multiple of 3 bases long, since sequenceType is coding`,
+				`sheet "experiments" row 2 column "barcodeIdentityPath": "/path/to/bi.txt" is not an existing path`,
+				`sheet "experiments" row 3 column "wildtypeSequence": ` +
+					`"TTTT" is not a multiple of 3 bases long, since sequenceType is coding`,
+				`sheet "experiments" row 3 column "experiment_id": ` +
+					`"exp2" is not an experiment with both input and output samples`,
+				`sheet "experiments" row 3 column "barcodeIdentityPath": "/path/to/bi.txt" is not an existing path`,
+				`sheet "experiments" row 4 column "wildtypeSequence": ` +
+					`"CCCC" is not a multiple of 3 bases long, since sequenceType is coding`,
+				`sheet "experiments" row 4 column "experiment_id": ` +
+					`"exp3" is not an experiment with both input and output samples`,
+				`sheet "experiments" row 4 column "barcodeIdentityPath": "/path/to/bi.txt" is not an existing path`,
+				`sheet "samples" row 2 column "experiment_replicate": "first" is not a whole number`,
+			})
+		})
+	})
+}
@@ -0,0 +1,318 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Authors:
+ *	- Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package sheets
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	googleSheets "google.golang.org/api/sheets/v4"
+)
+
+const (
+	// RunsSheetName is the name of the tab that UpdateRunStatus() writes to. It
+	// must already exist in the spreadsheet, but can be empty.
+	RunsSheetName = "runs"
+
+	// RunStateRunning, RunStateComplete and RunStateFailed are the values we
+	// write to the status columns of the runs sheet.
+	RunStateRunning  = "running"
+	RunStateComplete = "complete"
+	RunStateFailed   = "failed"
+
+	// RunTimeFormat is the format of the started and updated columns.
+	RunTimeFormat = time.RFC3339
+
+	colExperimentID = "experiment_id"
+	colSamples      = "samples"
+	colFastqStatus  = "fastq_status"
+	colFastqDir     = "fastq_dir"
+	colDimSumStatus = "dimsum_status"
+	colOutputDir    = "output_dir"
+	colKeyHash      = "key_hash"
+	colStarted      = "started"
+	colUpdated      = "updated"
+	colMessage      = "message"
+
+	valueInputRaw   = "RAW"
+	insertDataRows  = "INSERT_ROWS"
+	firstColumnName = "A"
+)
+
+// runsHeaders are the columns of the runs sheet, in the order we create them.
+var runsHeaders = []string{ //nolint:gochecknoglobals
+	colExperimentID, colSamples, colFastqStatus, colFastqDir, colDimSumStatus,
+	colOutputDir, colKeyHash, colStarted, colUpdated, colMessage,
+}
+
+// RunStatusWriter is something that can record the progress of a run.
+type RunStatusWriter interface {
+	UpdateRunStatus(sheetID string, status *RunStatus) error
+}
+
+// RunStatus describes the progress of getting the fastqs for and running
+// DiMSum on a particular set of sample runs of an experiment. A row in the runs
+// sheet is identified by its ExperimentID and Samples.
+type RunStatus struct {
+	ExperimentID string
+	// Samples is the sorted, comma separated sampleName:runID pairs.
+	Samples      string
+	FastqStatus  string
+	FastqDir     string
+	DimSumStatus string
+	OutputDir    string
+	KeyHash      string
+	// Message is typically the error of a failed run. Unlike the other
+	// properties, it is always written, so a blank Message clears any old one.
+	Message string
+}
+
+// values returns our properties keyed on runs sheet column header, leaving out
+// blank ones apart from the message.
+func (r *RunStatus) values() map[string]string {
+	vals := map[string]string{colMessage: r.Message}
+
+	for col, val := range map[string]string{
+		colExperimentID: r.ExperimentID,
+		colSamples:      r.Samples,
+		colFastqStatus:  r.FastqStatus,
+		colFastqDir:     r.FastqDir,
+		colDimSumStatus: r.DimSumStatus,
+		colOutputDir:    r.OutputDir,
+		colKeyHash:      r.KeyHash,
+	} {
+		if val != "" {
+			vals[col] = val
+		}
+	}
+
+	return vals
+}
+
+// UpdateRunStatus finds the row in the runs sheet of the given spreadsheet with
+// the status' ExperimentID and Samples, and updates its cells with the
+// status' non-blank properties and the current time. If there is no such row,
+// one is appended. If the runs sheet is empty, the column headers are written
+// first.
+//
+// Calls on the same Sheets are serialised, but the Sheets API has no way to
+// lock a sheet, so if other processes update the status of the same run at the
+// same time, they may each append a row for it. Such duplicate rows are merged
+// in to the first one (see mergeRunRows()) and blanked by the next update.
+//
+// The Sheets must have been made with NewWritable().
+func (s *Sheets) UpdateRunStatus(sheetID string, status *RunStatus) error {
+	s.runsMu.Lock()
+	defer s.runsMu.Unlock()
+
+	sheet, err := s.Read(sheetID, RunsSheetName)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().Format(RunTimeFormat)
+	vals := status.values()
+	vals[colUpdated] = now
+
+	if sheet == nil {
+		vals[colStarted] = now
+
+		return s.appendRows(sheetID, [][]string{runsHeaders, fillRow(nil, runsHeaders, vals)})
+	}
+
+	if err = checkRunsColumns(sheet); err != nil {
+		return err
+	}
+
+	indexes := findRunRows(sheet, status)
+	if len(indexes) == 0 {
+		vals[colStarted] = now
+
+		return s.appendRows(sheetID, [][]string{fillRow(nil, sheet.ColumnHeaders, vals)})
+	}
+
+	merged := mergeRunRows(sheet, indexes)
+
+	if merged[sheet.columnIndex(colStarted)] == "" {
+		vals[colStarted] = now
+	}
+
+	err = s.updateRow(sheetID, indexes[0]+firstDataRowNumber, fillRow(merged, sheet.ColumnHeaders, vals))
+	if err != nil {
+		return err
+	}
+
+	for _, i := range indexes[1:] {
+		if err = s.updateRow(sheetID, i+firstDataRowNumber, make([]string, len(sheet.Rows[i]))); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkRunsColumns returns a *MissingColumnsError if the given sheet lacks any
+// of our runs columns.
+func checkRunsColumns(sheet *Sheet) error {
+	var missing []string
+
+	for _, col := range runsHeaders {
+		if sheet.columnIndex(col) < 0 {
+			missing = append(missing, col)
+		}
+	}
+
+	if len(missing) > 0 {
+		return &MissingColumnsError{Sheet: RunsSheetName, Columns: missing}
+	}
+
+	return nil
+}
+
+// findRunRows returns the indexes of the rows in the given runs sheet with the
+// given status' ExperimentID and Samples. There should only be one, but
+// concurrent updates can result in duplicates.
+func findRunRows(sheet *Sheet, status *RunStatus) []int {
+	var indexes []int
+
+	for i, row := range sheet.OptionalColumns(colExperimentID, colSamples) {
+		if row[0] == status.ExperimentID && row[1] == status.Samples {
+			indexes = append(indexes, i)
+		}
+	}
+
+	return indexes
+}
+
+// mergeRunRows returns the cells of the rows with the given indexes in the
+// given runs sheet merged in to one. Taking the rows in order of their updated
+// time, each cell has the last non-blank value, except that started is the
+// earliest, and message is that of the last row.
+func mergeRunRows(sheet *Sheet, indexes []int) []string {
+	startedCol := sheet.columnIndex(colStarted)
+	updatedCol := sheet.columnIndex(colUpdated)
+	messageCol := sheet.columnIndex(colMessage)
+
+	ordered := slices.Clone(indexes)
+	slices.SortStableFunc(ordered, func(a, b int) int {
+		return compareRunTimes(cellAt(sheet.Rows[a], updatedCol), cellAt(sheet.Rows[b], updatedCol))
+	})
+
+	merged := make([]string, len(sheet.ColumnHeaders))
+
+	for _, i := range ordered {
+		for j, val := range sheet.Rows[i] {
+			if j >= len(merged) {
+				merged = append(merged, make([]string, j-len(merged)+1)...)
+			}
+
+			switch {
+			case j == messageCol:
+				merged[j] = val
+			case val == "":
+			case j == startedCol && merged[j] != "" && compareRunTimes(merged[j], val) <= 0:
+			default:
+				merged[j] = val
+			}
+		}
+	}
+
+	return merged
+}
+
+// cellAt returns the value at the given index of the given row, or blank if
+// it's out of range.
+func cellAt(row []string, i int) string {
+	if i < 0 || i >= len(row) {
+		return ""
+	}
+
+	return row[i]
+}
+
+// compareRunTimes compares the given started or updated times, falling back to
+// comparing them as strings if they're not in RunTimeFormat.
+func compareRunTimes(a, b string) int {
+	ta, errA := time.Parse(RunTimeFormat, a)
+	tb, errB := time.Parse(RunTimeFormat, b)
+
+	if errA != nil || errB != nil {
+		return strings.Compare(a, b)
+	}
+
+	return ta.Compare(tb)
+}
+
+// fillRow returns a copy of the given row, extended to the length of the given
+// headers, with the cells under headers that are keys of vals set to their
+// values.
+func fillRow(row []string, headers []string, vals map[string]string) []string {
+	filled := make([]string, max(len(row), len(headers)))
+	copy(filled, row)
+
+	for i, header := range headers {
+		if val, ok := vals[normaliseHeader(header)]; ok {
+			filled[i] = val
+		}
+	}
+
+	return filled
+}
+
+// appendRows adds the given rows after the last row of the runs sheet.
+func (s *Sheets) appendRows(sheetID string, rows [][]string) error {
+	_, err := s.srv.Spreadsheets.Values.Append(sheetID, RunsSheetName, toValueRange(rows)).
+		ValueInputOption(valueInputRaw).InsertDataOption(insertDataRows).Do()
+
+	return err
+}
+
+// updateRow replaces the cells of the given row number of the runs sheet.
+func (s *Sheets) updateRow(sheetID string, rowNumber int, row []string) error {
+	rangeStr := fmt.Sprintf("%s!%s%d", RunsSheetName, firstColumnName, rowNumber)
+
+	_, err := s.srv.Spreadsheets.Values.Update(sheetID, rangeStr, toValueRange([][]string{row})).
+		ValueInputOption(valueInputRaw).Do()
+
+	return err
+}
+
+func toValueRange(rows [][]string) *googleSheets.ValueRange {
+	values := make([][]any, len(rows))
+
+	for i, row := range rows {
+		values[i] = make([]any, len(row))
+
+		for j, cell := range row {
+			values[i][j] = cell
+		}
+	}
+
+	return &googleSheets.ValueRange{Values: values}
+}
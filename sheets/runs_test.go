@@ -0,0 +1,199 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Authors:
+ *	- Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package sheets
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// toAny converts the given strings to a row of fake sheet cells.
+func toAny(strs []string) []any {
+	row := make([]any, len(strs))
+
+	for i, s := range strs {
+		row[i] = s
+	}
+
+	return row
+}
+
+func TestRunStatus(t *testing.T) {
+	Convey("Given a fake Google sheet with an empty runs tab", t, func() {
+		fake := newFakeSheetsServer("sheetID", map[string][][]any{RunsSheetName: {}})
+		defer fake.Close()
+
+		s, err := fake.sheets()
+		So(err, ShouldBeNil)
+
+		var _ RunStatusWriter = s
+
+		status := &RunStatus{
+			ExperimentID: "exp1",
+			Samples:      "s1:1,s2:2",
+			FastqStatus:  RunStateRunning,
+			FastqDir:     "/fastqs",
+		}
+
+		Convey("You can add a run status, which writes the headers first", func() {
+			err = s.UpdateRunStatus("sheetID", status)
+			So(err, ShouldBeNil)
+
+			sheet, errr := s.Read("sheetID", RunsSheetName)
+			So(errr, ShouldBeNil)
+			So(sheet.ColumnHeaders, ShouldResemble, runsHeaders)
+			So(sheet.Rows, ShouldHaveLength, 1)
+
+			row := sheet.OptionalColumns(colExperimentID, colSamples, colFastqStatus, colFastqDir,
+				colDimSumStatus, colStarted, colUpdated)[0]
+			So(row[:5], ShouldResemble, []string{"exp1", "s1:1,s2:2", RunStateRunning, "/fastqs", ""})
+
+			started, errp := time.Parse(RunTimeFormat, row[5])
+			So(errp, ShouldBeNil)
+			So(started, ShouldHappenWithin, time.Minute, time.Now())
+			So(row[6], ShouldEqual, row[5])
+
+			Convey("Then update the same row without losing earlier values", func() {
+				err = s.UpdateRunStatus("sheetID", &RunStatus{
+					ExperimentID: "exp1",
+					Samples:      "s1:1,s2:2",
+					DimSumStatus: RunStateFailed,
+					OutputDir:    "/out",
+					KeyHash:      "abc",
+					Message:      "oops",
+				})
+				So(err, ShouldBeNil)
+
+				sheet, err = s.Read("sheetID", RunsSheetName)
+				So(err, ShouldBeNil)
+				So(sheet.Rows, ShouldHaveLength, 1)
+
+				row = sheet.OptionalColumns(colFastqStatus, colFastqDir, colDimSumStatus,
+					colOutputDir, colKeyHash, colMessage, colStarted)[0]
+				So(row, ShouldResemble, []string{RunStateRunning, "/fastqs", RunStateFailed, "/out", "abc", "oops", row[6]})
+				So(row[6], ShouldEqual, started.Format(RunTimeFormat))
+			})
+
+			Convey("Then add a row for different samples", func() {
+				err = s.UpdateRunStatus("sheetID", &RunStatus{ExperimentID: "exp1", Samples: "s1:1"})
+				So(err, ShouldBeNil)
+
+				So(fake.values(RunsSheetName), ShouldHaveLength, 3)
+			})
+		})
+
+		Convey("Existing rows and extra columns are preserved", func() {
+			fake.tabs[RunsSheetName] = [][]any{
+				{"notes", colExperimentID, colSamples, colFastqStatus, colFastqDir, colDimSumStatus,
+					colOutputDir, colKeyHash, colStarted, colUpdated, colMessage},
+				{"keep me", "exp1", "s1:1,s2:2", RunStateComplete},
+			}
+
+			status.FastqStatus = RunStateComplete
+			status.Message = ""
+
+			err = s.UpdateRunStatus("sheetID", status)
+			So(err, ShouldBeNil)
+
+			rows := fake.values(RunsSheetName)
+			So(rows, ShouldHaveLength, 2)
+			So(rows[1][0], ShouldEqual, "keep me")
+			So(rows[1][4], ShouldEqual, "/fastqs")
+			So(rows[1][8], ShouldNotBeBlank)
+		})
+
+		Convey("Duplicate rows from concurrent updates are merged in to the first", func() {
+			fake.tabs[RunsSheetName] = [][]any{
+				toAny(runsHeaders),
+				{"exp1", "s1:1,s2:2", RunStateComplete, "/fastqs", "", "", "", "2025-01-01T10:00:00Z",
+					"2025-01-01T10:05:00Z", "old"},
+				{"exp2", "s3:3"},
+				{"exp1", "s1:1,s2:2", "", "", RunStateRunning, "/out", "abc", "2025-01-01T09:00:00Z",
+					"2025-01-01T11:00:00Z", "newer"},
+			}
+
+			err = s.UpdateRunStatus("sheetID", &RunStatus{ExperimentID: "exp1", Samples: "s1:1,s2:2", KeyHash: "def"})
+			So(err, ShouldBeNil)
+
+			rows := fake.values(RunsSheetName)
+			So(rows, ShouldHaveLength, 4)
+			So(rows[1][:9], ShouldResemble, []any{"exp1", "s1:1,s2:2", RunStateComplete, "/fastqs",
+				RunStateRunning, "/out", "def", "2025-01-01T09:00:00Z", rows[1][8]})
+			So(rows[1][9], ShouldEqual, "")
+			So(rows[2][0], ShouldEqual, "exp2")
+			So(rows[3], ShouldResemble, []any{"", "", "", "", "", "", "", "", "", ""})
+
+			sheet, errr := s.Read("sheetID", RunsSheetName)
+			So(errr, ShouldBeNil)
+			So(findRunRows(sheet, status), ShouldResemble, []int{0})
+		})
+
+		Convey("Concurrent updates of a new run only add one row", func() {
+			var wg sync.WaitGroup
+
+			errs := make(chan error, 5)
+
+			for range 5 {
+				wg.Add(1)
+
+				go func() {
+					defer wg.Done()
+
+					errs <- s.UpdateRunStatus("sheetID", status)
+				}()
+			}
+
+			wg.Wait()
+			close(errs)
+
+			for err := range errs {
+				So(err, ShouldBeNil)
+			}
+
+			So(fake.values(RunsSheetName), ShouldHaveLength, 2)
+		})
+
+		Convey("A runs tab lacking our columns is an error", func() {
+			fake.tabs[RunsSheetName] = [][]any{{colExperimentID, colSamples}}
+
+			err = s.UpdateRunStatus("sheetID", status)
+			So(errors.Is(err, ErrColumnNotFound), ShouldBeTrue)
+			So(err.Error(), ShouldContainSubstring, colFastqStatus)
+		})
+
+		Convey("A missing runs tab is an error", func() {
+			delete(fake.tabs, RunsSheetName)
+
+			err = s.UpdateRunStatus("sheetID", status)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,324 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Authors:
+ *	- Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package sheets
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/wtsi-hgi/dimsum-automation/types"
+)
+
+const (
+	sheetTag          = "sheet"
+	tagAliasSeparator = "|"
+	tagOptSeparator   = ","
+	tagOptOptional    = "optional"
+	tagOptFloat       = "float"
+
+	// firstDataRowNumber is the spreadsheet row number of the first row after
+	// the header row.
+	firstDataRowNumber = 2
+)
+
+// MissingColumnsError is returned when a sheet lacks required columns. It
+// satisfies errors.Is(err, ErrColumnNotFound).
+type MissingColumnsError struct {
+	Sheet   string
+	Columns []string
+}
+
+// Error names the sheet and every missing column.
+func (e *MissingColumnsError) Error() string {
+	return fmt.Sprintf("%s: sheet %q is missing: %s", ErrColumnNotFound, e.Sheet, strings.Join(e.Columns, ", "))
+}
+
+// Is returns true for ErrColumnNotFound.
+func (e *MissingColumnsError) Is(target error) bool {
+	return target == ErrColumnNotFound //nolint:errorlint
+}
+
+// field describes a struct field that is set from a sheet column, as declared
+// by a struct tag like `sheet:"header|alias,optional,float"`. The header and
+// any aliases are matched against column headers ignoring case and whitespace.
+// Optional columns can be absent from the sheet, and float string fields are
+// checked to hold a float. Other string fields are text, and are read as they
+// are shown in the sheet.
+type field struct {
+	index    []int
+	headers  []string
+	optional bool
+	float    bool
+	text     bool
+}
+
+// hasHeader returns true if the given header matches our header or one of our
+// aliases, ignoring case and whitespace.
+func (f field) hasHeader(header string) bool {
+	return slices.ContainsFunc(f.headers, func(h string) bool {
+		return normaliseHeader(h) == normaliseHeader(header)
+	})
+}
+
+// schema is the list of fields in a struct that have sheet tags, including
+// those of embedded structs.
+type schema []field
+
+// schemaFor returns the schema for the given struct type.
+func schemaFor(t reflect.Type) schema {
+	var s schema
+
+	for i := range t.NumField() {
+		sf := t.Field(i)
+		tag, ok := sf.Tag.Lookup(sheetTag)
+
+		if !ok {
+			if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+				for _, f := range schemaFor(sf.Type) {
+					f.index = append([]int{i}, f.index...)
+					s = append(s, f)
+				}
+			}
+
+			continue
+		}
+
+		f := parseSheetTag(i, tag)
+		f.text = sf.Type.Kind() == reflect.String && !f.float
+
+		s = append(s, f)
+	}
+
+	return s
+}
+
+func parseSheetTag(index int, tag string) field {
+	parts := strings.Split(tag, tagOptSeparator)
+	f := field{index: []int{index}, headers: strings.Split(parts[0], tagAliasSeparator)}
+
+	for _, opt := range parts[1:] {
+		switch opt {
+		case tagOptOptional:
+			f.optional = true
+		case tagOptFloat:
+			f.float = true
+		}
+	}
+
+	return f
+}
+
+// columnIndexes returns the index of each of our fields' columns in the given
+// sheet, or -1 for absent optional columns. Column headers configured in the
+// given TabLayout are looked for before our own. Returns a
+// *MissingColumnsError if any required columns are absent.
+func (s schema) columnIndexes(sheet *Sheet, sheetName string, t *TabLayout) ([]int, error) {
+	indexes := make([]int, len(s))
+
+	var missing []string
+
+	for i, f := range s {
+		headers := f.headers
+		if h, ok := t.header(f); ok {
+			headers = append([]string{h}, headers...)
+		}
+
+		indexes[i] = sheet.columnIndex(headers...)
+
+		if indexes[i] < 0 && !f.optional {
+			missing = append(missing, headers[0])
+		}
+	}
+
+	if len(missing) > 0 {
+		return nil, &MissingColumnsError{Sheet: sheetName, Columns: missing}
+	}
+
+	return indexes, nil
+}
+
+// textFields returns whether each of our fields is text.
+func (s schema) textFields() []bool {
+	text := make([]bool, len(s))
+
+	for i, f := range s {
+		text[i] = f.text
+	}
+
+	return text
+}
+
+// parsedRow is a row of a sheet parsed in to a T.
+type parsedRow[T any] struct {
+	value   *T
+	cells   map[string]string
+	headers map[string]string
+	number  int
+	sheet   string
+}
+
+// cell returns the raw value of the column with the given canonical header in
+// this row.
+func (r *parsedRow[T]) cell(header string) string {
+	return r.cells[header]
+}
+
+// cellError returns a CellError for the cell in the column with the given
+// canonical header in this row.
+func (r *parsedRow[T]) cellError(header, expected string, err error) *CellError {
+	return &CellError{
+		Sheet:    r.sheet,
+		Row:      r.number,
+		Column:   r.headers[header],
+		Value:    r.cells[header],
+		Expected: expected,
+		Err:      err,
+	}
+}
+
+// parseSheet returns a new T for each row in the given sheet, with T's
+// sheet-tagged fields set from the corresponding columns, as named by the
+// given TabLayout. If the sheet lacks required columns, returns a
+// *MissingColumnsError. Otherwise, every row that isn't blank in all those
+// columns is parsed, and CellErrors are returned for all values that couldn't
+// be converted to their field's type.
+func parseSheet[T any](sheet *Sheet, sheetName string, t *TabLayout) ([]*parsedRow[T], CellErrors, error) {
+	s := schemaFor(reflect.TypeFor[T]())
+
+	indexes, err := s.columnIndexes(sheet, sheetName, t)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	headers := make(map[string]string, len(s))
+
+	for i, f := range s {
+		headers[f.headers[0]] = f.headers[0]
+
+		if indexes[i] >= 0 {
+			headers[f.headers[0]] = sheet.ColumnHeaders[indexes[i]]
+		}
+	}
+
+	var cellErrs CellErrors
+
+	rows := make([]*parsedRow[T], 0, len(sheet.Rows))
+
+	for i, vals := range sheet.rowsForColumnIndexesFormatted(indexes, s.textFields()) {
+		if isBlank(vals) {
+			continue
+		}
+
+		row := &parsedRow[T]{
+			value:   new(T),
+			cells:   make(map[string]string, len(s)),
+			headers: headers,
+			number:  i + firstDataRowNumber,
+			sheet:   sheetName,
+		}
+		v := reflect.ValueOf(row.value).Elem()
+
+		for j, f := range s {
+			row.cells[f.headers[0]] = vals[j]
+
+			c := converter{}
+			expected := c.set(v.FieldByIndex(f.index), vals[j], f.float)
+
+			if c.Err != nil {
+				cellErrs = append(cellErrs, row.cellError(f.headers[0], expected, c.Err))
+			}
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, cellErrs, nil
+}
+
+// isBlank returns true if all the given values are blank.
+func isBlank(vals []string) bool {
+	for _, val := range vals {
+		if val != "" {
+			return false
+		}
+	}
+
+	return true
+}
+
+// set converts the given string to the type of the given field and sets it.
+// float string fields are checked to be floats. Returns a description of the
+// values the field accepts.
+func (c *converter) set(v reflect.Value, s string, float bool) string {
+	switch p := v.Addr().Interface().(type) {
+	case *string:
+		if !float {
+			*p = s
+
+			return "text"
+		}
+
+		*p = c.ToFloatString(s)
+
+		return "a number"
+	case *int:
+		*p = c.ToInt(s)
+
+		return "a whole number"
+	case *bool:
+		*p = c.ToBool(s)
+
+		return "TRUE or FALSE"
+	case *types.SequenceType:
+		*p = c.ToSequenceType(s)
+
+		return describeChoices(types.SequenceTypeAuto, types.SequenceTypeC, types.SequenceTypeNC)
+	case *types.MutagenesisType:
+		*p = c.ToMutagenesisType(s)
+
+		return describeChoices(types.MutagenesisTypeRandom, types.MutagenesisTypeCodon)
+	case *types.Selection:
+		*p = c.ToSelection(s)
+
+		return describeChoices(types.SelectionInput, types.SelectionOutput)
+	default:
+		panic(fmt.Sprintf("unsupported sheet field type %s", v.Type()))
+	}
+}
+
+// describeChoices returns a description of the given allowed values.
+func describeChoices[T ~string](choices ...T) string {
+	strs := make([]string, len(choices))
+
+	for i, choice := range choices {
+		strs[i] = string(choice)
+	}
+
+	return "one of " + strings.Join(strs, ", ")
+}
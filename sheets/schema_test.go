@@ -0,0 +1,104 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Authors:
+ *	- Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package sheets
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/wtsi-hgi/dimsum-automation/types"
+)
+
+type testEmbedded struct {
+	Count int  `sheet:"count"`
+	Flag  bool `sheet:"flag,optional"`
+}
+
+type testRow struct {
+	Name      string          `sheet:"name|alias"`
+	Rate      string          `sheet:"rate,float"`
+	Selection types.Selection `sheet:"selection"`
+	Untagged  string
+	testEmbedded
+}
+
+func TestSchema(t *testing.T) {
+	Convey("Given a sheet with lenient headers", t, func() {
+		sheet := NewSheet([][]string{
+			{" ALIAS", "Count ", "r ate", "selection", "other"},
+			{"a", "1", "0.5", "input", "x"},
+			{"b", "", "", "output", "y"},
+		})
+
+		Convey("You can parse its rows in to structs using sheet tags", func() {
+			rows, cellErrs, err := parseSheet[testRow](sheet, "test", nil)
+			So(err, ShouldBeNil)
+			So(cellErrs, ShouldBeEmpty)
+			So(rows, ShouldHaveLength, 2)
+			So(*rows[0].value, ShouldResemble, testRow{
+				Name:         "a",
+				Rate:         "0.5",
+				Selection:    types.SelectionInput,
+				testEmbedded: testEmbedded{Count: 1},
+			})
+			So(rows[1].value.Name, ShouldEqual, "b")
+			So(rows[1].cell("count"), ShouldBeBlank)
+			So(rows[0].cell("count"), ShouldEqual, "1")
+
+			sheet.Rows[1][1] = "many"
+			sheet.Rows[1][2] = "fast"
+			rows, cellErrs, err = parseSheet[testRow](sheet, "test", nil)
+			So(err, ShouldBeNil)
+			So(rows, ShouldHaveLength, 2)
+			So(cellErrs, ShouldHaveLength, 2)
+			So(*cellErrs[0], ShouldResemble, CellError{
+				Sheet:    "test",
+				Row:      3,
+				Column:   "r ate",
+				Value:    "fast",
+				Expected: "a number",
+				Err:      cellErrs[0].Err,
+			})
+			So(cellErrs[1].Column, ShouldEqual, "Count ")
+			So(cellErrs[1].Error(), ShouldEqual, `sheet "test" row 3 column "Count ": "many" is not a whole number`)
+		})
+
+		Convey("Missing required columns are all named in the error", func() {
+			sheet = NewSheet([][]string{{"flag"}, {"true"}})
+
+			_, _, err := parseSheet[testRow](sheet, "test", nil)
+			So(errors.Is(err, ErrColumnNotFound), ShouldBeTrue)
+
+			var mcErr *MissingColumnsError
+			So(errors.As(err, &mcErr), ShouldBeTrue)
+			So(mcErr.Sheet, ShouldEqual, "test")
+			So(mcErr.Columns, ShouldResemble, []string{"name", "rate", "selection", "count"})
+			So(err.Error(), ShouldEqual, `column not found in sheet: sheet "test" is missing: name, rate, selection, count`)
+		})
+	})
+}
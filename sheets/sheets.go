@@ -29,6 +29,10 @@ package sheets
 import (
 	"context"
 	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 
 	"google.golang.org/api/option"
 	googleSheets "google.golang.org/api/sheets/v4"
@@ -38,19 +42,57 @@ type Error string
 
 func (e Error) Error() string { return string(e) }
 
-const ErrColumnNotFound = Error("column not found in sheet")
+const (
+	ErrColumnNotFound     = Error("column not found in sheet")
+	ErrUnexpectedResponse = Error("unexpected response from Google sheets")
+)
+
+const (
+	scopeReadOnly  = "https://www.googleapis.com/auth/spreadsheets.readonly"
+	scopeReadWrite = "https://www.googleapis.com/auth/spreadsheets"
+
+	// gridDataFields are the parts of a spreadsheet we get: the title of each
+	// tab, and each cell's unformatted and formatted values and number format
+	// type.
+	gridDataFields = "sheets(properties(title),data(rowData(values(" +
+		"effectiveValue,formattedValue,effectiveFormat(numberFormat(type))))))"
+)
+
+// dateTimeNumberFormatTypes are the number format types of cells whose
+// formatted values we use as their unformatted values, so that dates and times
+// are as they are shown.
+var dateTimeNumberFormatTypes = []string{"DATE", "TIME", "DATE_TIME"}
 
 // Sheets allows the retrival of sheets from Google docs.
 type Sheets struct {
-	srv *googleSheets.Service
+	srv    *googleSheets.Service
+	layout *Layout
+	runsMu sync.Mutex
 }
 
 // New returns a Sheets that you can Get() sheets from Google docs with.
 func New(sc *ServiceCredentials) (*Sheets, error) {
+	return newWithScope(sc, scopeReadOnly)
+}
+
+// NewWritable is like New, but the returned Sheets also has permission to
+// UpdateRunStatus(). The service account must have been given edit access to
+// the spreadsheet.
+func NewWritable(sc *ServiceCredentials) (*Sheets, error) {
+	return newWithScope(sc, scopeReadWrite)
+}
+
+func newWithScope(sc *ServiceCredentials, scope string) (*Sheets, error) {
 	ctx := context.Background()
-	client := sc.toJWTConfig().Client(ctx)
+	client := sc.toJWTConfig(scope).Client(ctx)
+
+	return newSheets(ctx, option.WithHTTPClient(client))
+}
 
-	srv, err := googleSheets.NewService(ctx, option.WithHTTPClient(client))
+// newSheets returns a Sheets using a service configured with the given options,
+// which lets tests point at a fake server.
+func newSheets(ctx context.Context, opts ...option.ClientOption) (*Sheets, error) {
+	srv, err := googleSheets.NewService(ctx, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -63,88 +105,275 @@ type Sheet struct {
 	ColumnHeaders []string
 	Rows          [][]string
 
+	// FormattedRows, if not nil, are the Rows as they are shown in the
+	// spreadsheet, with its number formats applied. Text columns are read
+	// from these, so that eg. leading zeros are kept.
+	FormattedRows [][]string
+
 	headerLookup map[string]int
 }
 
 // Read retrieves the contents of a given document and sheet within that
 // document. The id of a Google sheet is the long string of characters in the
 // URL when viewing that document.
+//
+// Rows have the unformatted cell values, so numbers are not subject to the
+// spreadsheet's locale or number formats (see cellToString()), but dates and
+// times are as they are shown. FormattedRows have the values as they are shown.
 func (s *Sheets) Read(sheetID, sheetName string) (*Sheet, error) {
-	valRange, err := s.srv.Spreadsheets.Values.Get(sheetID, sheetName).Do()
+	sheets, err := s.ReadMany(sheetID, sheetName)
+	if err != nil {
+		return nil, err
+	}
+
+	return sheets[0], nil
+}
+
+// ReadMany is like Read(), but retrieves multiple sheets of the given document
+// in a single request, returning them in the same order as the given names.
+func (s *Sheets) ReadMany(sheetID string, sheetNames ...string) ([]*Sheet, error) {
+	ranges := make([]string, len(sheetNames))
+
+	for i, name := range sheetNames {
+		ranges[i] = quoteSheetName(name)
+	}
+
+	resp, err := s.srv.Spreadsheets.Get(sheetID).Ranges(ranges...).
+		IncludeGridData(true).Fields(gridDataFields).Do()
 	if err != nil {
 		return nil, err
 	}
 
-	if len(valRange.Values) == 0 {
+	byTitle := make(map[string]*googleSheets.Sheet, len(resp.Sheets))
+
+	for _, sheet := range resp.Sheets {
+		if sheet.Properties != nil {
+			byTitle[sheet.Properties.Title] = sheet
+		}
+	}
+
+	sheets := make([]*Sheet, len(sheetNames))
+
+	for i, name := range sheetNames {
+		sheet, ok := byTitle[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: no sheet %q", ErrUnexpectedResponse, name)
+		}
+
+		sheets[i] = gridDataToSheet(sheet.Data)
+	}
+
+	return sheets, nil
+}
+
+// quoteSheetName returns the given sheet name quoted for use as an A1 range,
+// so that names with spaces or punctuation work.
+func quoteSheetName(name string) string {
+	return "'" + strings.ReplaceAll(name, "'", "''") + "'"
+}
+
+// gridDataToSheet returns a Sheet with the values of the given grid data, like
+// NewSheet(), but also with FormattedRows. Trailing blank cells and rows are
+// dropped.
+func gridDataToSheet(data []*googleSheets.GridData) *Sheet {
+	var values, formatted [][]string
+
+	for _, gd := range data {
+		for _, rd := range gd.RowData {
+			row, fRow := rowDataToStringSlices(rd)
+			values = append(values, row)
+			formatted = append(formatted, fRow)
+		}
+	}
+
+	for len(values) > 0 && len(values[len(values)-1]) == 0 {
+		values = values[:len(values)-1]
+		formatted = formatted[:len(formatted)-1]
+	}
+
+	sheet := NewSheet(values)
+	if sheet != nil {
+		sheet.FormattedRows = formatted[1:]
+	}
+
+	return sheet
+}
+
+// rowDataToStringSlices returns the unformatted and formatted values of the
+// cells in the given row, without trailing blank cells.
+func rowDataToStringSlices(rd *googleSheets.RowData) ([]string, []string) {
+	if rd == nil {
 		return nil, nil
 	}
 
-	var header []string
+	values := make([]string, len(rd.Values))
+	formatted := make([]string, len(rd.Values))
+	last := -1
 
-	headerLookup := make(map[string]int, len(valRange.Values[0]))
-	rows := make([][]string, len(valRange.Values)-1)
+	for i, cd := range rd.Values {
+		if cd == nil {
+			continue
+		}
 
-	for i, row := range valRange.Values {
-		if i == 0 {
-			header = rowToStringSlice(row)
-			for i, head := range header {
-				headerLookup[head] = i
-			}
-		} else {
-			rows[i-1] = rowToStringSlice(row)
+		values[i] = cellDataToString(cd)
+		formatted[i] = cd.FormattedValue
+
+		if values[i] != "" || formatted[i] != "" {
+			last = i
 		}
 	}
 
+	return values[:last+1], formatted[:last+1]
+}
+
+// cellDataToString returns the unformatted value of the given cell as a string
+// (see cellToString()), except for dates, times and errors, which are returned
+// as they are shown.
+func cellDataToString(cd *googleSheets.CellData) string {
+	ev := cd.EffectiveValue
+
+	switch {
+	case ev == nil:
+		return cd.FormattedValue
+	case ev.ErrorValue != nil:
+		return cd.FormattedValue
+	case cd.EffectiveFormat != nil && cd.EffectiveFormat.NumberFormat != nil &&
+		slices.Contains(dateTimeNumberFormatTypes, cd.EffectiveFormat.NumberFormat.Type):
+		return cd.FormattedValue
+	case ev.NumberValue != nil:
+		return cellToString(*ev.NumberValue)
+	case ev.BoolValue != nil:
+		return cellToString(*ev.BoolValue)
+	case ev.StringValue != nil:
+		return *ev.StringValue
+	default:
+		return cd.FormattedValue
+	}
+}
+
+// NewSheet returns a Sheet with the given values, where the first row is the
+// column headers. Returns nil if there are no values.
+func NewSheet(values [][]string) *Sheet {
+	if len(values) == 0 {
+		return nil
+	}
+
+	header := values[0]
+	headerLookup := make(map[string]int, len(header))
+
+	for i, head := range header {
+		headerLookup[normaliseHeader(head)] = i
+	}
+
 	return &Sheet{
 		ColumnHeaders: header,
-		Rows:          rows,
+		Rows:          values[1:],
 		headerLookup:  headerLookup,
-	}, nil
+	}
 }
 
-func rowToStringSlice(in []any) []string {
-	out := make([]string, len(in))
+// cellToString converts an unformatted cell value to a string the way our
+// converter expects: numbers are written out in full without exponents or
+// grouping (so a cell density shown as "1.50E+06" becomes "1500000", and a
+// whole number like 3 is "3"), and booleans are TRUE or FALSE.
+func cellToString(cell any) string {
+	switch v := cell.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, floatBits)
+	case bool:
+		return strings.ToUpper(strconv.FormatBool(v))
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// normaliseHeader returns the given column header in lower case with all
+// whitespace removed, so that headers can be matched leniently.
+func normaliseHeader(header string) string {
+	return strings.ToLower(strings.Join(strings.Fields(header), ""))
+}
 
-	for i, cols := range in {
-		out[i] = fmt.Sprint(cols)
+// columnIndex returns the index of the first column whose header matches one of
+// the given headers, ignoring case and whitespace, or -1 if none match.
+func (s *Sheet) columnIndex(headers ...string) int {
+	for _, header := range headers {
+		if i, ok := s.headerLookup[normaliseHeader(header)]; ok {
+			return i
+		}
 	}
 
-	return out
+	return -1
 }
 
 // Columns returns a slice for each row in the sheet (like Rows property), but
 // each slice only has values from the columns with the given column headers.
+// Headers are matched ignoring case and whitespace.
 //
 // Will return an error if given cols are not amongst ColumnHeaders.
 func (s *Sheet) Columns(cols ...string) ([][]string, error) {
 	colIndexes := make([]int, len(cols))
 
 	for i, col := range cols {
-		colIndex, ok := s.headerLookup[col]
-		if !ok {
+		colIndex := s.columnIndex(col)
+		if colIndex < 0 {
 			return nil, ErrColumnNotFound
 		}
 
 		colIndexes[i] = colIndex
 	}
 
+	return s.rowsForColumnIndexes(colIndexes), nil
+}
+
+// OptionalColumns is like Columns(), but columns that are not amongst
+// ColumnHeaders are not an error; their values will be blank in every row.
+func (s *Sheet) OptionalColumns(cols ...string) [][]string {
+	colIndexes := make([]int, len(cols))
+
+	for i, col := range cols {
+		colIndexes[i] = s.columnIndex(col)
+	}
+
+	return s.rowsForColumnIndexes(colIndexes)
+}
+
+// rowsForColumnIndexes returns a slice for each row in the sheet containing the
+// values in the given column indexes. Negative or out of range indexes result
+// in blank values.
+func (s *Sheet) rowsForColumnIndexes(colIndexes []int) [][]string {
+	return s.rowsForColumnIndexesFormatted(colIndexes, nil)
+}
+
+// rowsForColumnIndexesFormatted is like rowsForColumnIndexes(), but takes the
+// values of columns whose corresponding formatted element is true from our
+// FormattedRows, if we have them.
+func (s *Sheet) rowsForColumnIndexesFormatted(colIndexes []int, formatted []bool) [][]string {
 	rows := make([][]string, len(s.Rows))
 
 	for i, wholeRow := range s.Rows {
 		row := make([]string, len(colIndexes))
 
 		for j, colIndex := range colIndexes {
-			if colIndex >= len(wholeRow) {
+			source := wholeRow
+			if j < len(formatted) && formatted[j] && i < len(s.FormattedRows) {
+				source = s.FormattedRows[i]
+			}
+
+			if colIndex < 0 || colIndex >= len(source) {
 				row[j] = ""
 
 				continue
 			}
 
-			row[j] = wholeRow[colIndex]
+			row[j] = source[colIndex]
 		}
 
 		rows[i] = row
 	}
 
-	return rows, nil
+	return rows
 }
@@ -27,7 +27,10 @@
 package sheets
 
 import (
+	"encoding/json"
 	"fmt"
+	"path/filepath"
+	"strconv"
 	"testing"
 
 	. "github.com/smartystreets/goconvey/convey"
@@ -35,6 +38,144 @@ import (
 	"github.com/wtsi-hgi/dimsum-automation/types"
 )
 
+func TestSheet(t *testing.T) {
+	Convey("Given a Sheet, you can get required and optional columns", t, func() {
+		sheet := &Sheet{
+			ColumnHeaders: []string{"a", "b", "c"},
+			Rows: [][]string{
+				{"a1", "b1", "c1"},
+				{"a2", "b2"},
+			},
+			headerLookup: map[string]int{"a": 0, "b": 1, "c": 2},
+		}
+
+		rows, err := sheet.Columns("c", "a")
+		So(err, ShouldBeNil)
+		So(rows, ShouldResemble, [][]string{{"c1", "a1"}, {"", "a2"}})
+
+		_, err = sheet.Columns("a", "d")
+		So(err, ShouldEqual, ErrColumnNotFound)
+
+		rows = sheet.OptionalColumns("a", "d", "c")
+		So(rows, ShouldResemble, [][]string{{"a1", "", "c1"}, {"a2", "", ""}})
+	})
+}
+
+func TestSheetsFake(t *testing.T) {
+	Convey("Unformatted cell values are converted to strings in full", t, func() {
+		for cell, expected := range map[any]string{
+			nil:              "",
+			"text":           "text",
+			float64(3):       "3",
+			1.5e6:            "1500000",
+			0.000001:         "0.000001",
+			true:             "TRUE",
+			false:            "FALSE",
+			json.Number("7"): "7",
+		} {
+			So(cellToString(cell), ShouldEqual, expected)
+		}
+	})
+
+	Convey("Given a fake Google sheet with unformatted values", t, func() {
+		tabs := make(map[string][][]any, len(sheetNames))
+
+		for _, name := range sheetNames {
+			records, err := readDelimited(filepath.Join(testMetadataDir, name+csvExt), false)
+			So(err, ShouldBeNil)
+
+			tabs[name] = unformattedValues(records)
+		}
+
+		tabs["my 'tab'"] = [][]any{{"a"}, {1.0}}
+
+		fake := newFakeSheetsServer("sheetID", tabs)
+		defer fake.Close()
+
+		s, err := fake.sheets()
+		So(err, ShouldBeNil)
+
+		Convey("You can read multiple sheets in one request", func() {
+			sheets, err := s.ReadMany("sheetID", "libraries", "my 'tab'")
+			So(err, ShouldBeNil)
+			So(sheets, ShouldHaveLength, 2)
+			So(sheets[0].Rows[1][2], ShouldEqual, "4")
+			So(sheets[1].Rows, ShouldResemble, [][]string{{"1"}})
+			So(fake.requestCount(), ShouldEqual, 1)
+
+			_, err = s.ReadMany("sheetID", "missing")
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Text columns are read as shown, and others unformatted", func() {
+			tabs["samples"][1][1] = fakeCell{value: 7.0, formatted: "007"}
+			tabs["samples"][1][5] = fakeCell{value: 1.5e6, formatted: "1.50E+06"}
+			tabs["samples"][2][3] = fakeCell{value: 1.0, formatted: "1.0"}
+
+			libs, err := s.DimSumMetaData("sheetID")
+			So(err, ShouldBeNil)
+
+			samples := libs[0].Experiments[0].Samples
+			So(samples[0].SampleName, ShouldEqual, "007")
+			So(samples[0].CellDensity, ShouldEqual, "1500000")
+			So(samples[1].ExperimentReplicate, ShouldEqual, 1)
+
+			sheet, err := s.Read("sheetID", "samples")
+			So(err, ShouldBeNil)
+			So(sheet.Rows[0][1], ShouldEqual, "7")
+			So(sheet.FormattedRows[0][1], ShouldEqual, "007")
+		})
+
+		Convey("Dates and times are read as shown", func() {
+			tabs["my 'tab'"] = [][]any{{"a", "b"}, {fakeCell{value: 45000.0, formatted: "2023-03-15", dateTimeFormat: true}}}
+
+			sheet, err := s.Read("sheetID", "my 'tab'")
+			So(err, ShouldBeNil)
+			So(sheet.Rows, ShouldResemble, [][]string{{"2023-03-15"}})
+		})
+
+		Convey("DimSumMetaData gives the same results as from files, with one request", func() {
+			f, err := NewFiles(testMetadataDir)
+			So(err, ShouldBeNil)
+
+			expected, err := f.DimSumMetaData("")
+			So(err, ShouldBeNil)
+
+			libs, err := s.DimSumMetaData("sheetID")
+			So(err, ShouldBeNil)
+			So(libs, ShouldResemble, expected)
+			So(fake.requestCount(), ShouldEqual, 1)
+		})
+	})
+}
+
+// unformattedValues converts the given records to cells like the Google sheets
+// API returns with UNFORMATTED_VALUE rendering: numbers as float64 and
+// booleans as bool.
+func unformattedValues(records [][]string) [][]any {
+	rows := make([][]any, len(records))
+
+	for i, record := range records {
+		rows[i] = make([]any, len(record))
+
+		for j, val := range record {
+			rows[i][j] = val
+
+			if i == 0 {
+				continue
+			}
+
+			if f, err := strconv.ParseFloat(val, floatBits); err == nil {
+				rows[i][j] = f
+			} else if b, err := strconv.ParseBool(val); err == nil {
+				rows[i][j] = b
+			}
+		}
+	}
+
+	return rows
+}
+
 func TestSheets(t *testing.T) {
 	c, err := config.FromEnv("..")
 	if err != nil {
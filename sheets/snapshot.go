@@ -0,0 +1,253 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Authors:
+ *	- Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package sheets
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/wtsi-hgi/dimsum-automation/types"
+)
+
+const (
+	RowKindLibrary    = "library"
+	RowKindExperiment = "experiment"
+	RowKindSample     = "sample"
+
+	RowAdded   = "added"
+	RowRemoved = "removed"
+	RowChanged = "changed"
+
+	snapshotDirPerm  = 0700
+	snapshotFilePerm = 0600
+)
+
+// Snapshot records a fingerprint of every library, experiment and sample row
+// read from a spreadsheet, so that a later Snapshot can be compared to it to
+// find out what changed.
+type Snapshot struct {
+	Taken time.Time `json:"taken"`
+
+	// Fingerprints are keyed on "kind id", where kind is one of the RowKind*
+	// constants, and id is a library or experiment ID, or for samples,
+	// "experimentID/sampleName:runID".
+	Fingerprints map[string]string `json:"fingerprints"`
+
+	// Experiments holds each experiment's effective DiMSum parameters (ie.
+	// including those inherited from its library), without its samples.
+	Experiments map[string]*types.Experiment `json:"experiments"`
+}
+
+// NewSnapshot returns a Snapshot of the given libraries, as returned by
+// DimSumMetaData().
+func NewSnapshot(libs types.Libraries) *Snapshot {
+	s := &Snapshot{
+		Taken:        time.Now(),
+		Fingerprints: make(map[string]string),
+		Experiments:  make(map[string]*types.Experiment),
+	}
+
+	for _, lib := range libs {
+		s.add(RowKindLibrary, lib.LibraryID, lib)
+
+		for _, exp := range lib.Experiments {
+			s.add(RowKindExperiment, exp.ExperimentID, exp)
+			s.Experiments[exp.ExperimentID] = exp.Clone(nil)
+
+			s.addSamples(exp)
+		}
+	}
+
+	return s
+}
+
+func (s *Snapshot) add(kind, id string, v any) {
+	s.Fingerprints[kind+" "+id] = fingerprint(v)
+}
+
+// addSamples adds the given experiment's samples, distinguishing repeated
+// sample runs (eg. technical replicates) by their order.
+func (s *Snapshot) addSamples(exp *types.Experiment) {
+	seen := make(map[string]int, len(exp.Samples))
+
+	for _, sample := range exp.Samples {
+		id := fmt.Sprintf("%s/%s:%s", exp.ExperimentID, sample.SampleName, sample.RunID)
+
+		seen[id]++
+		if seen[id] > 1 {
+			id = fmt.Sprintf("%s#%d", id, seen[id])
+		}
+
+		s.add(RowKindSample, id, sample)
+	}
+}
+
+// fingerprint returns a hash of the values of the sheet-tagged fields of the
+// given pointer to a struct.
+func fingerprint(v any) string {
+	val := reflect.ValueOf(v).Elem()
+	hasher := sha256.New()
+
+	for _, f := range schemaFor(val.Type()) {
+		fmt.Fprintf(hasher, "%s=%v\n", f.headers[0], val.FieldByIndex(f.index).Interface())
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
+// LoadSnapshot reads a Snapshot previously saved to the given path with
+// Save(). If the file doesn't exist, the error will satisfy
+// errors.Is(err, fs.ErrNotExist).
+func LoadSnapshot(path string) (*Snapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Snapshot{}
+
+	return s, json.Unmarshal(data, s)
+}
+
+// Save writes this Snapshot to the given path, creating its parent directory
+// if necessary.
+func (s *Snapshot) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), snapshotDirPerm); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, snapshotFilePerm)
+}
+
+// RowChange describes a row that differs between two Snapshots.
+type RowChange struct {
+	// Kind is one of the RowKind* constants.
+	Kind string
+
+	// ID is the library or experiment ID, or for samples,
+	// "experimentID/sampleName:runID".
+	ID string
+
+	// Change is one of RowAdded, RowRemoved or RowChanged.
+	Change string
+
+	// Columns are the experiments sheet columns of the effective DiMSum
+	// parameters that changed, for changed experiments.
+	Columns []string
+}
+
+// String describes the change, eg. "changed experiment exp1 (wildtypeSequence)".
+func (r *RowChange) String() string {
+	desc := fmt.Sprintf("%s %s %s", r.Change, r.Kind, r.ID)
+
+	if len(r.Columns) > 0 {
+		desc += " (" + strings.Join(r.Columns, ", ") + ")"
+	}
+
+	return desc
+}
+
+// Diff returns the rows that were added, removed or changed since the given
+// earlier Snapshot, sorted by kind (libraries, then experiments, then
+// samples) and ID.
+func (s *Snapshot) Diff(prev *Snapshot) []*RowChange {
+	var changes []*RowChange
+
+	for key, fp := range s.Fingerprints {
+		prevFP, ok := prev.Fingerprints[key]
+
+		switch {
+		case !ok:
+			changes = append(changes, newRowChange(key, RowAdded))
+		case prevFP != fp:
+			change := newRowChange(key, RowChanged)
+
+			if change.Kind == RowKindExperiment {
+				change.Columns = changedColumns(prev.Experiments[change.ID], s.Experiments[change.ID])
+			}
+
+			changes = append(changes, change)
+		}
+	}
+
+	for key := range prev.Fingerprints {
+		if _, ok := s.Fingerprints[key]; !ok {
+			changes = append(changes, newRowChange(key, RowRemoved))
+		}
+	}
+
+	kindOrder := map[string]int{RowKindLibrary: 0, RowKindExperiment: 1, RowKindSample: 2} //nolint:mnd
+
+	slices.SortFunc(changes, func(a, b *RowChange) int {
+		if c := kindOrder[a.Kind] - kindOrder[b.Kind]; c != 0 {
+			return c
+		}
+
+		return strings.Compare(a.ID, b.ID)
+	})
+
+	return changes
+}
+
+func newRowChange(key, change string) *RowChange {
+	kind, id, _ := strings.Cut(key, " ")
+
+	return &RowChange{Kind: kind, ID: id, Change: change}
+}
+
+// changedColumns returns the headers of the sheet-tagged fields that differ
+// between the given experiments.
+func changedColumns(old, current *types.Experiment) []string {
+	if old == nil || current == nil {
+		return nil
+	}
+
+	oldVal, curVal := reflect.ValueOf(old).Elem(), reflect.ValueOf(current).Elem()
+
+	var cols []string
+
+	for _, f := range schemaFor(oldVal.Type()) {
+		if !reflect.DeepEqual(oldVal.FieldByIndex(f.index).Interface(), curVal.FieldByIndex(f.index).Interface()) {
+			cols = append(cols, f.headers[0])
+		}
+	}
+
+	return cols
+}
@@ -0,0 +1,96 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Authors:
+ *	- Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package sheets
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSnapshot(t *testing.T) {
+	Convey("Given a Snapshot of some metadata", t, func() {
+		f, err := NewFiles(testMetadataDir)
+		So(err, ShouldBeNil)
+
+		libs, err := f.DimSumMetaData("")
+		So(err, ShouldBeNil)
+
+		snap := NewSnapshot(libs)
+		So(snap.Fingerprints, ShouldHaveLength, 2+3+4)
+		So(snap.Fingerprints, ShouldContainKey, "sample exp1/DMS_sample2:12345")
+		So(snap.Experiments["exp1"].WildtypeSequence, ShouldEqual, "AAAA")
+		So(snap.Experiments["exp1"].Samples, ShouldBeNil)
+
+		Convey("It has no changes compared to a Snapshot of the same metadata", func() {
+			So(NewSnapshot(libs).Diff(snap), ShouldBeEmpty)
+		})
+
+		Convey("You can save and load it", func() {
+			path := filepath.Join(t.TempDir(), "sub", "snapshot.json")
+
+			_, err = LoadSnapshot(path)
+			So(errors.Is(err, fs.ErrNotExist), ShouldBeTrue)
+
+			So(snap.Save(path), ShouldBeNil)
+
+			loaded, err := LoadSnapshot(path)
+			So(err, ShouldBeNil)
+			So(loaded.Fingerprints, ShouldResemble, snap.Fingerprints)
+			So(loaded.Experiments["exp2"], ShouldResemble, snap.Experiments["exp2"])
+			So(NewSnapshot(libs).Diff(loaded), ShouldBeEmpty)
+		})
+
+		Convey("Changes to rows are reported, including inherited parameters", func() {
+			libs[0].WildtypeSequence = "GGGG"
+			libs[0].Experiments[0].WildtypeSequence = "GGGG"
+			libs[0].Experiments[0].Cutadapt5First = "TTTT"
+			libs[0].Experiments[1].Samples[0].Selection = "output"
+			libs[1].Experiments[0].Samples = append(libs[1].Experiments[0].Samples,
+				libs[1].Experiments[0].Samples[0])
+			libs[0].Experiments[0].Samples = libs[0].Experiments[0].Samples[1:]
+
+			changes := NewSnapshot(libs).Diff(snap)
+
+			descs := make([]string, len(changes))
+			for i, c := range changes {
+				descs[i] = c.String()
+			}
+
+			So(descs, ShouldResemble, []string{
+				"changed library lib1",
+				"changed experiment exp1 (cutadapt5First, wildtypeSequence)",
+				"removed sample exp1/DMS_sample1:",
+				"changed sample exp2/DMS_sample3:",
+				"added sample exp3/Collab_sample4:#2",
+			})
+		})
+	})
+}
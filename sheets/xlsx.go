@@ -0,0 +1,283 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Authors:
+ *	- Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package sheets
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+)
+
+const (
+	ErrInvalidXLSX = Error("invalid XLSX file")
+
+	xlsxWorkbookPath      = "xl/workbook.xml"
+	xlsxWorkbookRelsPath  = "xl/_rels/workbook.xml.rels"
+	xlsxSharedStringsPath = "xl/sharedStrings.xml"
+	xlsxDir               = "xl"
+
+	xlsxTypeSharedString = "s"
+	xlsxTypeInlineString = "inlineStr"
+	xlsxTypeBool         = "b"
+	xlsxTypeNumber       = "n"
+	xlsxTrue             = "1"
+
+	lettersInAlphabet = 26
+	floatBits         = 64
+)
+
+type xlsxWorkbook struct {
+	Sheets []struct {
+		Name  string `xml:"name,attr"`
+		RelID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRelationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+// xlsxText is an element that contains text either directly in a <t>, or
+// split over the <t>s of several rich text runs.
+type xlsxText struct {
+	T    string `xml:"t"`
+	Runs []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (x xlsxText) String() string {
+	if len(x.Runs) == 0 {
+		return x.T
+	}
+
+	var sb strings.Builder
+
+	for _, run := range x.Runs {
+		sb.WriteString(run.T)
+	}
+
+	return sb.String()
+}
+
+type xlsxSharedStrings struct {
+	Items []xlsxText `xml:"si"`
+}
+
+type xlsxWorksheet struct {
+	Rows []struct {
+		Number int `xml:"r,attr"`
+		Cells  []struct {
+			Ref    string   `xml:"r,attr"`
+			Type   string   `xml:"t,attr"`
+			Value  string   `xml:"v"`
+			Inline xlsxText `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// readXLSXSheet returns the cell values of the sheet with the given name
+// (matched case-insensitively) in the XLSX workbook at the given path. Values
+// are returned as strings the way Google sheets would format them, though
+// numbers are in their most precise form and dates are day numbers.
+func readXLSXSheet(workbookPath, sheetName string) ([][]string, error) {
+	zr, err := zip.OpenReader(workbookPath)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	sheetPath, err := xlsxSheetPath(&zr.Reader, sheetName)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		shared xlsxSharedStrings
+		ws     xlsxWorksheet
+	)
+
+	if _, err = decodeZipXML(&zr.Reader, xlsxSharedStringsPath, &shared); err != nil {
+		return nil, err
+	}
+
+	if err = decodeRequiredZipXML(&zr.Reader, sheetPath, &ws); err != nil {
+		return nil, err
+	}
+
+	return ws.values(shared)
+}
+
+// xlsxSheetPath returns the path within the zip of the worksheet with the given
+// name.
+func xlsxSheetPath(zr *zip.Reader, sheetName string) (string, error) {
+	var (
+		wb   xlsxWorkbook
+		rels xlsxRelationships
+	)
+
+	if err := decodeRequiredZipXML(zr, xlsxWorkbookPath, &wb); err != nil {
+		return "", err
+	}
+
+	if err := decodeRequiredZipXML(zr, xlsxWorkbookRelsPath, &rels); err != nil {
+		return "", err
+	}
+
+	for _, sheet := range wb.Sheets {
+		if !strings.EqualFold(sheet.Name, sheetName) {
+			continue
+		}
+
+		for _, rel := range rels.Relationships {
+			if rel.ID != sheet.RelID {
+				continue
+			}
+
+			if strings.HasPrefix(rel.Target, "/") {
+				return strings.TrimPrefix(rel.Target, "/"), nil
+			}
+
+			return path.Join(xlsxDir, rel.Target), nil
+		}
+	}
+
+	return "", fmt.Errorf("%w: %s", ErrSheetNotFound, sheetName)
+}
+
+// decodeRequiredZipXML is like decodeZipXML(), but returns an error if the file
+// isn't in the zip.
+func decodeRequiredZipXML(zr *zip.Reader, name string, v any) error {
+	found, err := decodeZipXML(zr, name, v)
+	if err == nil && !found {
+		err = fmt.Errorf("%w: %s missing", ErrInvalidXLSX, name)
+	}
+
+	return err
+}
+
+// decodeZipXML decodes the XML file at the given path within the zip in to v,
+// returning false if there is no such file.
+func decodeZipXML(zr *zip.Reader, name string, v any) (bool, error) {
+	for _, f := range zr.File {
+		if f.Name != name {
+			continue
+		}
+
+		r, err := f.Open()
+		if err != nil {
+			return true, err
+		}
+		defer r.Close()
+
+		return true, xml.NewDecoder(r).Decode(v)
+	}
+
+	return false, nil
+}
+
+// values returns our cell values, resolving shared strings and placing cells
+// in the correct rows and columns according to their references. Empty rows,
+// which are omitted from the XLSX, are returned as empty slices.
+func (ws xlsxWorksheet) values(shared xlsxSharedStrings) ([][]string, error) {
+	values := make([][]string, 0, len(ws.Rows))
+
+	for _, row := range ws.Rows {
+		for len(values) < row.Number-1 {
+			values = append(values, nil)
+		}
+
+		var vals []string
+
+		for i, cell := range row.Cells {
+			col := i
+
+			if cell.Ref != "" {
+				col = xlsxColumnIndex(cell.Ref)
+			}
+
+			for len(vals) <= col {
+				vals = append(vals, "")
+			}
+
+			val, err := xlsxCellValue(cell.Type, cell.Value, cell.Inline, shared)
+			if err != nil {
+				return nil, err
+			}
+
+			vals[col] = val
+		}
+
+		values = append(values, vals)
+	}
+
+	return values, nil
+}
+
+// xlsxColumnIndex converts a cell reference like "AB12" to a 0-based column
+// index like 27.
+func xlsxColumnIndex(ref string) int {
+	col := 0
+
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+
+		col = col*lettersInAlphabet + int(r-'A') + 1
+	}
+
+	return col - 1
+}
+
+func xlsxCellValue(cellType, value string, inline xlsxText, shared xlsxSharedStrings) (string, error) {
+	switch cellType {
+	case xlsxTypeSharedString:
+		i, err := strconv.Atoi(value)
+		if err != nil || i < 0 || i >= len(shared.Items) {
+			return "", fmt.Errorf("%w: shared string index %q", ErrInvalidXLSX, value)
+		}
+
+		return shared.Items[i].String(), nil
+	case xlsxTypeInlineString:
+		return inline.String(), nil
+	case xlsxTypeBool:
+		return strings.ToUpper(strconv.FormatBool(value == xlsxTrue)), nil
+	case xlsxTypeNumber, "":
+		if f, err := strconv.ParseFloat(value, floatBits); err == nil {
+			return strconv.FormatFloat(f, 'f', -1, floatBits), nil
+		}
+	}
+
+	return value, nil
+}
@@ -70,50 +70,53 @@ func StringToMutagenesisType(s string) (MutagenesisType, error) {
 	}
 }
 
+// Experiment holds the DiMSum settings for an experiment, and its samples.
+// Fields with a "sheet" struct tag are read from the correspondingly named
+// column of the experiments sheet (see the sheets package).
 type Experiment struct {
-	ExperimentID                   string
-	Assay                          string
-	StartStage                     int
-	StopStage                      int
-	BarcodeDesignPath              string
-	BarcodeErrorRate               string
-	ExperimentDesignPairDuplicates bool
-	CountPath                      string
-	BarcodeIdentityPath            string
-	Cutadapt5First                 string
-	Cutadapt5Second                string
-	CutadaptMinLength              int
-	CutadaptErrorRate              string
-	CutadaptOverlap                int
-	CutadaptCut5First              string
-	CutadaptCut5Second             string
-	CutadaptCut3First              string
-	CutadaptCut3Second             string
-	VsearchMinQual                 int
-	VsearchMaxQual                 int
-	VsearchMaxee                   int
-	VsearchMinovlen                int
-	ReverseComplement              bool
-	WildtypeSequence               string
-	PermittedSequences             string
-	SequenceType                   SequenceType
-	MutagenesisType                MutagenesisType
-	Indels                         string
-	MaxSubstitutions               int
-	MixedSubstitutions             bool
-	FitnessMinInputCountAll        int
-	FitnessMinInputCountAny        int
-	FitnessMinOutputCountAll       int
-	FitnessMinOutputCountAny       int
-	FitnessNormalise               bool
-	FitnessErrorModel              bool
-	FitnessDropoutPseudocount      int
-	RetainedReplicates             string
-	Stranded                       bool
-	Paired                         bool
-	SynonymSequencePath            string
-	TransLibrary                   bool
-	TransLibraryReverseComplement  bool
+	ExperimentID                   string          `sheet:"experiment_id|experiment"`
+	Assay                          string          `sheet:"Assay"`
+	StartStage                     int             `sheet:"startStage"`
+	StopStage                      int             `sheet:"stopStage"`
+	BarcodeDesignPath              string          `sheet:"barcodeDesignPath"`
+	BarcodeErrorRate               string          `sheet:"barcodeErrorRate,float"`
+	ExperimentDesignPairDuplicates bool            `sheet:"experimentDesignPairDuplicates"`
+	CountPath                      string          `sheet:"countPath"`
+	BarcodeIdentityPath            string          `sheet:"barcodeIdentityPath"`
+	Cutadapt5First                 string          `sheet:"cutadapt5First"`
+	Cutadapt5Second                string          `sheet:"cutadapt5Second"`
+	CutadaptMinLength              int             `sheet:"cutadaptMinLength"`
+	CutadaptErrorRate              string          `sheet:"cutadaptErrorRate,float"`
+	CutadaptOverlap                int             `sheet:"cutadaptOverlap"`
+	CutadaptCut5First              string          `sheet:"cutadaptCut5First"`
+	CutadaptCut5Second             string          `sheet:"cutadaptCut5Second"`
+	CutadaptCut3First              string          `sheet:"cutadaptCut3First"`
+	CutadaptCut3Second             string          `sheet:"cutadaptCut3Second"`
+	VsearchMinQual                 int             `sheet:"vsearchMinQual"`
+	VsearchMaxQual                 int             `sheet:"vsearchMaxQual"`
+	VsearchMaxee                   int             `sheet:"vsearchMaxee"`
+	VsearchMinovlen                int             `sheet:"vsearchMinovlen"`
+	ReverseComplement              bool            `sheet:"reverseComplement"`
+	WildtypeSequence               string          `sheet:"wildtypeSequence"`
+	PermittedSequences             string          `sheet:"permittedSequences"`
+	SequenceType                   SequenceType    `sheet:"sequenceType"`
+	MutagenesisType                MutagenesisType `sheet:"mutagenesisType"`
+	Indels                         string          `sheet:"indels"`
+	MaxSubstitutions               int             `sheet:"maxSubstitutions"`
+	MixedSubstitutions             bool            `sheet:"mixedSubstitutions"`
+	FitnessMinInputCountAll        int             `sheet:"fitnessMinInputCountAll"`
+	FitnessMinInputCountAny        int             `sheet:"fitnessMinInputCountAny"`
+	FitnessMinOutputCountAll       int             `sheet:"fitnessMinOutputCountAll"`
+	FitnessMinOutputCountAny       int             `sheet:"fitnessMinOutputCountAny"`
+	FitnessNormalise               bool            `sheet:"fitnessNormalise"`
+	FitnessErrorModel              bool            `sheet:"fitnessErrorModel"`
+	FitnessDropoutPseudocount      int             `sheet:"fitnessDropoutPseudocount"`
+	RetainedReplicates             string          `sheet:"retainedReplicates"`
+	Stranded                       bool            `sheet:"stranded"`
+	Paired                         bool            `sheet:"paired"`
+	SynonymSequencePath            string          `sheet:"synonymSequencePath"`
+	TransLibrary                   bool            `sheet:"transLibrary"`
+	TransLibraryReverseComplement  bool            `sheet:"transLibraryReverseComplement"`
 	Samples                        []*Sample
 }
 
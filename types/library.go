@@ -26,6 +26,12 @@
 
 package types
 
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
 type Error string
 
 func (e Error) Error() string { return string(e) }
@@ -34,19 +40,53 @@ const (
 	ErrNoSamplesRequested            = Error("no samples requested")
 	ErrSamplesNotFound               = Error("samples not found")
 	ErrNotAllSamplesInSameExperiment = Error("not all samples in the same experiment")
+	ErrExperimentNotFound            = Error("experiment not found")
+	ErrNoSamplesSelected             = Error("no samples of the experiment were selected")
+	ErrSampleInMultipleExperiments   = Error("sample run is in more than one experiment")
 )
 
+// Library holds the metadata for a library and its Experiments. StudyID and
+// StudyName will be blank if the library's samples belong to more than one
+// study. Tagged fields are read from the libraries sheet.
 type Library struct {
 	StudyID          string
 	StudyName        string
-	LibraryID        string
-	WildtypeSequence string
-	MaxSubstitutions int
+	LibraryID        string `sheet:"library_id|library"`
+	WildtypeSequence string `sheet:"wildtypeSequence"`
+	MaxSubstitutions int    `sheet:"maxSubstitutions"`
 	Experiments      []*Experiment
 }
 
 type Libraries []*Library
 
+// Studies returns the sorted unique StudyIDs of the samples in this library. If
+// no samples have a StudyID, returns our own StudyID, if any.
+func (l *Library) Studies() []string {
+	seen := make(map[string]bool)
+
+	for _, exp := range l.Experiments {
+		for _, s := range exp.Samples {
+			if s.StudyID != "" {
+				seen[s.StudyID] = true
+			}
+		}
+	}
+
+	if len(seen) == 0 && l.StudyID != "" {
+		return []string{l.StudyID}
+	}
+
+	studies := make([]string, 0, len(seen))
+
+	for id := range seen {
+		studies = append(studies, id)
+	}
+
+	sort.Strings(studies)
+
+	return studies
+}
+
 // Subset returns a new Library containing only the experiment with the desired
 // samples inside it. If the given samples belong to more than one experiment,
 // an error is returned. If the samples are not found, an error is returned. The
@@ -61,6 +101,96 @@ func (l Libraries) Subset(desired []*Sample) (*Library, error) {
 	return l.findMatchingLibrary(valid)
 }
 
+// SubsetExperiment returns a new Library containing only the experiment with the
+// given ID, with only those of its samples for which keep returns true (or all
+// of them if keep is nil). Returns ErrExperimentNotFound if none of the
+// libraries have the experiment, or ErrNoSamplesSelected if no samples were
+// kept.
+func (l Libraries) SubsetExperiment(experimentID string, keep func(s *Sample) bool) (*Library, error) {
+	for _, lib := range l {
+		for _, exp := range lib.Experiments {
+			if exp.ExperimentID != experimentID {
+				continue
+			}
+
+			var samples []*Sample
+
+			for _, s := range exp.Samples {
+				if keep == nil || keep(s) {
+					samples = append(samples, s)
+				}
+			}
+
+			if len(samples) == 0 {
+				return nil, ErrNoSamplesSelected
+			}
+
+			return lib.Clone(exp, samples), nil
+		}
+	}
+
+	return nil, ErrExperimentNotFound
+}
+
+// SubsetByExperiment is like Subset(), but the desired samples can belong to
+// different experiments. It returns a new Library for each experiment that has
+// any of the desired samples, containing only that experiment with those
+// samples inside it. If any samples are not found, an error naming them is
+// returned, as is an ErrSampleInMultipleExperiments error if any are found in
+// more than one experiment.
+func (l Libraries) SubsetByExperiment(desired []*Sample) (Libraries, error) {
+	valid, err := getValidSamples(desired)
+	if err != nil {
+		return nil, err
+	}
+
+	found := make(map[string]string, len(valid))
+
+	var groups Libraries
+
+	for _, lib := range l {
+		for _, exp := range lib.Experiments {
+			samples := findDesiredSamplesInExperiment(exp, valid)
+			if len(samples) == 0 {
+				continue
+			}
+
+			for _, s := range samples {
+				if other, ok := found[s.Key()]; ok {
+					return nil, fmt.Errorf("%w: %s in %s and %s",
+						ErrSampleInMultipleExperiments, s.Key(), other, exp.ExperimentID)
+				}
+
+				found[s.Key()] = exp.ExperimentID
+			}
+
+			groups = append(groups, lib.Clone(exp, samples))
+		}
+	}
+
+	return groups, checkAllFound(valid, found)
+}
+
+// checkAllFound returns an ErrSamplesNotFound error naming the desired keys
+// that are not in found.
+func checkAllFound(desired map[string]bool, found map[string]string) error {
+	var missing []string
+
+	for key := range desired {
+		if _, ok := found[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+
+	if len(missing) == 0 {
+		return nil
+	}
+
+	sort.Strings(missing)
+
+	return fmt.Errorf("%w: %s", ErrSamplesNotFound, strings.Join(missing, ", "))
+}
+
 // getValidSamples extracts valid samples from input and returns a map of their
 // keys.
 func getValidSamples(desired []*Sample) (map[string]bool, error) {
@@ -117,6 +247,51 @@ func findDesiredSamplesInExperiment(exp *Experiment, desired map[string]bool) []
 	return samples
 }
 
+// Filter returns new Libraries containing only the samples for which keep
+// returns true. Experiments and Libraries left without any samples are
+// excluded. The original Libraries are not altered.
+func (l Libraries) Filter(keep func(lib *Library, exp *Experiment, s *Sample) bool) Libraries {
+	filtered := make(Libraries, 0, len(l))
+
+	for _, lib := range l {
+		var exps []*Experiment
+
+		for _, exp := range lib.Experiments {
+			var samples []*Sample
+
+			for _, s := range exp.Samples {
+				if keep(lib, exp, s) {
+					samples = append(samples, s)
+				}
+			}
+
+			if len(samples) > 0 {
+				exps = append(exps, exp.Clone(samples))
+			}
+		}
+
+		if len(exps) > 0 {
+			newL := *lib
+			newL.Experiments = exps
+			filtered = append(filtered, &newL)
+		}
+	}
+
+	return filtered
+}
+
+// SortSamples sorts the samples within each experiment in place, using the
+// given less function. The sort is stable.
+func (l Libraries) SortSamples(less func(a, b *Sample) bool) {
+	for _, lib := range l {
+		for _, exp := range lib.Experiments {
+			sort.SliceStable(exp.Samples, func(i, j int) bool {
+				return less(exp.Samples[i], exp.Samples[j])
+			})
+		}
+	}
+}
+
 // Clone returns a new Library with the given experiment and samples inside it.
 // It otherwise has the same values as the original Library.
 func (l *Library) Clone(exp *Experiment, samples []*Sample) *Library {
@@ -27,6 +27,7 @@
 package types
 
 import (
+	"errors"
 	"testing"
 
 	. "github.com/smartystreets/goconvey/convey"
@@ -100,6 +101,52 @@ func TestLibrary(t *testing.T) {
 
 		libraries := Libraries{lib1, lib2}
 
+		Convey("Studies returns the unique studies of a library's samples", func() {
+			So(lib1.Studies(), ShouldResemble, []string{"study1"})
+
+			lib2.Experiments[0].Samples[0].StudyID = "studyB"
+			lib2.Experiments[0].Samples[1].StudyID = "studyA"
+			lib2.Experiments[1].Samples[0].StudyID = "studyB"
+
+			So(lib2.Studies(), ShouldResemble, []string{"studyA", "studyB"})
+
+			So((&Library{}).Studies(), ShouldBeEmpty)
+		})
+
+		Convey("You can Filter them to get just certain samples", func() {
+			filtered := libraries.Filter(func(_ *Library, exp *Experiment, s *Sample) bool {
+				return exp.ExperimentID != "exp2" && s.SampleName != "sample2"
+			})
+
+			So(filtered, ShouldHaveLength, 2)
+			So(filtered[0].LibraryID, ShouldEqual, "lib1")
+			So(filtered[0].Experiments, ShouldHaveLength, 1)
+			So(filtered[0].Experiments[0].Samples, ShouldHaveLength, 1)
+			So(filtered[0].Experiments[0].Samples[0].SampleName, ShouldEqual, "sample1")
+			So(filtered[1].LibraryID, ShouldEqual, "lib2")
+			So(filtered[1].Experiments, ShouldHaveLength, 1)
+			So(filtered[1].Experiments[0].ExperimentID, ShouldEqual, "exp3")
+			So(filtered[1].Experiments[0].Samples, ShouldHaveLength, 2)
+
+			So(lib1.Experiments[0].Samples, ShouldHaveLength, 2)
+			So(lib2.Experiments, ShouldHaveLength, 2)
+
+			filtered = libraries.Filter(func(lib *Library, _ *Experiment, _ *Sample) bool {
+				return lib.LibraryID == "lib3"
+			})
+			So(filtered, ShouldBeEmpty)
+		})
+
+		Convey("You can sort the samples in each experiment", func() {
+			libraries.SortSamples(func(a, b *Sample) bool {
+				return a.SampleName > b.SampleName
+			})
+
+			So(lib1.Experiments[0].Samples[0].SampleName, ShouldEqual, "sample2")
+			So(lib1.Experiments[0].Samples[1].SampleName, ShouldEqual, "sample1")
+			So(lib2.Experiments[1].Samples[0].SampleName, ShouldEqual, "sample6")
+		})
+
 		Convey("Subset returns an error if no samples are requested", func() {
 			_, err := libraries.Subset([]*Sample{})
 			So(err, ShouldEqual, ErrNoSamplesRequested)
@@ -149,6 +196,63 @@ func TestLibrary(t *testing.T) {
 			So(result.Experiments[0].Samples[0].SampleName, ShouldEqual, "sample1")
 		})
 
+		Convey("SubsetByExperiment groups samples from different experiments", func() {
+			groups, err := libraries.SubsetByExperiment([]*Sample{
+				{SampleName: "sample5", RunID: "run3"},
+				{SampleName: "sample1", RunID: "run1"},
+				{SampleName: "sample3", RunID: "run2"},
+				{SampleName: "sample6", RunID: "run3"},
+			})
+			So(err, ShouldBeNil)
+			So(groups, ShouldHaveLength, 3)
+			So(groups[0].LibraryID, ShouldEqual, "lib1")
+			So(groups[0].Experiments[0].Samples, ShouldHaveLength, 1)
+			So(groups[1].Experiments[0].ExperimentID, ShouldEqual, "exp2")
+			So(groups[2].LibraryID, ShouldEqual, "lib2")
+			So(groups[2].Experiments, ShouldHaveLength, 1)
+			So(groups[2].Experiments[0].ExperimentID, ShouldEqual, "exp3")
+			So(groups[2].Experiments[0].Samples, ShouldHaveLength, 2)
+
+			_, err = libraries.SubsetByExperiment([]*Sample{
+				{SampleName: "sample1", RunID: "run1"},
+				{SampleName: "sample9", RunID: "run9"},
+				{SampleName: "sample8", RunID: "run1"},
+			})
+			So(errors.Is(err, ErrSamplesNotFound), ShouldBeTrue)
+			So(err.Error(), ShouldEndWith, ": sample8.run1, sample9.run9")
+
+			_, err = libraries.SubsetByExperiment(nil)
+			So(err, ShouldEqual, ErrNoSamplesRequested)
+
+			lib2.Experiments[1].Samples = append(lib2.Experiments[1].Samples, &Sample{SampleName: "sample3", RunID: "run2"})
+
+			_, err = libraries.SubsetByExperiment([]*Sample{{SampleName: "sample3", RunID: "run2"}})
+			So(errors.Is(err, ErrSampleInMultipleExperiments), ShouldBeTrue)
+		})
+
+		Convey("SubsetExperiment returns a library with the chosen samples of an experiment", func() {
+			result, err := libraries.SubsetExperiment("exp3", nil)
+			So(err, ShouldBeNil)
+			So(result.LibraryID, ShouldEqual, "lib2")
+			So(result.Experiments, ShouldHaveLength, 1)
+			So(result.Experiments[0].ExperimentID, ShouldEqual, "exp3")
+			So(result.Experiments[0].Samples, ShouldHaveLength, 2)
+			So(lib2.Experiments, ShouldHaveLength, 2)
+
+			result, err = libraries.SubsetExperiment("exp3", func(s *Sample) bool {
+				return s.SampleName == "sample6"
+			})
+			So(err, ShouldBeNil)
+			So(result.Experiments[0].Samples, ShouldHaveLength, 1)
+			So(result.Experiments[0].Samples[0].SampleName, ShouldEqual, "sample6")
+
+			_, err = libraries.SubsetExperiment("exp3", func(_ *Sample) bool { return false })
+			So(err, ShouldEqual, ErrNoSamplesSelected)
+
+			_, err = libraries.SubsetExperiment("exp9", nil)
+			So(err, ShouldEqual, ErrExperimentNotFound)
+		})
+
 		Convey("Subset correctly handles the Sample.Key method for matching", func() {
 			samples := []*Sample{
 				{SampleName: "sample5", RunID: "run3"},
@@ -0,0 +1,90 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Authors:
+ *	- Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package types
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	ErrInvalidNameRun = Error("invalid sampleName:runID pair")
+
+	nameRunSeparator  = ":"
+	nameRunsSeparator = "\t"
+	nameRunsComment   = "#"
+)
+
+// StringToNameRun converts a "sampleName:runID" string to a Sample with just
+// SampleName and RunID set.
+func StringToNameRun(s string) (*Sample, error) {
+	parts := strings.Split(s, nameRunSeparator)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidNameRun, s)
+	}
+
+	return &Sample{SampleName: parts[0], RunID: parts[1]}, nil
+}
+
+// ReadNameRuns reads a TSV of sample names in the first column and run IDs in
+// the second, returning a Sample with just SampleName and RunID set for each
+// line. Blank lines, lines starting with # and a header line of "sample_name"
+// and "run_id" are ignored, as are any further columns.
+func ReadNameRuns(r io.Reader) ([]*Sample, error) {
+	var samples []*Sample
+
+	scanner := bufio.NewScanner(r)
+
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, nameRunsComment) {
+			continue
+		}
+
+		fields := strings.Split(line, nameRunsSeparator)
+		if len(fields) < 2 || fields[0] == "" || fields[1] == "" {
+			return nil, fmt.Errorf("%w: line %d: %q", ErrInvalidNameRun, n, line)
+		}
+
+		if n == 1 && isNameRunsHeader(fields) {
+			continue
+		}
+
+		samples = append(samples, &Sample{
+			SampleName: strings.TrimSpace(fields[0]),
+			RunID:      strings.TrimSpace(fields[1]),
+		})
+	}
+
+	return samples, scanner.Err()
+}
+
+func isNameRunsHeader(fields []string) bool {
+	return strings.EqualFold(fields[0], "sample_name") && strings.EqualFold(fields[1], "run_id")
+}
@@ -0,0 +1,66 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Authors:
+ *	- Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package types
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestNameRuns(t *testing.T) {
+	Convey("You can convert sampleName:runID strings to Samples", t, func() {
+		s, err := StringToNameRun("sample1:123")
+		So(err, ShouldBeNil)
+		So(s, ShouldResemble, &Sample{SampleName: "sample1", RunID: "123"})
+
+		for _, invalid := range []string{"sample1", "sample1:", ":123", "a:b:c"} {
+			_, err = StringToNameRun(invalid)
+			So(errors.Is(err, ErrInvalidNameRun), ShouldBeTrue)
+		}
+	})
+
+	Convey("You can read a TSV of sample names and run IDs", t, func() {
+		samples, err := ReadNameRuns(strings.NewReader(
+			"sample_name\trun_id\n# a comment\nsample1\t123\n\nsample2\t456\tignored\n"))
+		So(err, ShouldBeNil)
+		So(samples, ShouldResemble, []*Sample{
+			{SampleName: "sample1", RunID: "123"},
+			{SampleName: "sample2", RunID: "456"},
+		})
+
+		samples, err = ReadNameRuns(strings.NewReader("sample1\t123"))
+		So(err, ShouldBeNil)
+		So(samples, ShouldHaveLength, 1)
+
+		_, err = ReadNameRuns(strings.NewReader("sample1\t123\nsample2 456\n"))
+		So(errors.Is(err, ErrInvalidNameRun), ShouldBeTrue)
+		So(err.Error(), ShouldContainSubstring, "line 2")
+	})
+}
@@ -0,0 +1,239 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Authors:
+ *	- Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package types
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	ErrInvalidSequence   = Error("invalid nucleotide sequence")
+	ErrNotCodons         = Error("coding sequence length not a multiple of 3")
+	ErrInvalidAdaptor    = Error("invalid cutadapt adaptor")
+	ErrInvalidCut        = Error("invalid cutadapt cut length")
+	ErrPermittedLength   = Error("permitted sequences length doesn't match wildtype sequence")
+	ErrUnknownReplicate  = Error("retained replicate not amongst the experiment's samples")
+	ErrPathNotFound      = Error("path not found")
+	ErrInvalidReplicate  = Error("invalid experiment replicate")
+	ErrMissingSelections = Error("experiment lacks input or output samples")
+
+	allReplicates = "all"
+	codonLength   = 3
+)
+
+var (
+	nucleotidesRegex = regexp.MustCompile(`(?i)^[ACGT]+$`)
+	iupacRegex       = regexp.MustCompile(`(?i)^[ACGTURYSWKMBDHVN]+$`)
+	adaptorRegex     = regexp.MustCompile(`(?i)^\^?[ACGTURYSWKMBDHVNX]+\$?(\.\.\.[ACGTURYSWKMBDHVNX]+\$?)?$`)
+)
+
+// Problem describes a value that was read from a sheet without error, but that
+// wouldn't make sense to DiMSum.
+type Problem struct {
+	// Column is the header of the value's column, as given in the field's
+	// "sheet" struct tag.
+	Column string
+
+	// Value is the offending value.
+	Value string
+
+	// Expected describes what the value should have been.
+	Expected string
+
+	// Err is the underlying error.
+	Err error
+}
+
+// Error says what the value was and what was expected.
+func (p *Problem) Error() string {
+	return fmt.Sprintf("%s %q is not %s", p.Column, p.Value, p.Expected)
+}
+
+// Unwrap returns the underlying error.
+func (p *Problem) Unwrap() error {
+	return p.Err
+}
+
+// problems collects Problems.
+type problems []*Problem
+
+func (ps *problems) add(column, value, expected string, err error) {
+	*ps = append(*ps, &Problem{Column: column, Value: value, Expected: expected, Err: err})
+}
+
+// checkSequence adds a problem if the given non-blank value isn't made of
+// nucleotides.
+func (ps *problems) checkSequence(column, seq string) {
+	if seq != "" && !nucleotidesRegex.MatchString(seq) {
+		ps.add(column, seq, "a sequence of A, C, G and T", ErrInvalidSequence)
+	}
+}
+
+// checkAdaptor adds a problem if the given non-blank value isn't a cutadapt
+// adaptor.
+func (ps *problems) checkAdaptor(column, adaptor string) {
+	if adaptor != "" && !adaptorRegex.MatchString(adaptor) {
+		ps.add(column, adaptor, "a cutadapt adaptor, like ACGT, ^ACGT, ACGT$ or ACGT...TTGG", ErrInvalidAdaptor)
+	}
+}
+
+// checkCut adds a problem if the given non-blank value isn't a number of bases
+// to cut.
+func (ps *problems) checkCut(column, cut string) {
+	if cut == "" {
+		return
+	}
+
+	if n, err := strconv.Atoi(cut); err != nil || n < 0 {
+		ps.add(column, cut, "a whole number of bases", ErrInvalidCut)
+	}
+}
+
+// checkPath adds a problem if the given non-blank path doesn't exist according
+// to pathExists. Does nothing if pathExists is nil.
+func (ps *problems) checkPath(column, path string, pathExists func(string) bool) {
+	if path != "" && pathExists != nil && !pathExists(path) {
+		ps.add(column, path, "an existing path", ErrPathNotFound)
+	}
+}
+
+// Problems returns the problems with this library's values, checking that its
+// WildtypeSequence is made of nucleotides.
+func (l *Library) Problems() []*Problem {
+	var ps problems
+
+	ps.checkSequence("wildtypeSequence", l.WildtypeSequence)
+
+	return ps
+}
+
+// Problems returns the problems with this experiment's values that DiMSum
+// would choke on:
+//
+//   - WildtypeSequence must be made of nucleotides, and be a multiple of 3
+//     long if SequenceType is coding.
+//   - The cutadapt adaptors must be valid cutadapt adaptor strings, and the
+//     cutadapt cuts must be numbers of bases.
+//   - PermittedSequences must be IUPAC codes as long as WildtypeSequence.
+//   - RetainedReplicates must be "all" or ExperimentReplicates of our Samples.
+//   - We must have both input and output Samples.
+//   - If pathExists is not nil, the barcode design and identity, count and
+//     synonym sequence paths must exist according to it.
+//
+// Problems with our Samples are not included; see Sample.Problems().
+func (e *Experiment) Problems(pathExists func(string) bool) []*Problem {
+	var ps problems
+
+	e.sequenceProblems(&ps)
+
+	ps.checkAdaptor("cutadapt5First", e.Cutadapt5First)
+	ps.checkAdaptor("cutadapt5Second", e.Cutadapt5Second)
+	ps.checkCut("cutadaptCut5First", e.CutadaptCut5First)
+	ps.checkCut("cutadaptCut5Second", e.CutadaptCut5Second)
+	ps.checkCut("cutadaptCut3First", e.CutadaptCut3First)
+	ps.checkCut("cutadaptCut3Second", e.CutadaptCut3Second)
+
+	e.replicateProblems(&ps)
+	e.selectionProblems(&ps)
+
+	ps.checkPath("barcodeDesignPath", e.BarcodeDesignPath, pathExists)
+	ps.checkPath("barcodeIdentityPath", e.BarcodeIdentityPath, pathExists)
+	ps.checkPath("countPath", e.CountPath, pathExists)
+	ps.checkPath("synonymSequencePath", e.SynonymSequencePath, pathExists)
+
+	return ps
+}
+
+func (e *Experiment) sequenceProblems(ps *problems) {
+	ps.checkSequence("wildtypeSequence", e.WildtypeSequence)
+
+	if e.SequenceType == SequenceTypeC && len(e.WildtypeSequence)%codonLength != 0 {
+		ps.add("wildtypeSequence", e.WildtypeSequence,
+			"a multiple of 3 bases long, since sequenceType is coding", ErrNotCodons)
+	}
+
+	if e.PermittedSequences == "" {
+		return
+	}
+
+	if !iupacRegex.MatchString(e.PermittedSequences) || len(e.PermittedSequences) != len(e.WildtypeSequence) {
+		ps.add("permittedSequences", e.PermittedSequences,
+			fmt.Sprintf("a sequence of IUPAC nucleotide codes %d long, like the wildtypeSequence",
+				len(e.WildtypeSequence)), ErrPermittedLength)
+	}
+}
+
+func (e *Experiment) replicateProblems(ps *problems) {
+	if e.RetainedReplicates == "" || strings.EqualFold(e.RetainedReplicates, allReplicates) {
+		return
+	}
+
+	replicates := make(map[int]bool, len(e.Samples))
+
+	for _, s := range e.Samples {
+		replicates[s.ExperimentReplicate] = true
+	}
+
+	for _, rep := range strings.Split(e.RetainedReplicates, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(rep))
+		if err != nil || !replicates[n] {
+			ps.add("retainedReplicates", e.RetainedReplicates,
+				"all, or a comma separated list of the experiment_replicates of the experiment's samples",
+				ErrUnknownReplicate)
+
+			return
+		}
+	}
+}
+
+func (e *Experiment) selectionProblems(ps *problems) {
+	selections := make(map[Selection]bool, 2) //nolint:mnd
+
+	for _, s := range e.Samples {
+		selections[s.Selection] = true
+	}
+
+	if !selections[SelectionInput] || !selections[SelectionOutput] {
+		ps.add("experiment_id", e.ExperimentID, "an experiment with both input and output samples",
+			ErrMissingSelections)
+	}
+}
+
+// Problems returns the problems with this sample's values, checking that its
+// ExperimentReplicate is at least 1.
+func (s *Sample) Problems() []*Problem {
+	var ps problems
+
+	if s.ExperimentReplicate < 1 {
+		ps.add("experiment_replicate", strconv.Itoa(s.ExperimentReplicate), "1 or more", ErrInvalidReplicate)
+	}
+
+	return ps
+}
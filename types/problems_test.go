@@ -0,0 +1,140 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Authors:
+ *	- Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package types
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestProblems(t *testing.T) {
+	Convey("Libraries must have nucleotide wildtype sequences", t, func() {
+		So((&Library{WildtypeSequence: "acgtACGT"}).Problems(), ShouldBeEmpty)
+
+		ps := (&Library{WildtypeSequence: "ACGU"}).Problems()
+		So(ps, ShouldHaveLength, 1)
+		So(ps[0].Column, ShouldEqual, "wildtypeSequence")
+		So(ps[0].Value, ShouldEqual, "ACGU")
+		So(errors.Is(ps[0], ErrInvalidSequence), ShouldBeTrue)
+		So(ps[0].Error(), ShouldEqual, `wildtypeSequence "ACGU" is not a sequence of A, C, G and T`)
+	})
+
+	Convey("Samples must have an experiment replicate", t, func() {
+		So((&Sample{ExperimentReplicate: 1}).Problems(), ShouldBeEmpty)
+
+		ps := (&Sample{}).Problems()
+		So(ps, ShouldHaveLength, 1)
+		So(errors.Is(ps[0], ErrInvalidReplicate), ShouldBeTrue)
+	})
+
+	Convey("Given a valid experiment", t, func() {
+		exp := &Experiment{
+			ExperimentID:        "exp1",
+			WildtypeSequence:    "ACGTTT",
+			SequenceType:        SequenceTypeC,
+			PermittedSequences:  "NNNACG",
+			Cutadapt5First:      "^GGATCC...AAGN$",
+			Cutadapt5Second:     "aagctt",
+			CutadaptCut5First:   "3",
+			RetainedReplicates:  "1,2",
+			BarcodeIdentityPath: "/exists",
+			Samples: []*Sample{
+				{Selection: SelectionInput, ExperimentReplicate: 1},
+				{Selection: SelectionOutput, ExperimentReplicate: 2},
+			},
+		}
+
+		exists := func(path string) bool { return path == "/exists" }
+
+		So(exp.Problems(exists), ShouldBeEmpty)
+
+		problemsFor := func() []*Problem {
+			ps := exp.Problems(exists)
+			So(ps, ShouldHaveLength, 1)
+
+			return ps
+		}
+
+		Convey("Coding wildtype sequences must be a multiple of 3 long", func() {
+			exp.WildtypeSequence = "ACGTT"
+			exp.PermittedSequences = ""
+			ps := problemsFor()
+			So(errors.Is(ps[0], ErrNotCodons), ShouldBeTrue)
+
+			exp.SequenceType = SequenceTypeNC
+			So(exp.Problems(exists), ShouldBeEmpty)
+		})
+
+		Convey("Wildtype sequences must be nucleotides", func() {
+			exp.WildtypeSequence = "ACGTTX"
+			So(errors.Is(problemsFor()[0], ErrInvalidSequence), ShouldBeTrue)
+		})
+
+		Convey("Adaptors must be valid", func() {
+			exp.Cutadapt5Second = "GGA TCC"
+			ps := problemsFor()
+			So(ps[0].Column, ShouldEqual, "cutadapt5Second")
+			So(errors.Is(ps[0], ErrInvalidAdaptor), ShouldBeTrue)
+		})
+
+		Convey("Cuts must be numbers of bases", func() {
+			exp.CutadaptCut3Second = "-1"
+			So(errors.Is(problemsFor()[0], ErrInvalidCut), ShouldBeTrue)
+		})
+
+		Convey("Permitted sequences must match the wildtype length", func() {
+			exp.PermittedSequences = "NNN"
+			ps := problemsFor()
+			So(errors.Is(ps[0], ErrPermittedLength), ShouldBeTrue)
+			So(ps[0].Expected, ShouldContainSubstring, "6 long")
+		})
+
+		Convey("Retained replicates must exist", func() {
+			exp.RetainedReplicates = "all"
+			So(exp.Problems(exists), ShouldBeEmpty)
+
+			exp.RetainedReplicates = "1,3"
+			So(errors.Is(problemsFor()[0], ErrUnknownReplicate), ShouldBeTrue)
+		})
+
+		Convey("There must be input and output samples", func() {
+			exp.Samples = exp.Samples[:1]
+			exp.RetainedReplicates = ""
+			ps := problemsFor()
+			So(ps[0].Column, ShouldEqual, "experiment_id")
+			So(errors.Is(ps[0], ErrMissingSelections), ShouldBeTrue)
+		})
+
+		Convey("Paths must exist, if checked", func() {
+			exp.BarcodeIdentityPath = "/missing"
+			So(errors.Is(problemsFor()[0], ErrPathNotFound), ShouldBeTrue)
+			So(exp.Problems(nil), ShouldBeEmpty)
+		})
+	})
+}
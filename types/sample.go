@@ -29,11 +29,16 @@ package types
 import (
 	"fmt"
 	"math"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 )
 
 const (
 	ErrInvalidSelection = Error("invalid selection")
+	ErrInvalidQCPolicy  = Error("invalid QC policy")
+	ErrInvalidQCState   = Error("invalid QC state")
 
 	generationsMin = 0.05
 )
@@ -57,26 +62,158 @@ func StringToSelection(s string) (Selection, error) {
 	}
 }
 
+// QC states of sample runs, as returned by Sample.QCState().
+const (
+	QCStatePassed  = "pass"
+	QCStateFailed  = "fail"
+	QCStatePending = "pending"
+)
+
+// StringToQCState converts a string to one of the QCState*s. The spellings of
+// the QCPolicy*s ("passed" and "failed") are also accepted.
+func StringToQCState(s string) (string, error) {
+	switch s {
+	case QCStatePassed, "passed":
+		return QCStatePassed, nil
+	case QCStateFailed, string(QCPolicyIncludeFailed):
+		return QCStateFailed, nil
+	case QCStatePending:
+		return QCStatePending, nil
+	default:
+		return "", ErrInvalidQCState
+	}
+}
+
+// ManualQC values as stored in MLWH. Samples that have not been QC'd yet have
+// a blank ManualQC.
+const (
+	ManualQCPassed  = "1"
+	ManualQCFailed  = "0"
+	ManualQCPending = ""
+)
+
+// QCPolicy determines which samples are acceptable based on their ManualQC.
+type QCPolicy string
+
+const (
+	// QCPolicyPassOnly only accepts samples that passed manual QC.
+	QCPolicyPassOnly QCPolicy = "pass"
+
+	// QCPolicyIncludeFailed accepts samples that passed or failed manual QC.
+	// Users should be warned about the failed ones.
+	QCPolicyIncludeFailed QCPolicy = "failed"
+
+	// QCPolicyIncludePending accepts samples that passed manual QC, or that
+	// have not been QC'd yet.
+	QCPolicyIncludePending QCPolicy = "pending"
+)
+
+// StringToQCPolicy converts a string to a QCPolicy. Blank strings are treated
+// as QCPolicyPassOnly. The spellings of the QCState*s ("fail") are also
+// accepted.
+func StringToQCPolicy(s string) (QCPolicy, error) {
+	switch QCPolicy(s) {
+	case QCPolicyPassOnly, QCPolicy(""), "passed":
+		return QCPolicyPassOnly, nil
+	case QCPolicyIncludeFailed, QCPolicy(QCStateFailed):
+		return QCPolicyIncludeFailed, nil
+	case QCPolicyIncludePending:
+		return QCPolicyIncludePending, nil
+	default:
+		return "", ErrInvalidQCPolicy
+	}
+}
+
+// Accepts returns true if the given sample's ManualQC is acceptable under this
+// policy. An unset policy is treated as QCPolicyPassOnly.
+func (p QCPolicy) Accepts(s *Sample) bool {
+	switch {
+	case s.QCPassed():
+		return true
+	case s.QCFailed():
+		return p == QCPolicyIncludeFailed
+	default:
+		return p == QCPolicyIncludePending
+	}
+}
+
+// Sample holds the metadata for a sample run. The sequencing details (Lanes
+// through PipelineIDLims) come from MLWH, and are taken from the first lane of
+// the run for those that could differ between lanes, except ManualQC, which is
+// failed if any lane failed, passed if all passed, and otherwise pending.
+// PipelineIDLims is the library type LIMS records in
+// iseq_flowcell.pipeline_id_lims (eg. "Custom"). Tagged fields are read from
+// the samples sheet.
 type Sample struct {
-	SampleName          string
+	SampleName          string `sheet:"mlwh_sample_name|sample_name"`
 	SampleID            string
-	RunID               string
+	StudyID             string
+	StudyName           string
+	RunID               string `sheet:"run_id,optional"`
 	ManualQC            string
-	Selection           Selection
-	ExperimentReplicate int
-	TechnicalReplicate  int
-	SelectionTime       string
-	CellDensity         string
+	Lanes               []int
+	TagIndex            int
+	InstrumentModel     string
+	RunComplete         time.Time
+	ReadLength          int
+	PipelineIDLims      string
+	Selection           Selection `sheet:"selection"`
+	ExperimentReplicate int       `sheet:"experiment_replicate"`
+	TechnicalReplicate  int       `sheet:"technical_replicate,optional"`
+	SelectionTime       string    `sheet:"selection_time,float"`
+	CellDensity         string    `sheet:"cell_density,float"`
 	Pair1               string
 	Pair2               string
 }
 
+// CompareRunIDs compares run IDs numerically if they are both numbers,
+// otherwise lexically. It returns a negative number if a sorts before b, a
+// positive number if after, and 0 if they are the same.
+func CompareRunIDs(a, b string) int {
+	ai, errA := strconv.Atoi(a)
+	bi, errB := strconv.Atoi(b)
+
+	if errA == nil && errB == nil {
+		return ai - bi
+	}
+
+	return strings.Compare(a, b)
+}
+
 // Key returns a unique key for this sample, which is the SampleName and RunID
 // concatenated with a period.
 func (s *Sample) Key() string {
 	return s.SampleName + "." + s.RunID
 }
 
+// QCPassed returns true if this sample passed manual QC.
+func (s *Sample) QCPassed() bool {
+	return s.ManualQC == ManualQCPassed
+}
+
+// QCFailed returns true if this sample failed manual QC.
+func (s *Sample) QCFailed() bool {
+	return s.ManualQC == ManualQCFailed
+}
+
+// QCPending returns true if this sample has not been manually QC'd yet.
+func (s *Sample) QCPending() bool {
+	return !s.QCPassed() && !s.QCFailed()
+}
+
+// QCState returns QCStatePassed, QCStateFailed or QCStatePending, depending on
+// this sample's manual QC.
+func (s *Sample) QCState() string {
+	switch {
+	case s.QCPassed():
+		return QCStatePassed
+	case s.QCFailed():
+		return QCStateFailed
+	default:
+		return QCStatePending
+	}
+}
+
 // DimsumSampleName is the selection and replicate number, eg. "input1" or
 // "output2".
 func (s *Sample) DimsumSampleName() string {
@@ -128,6 +265,7 @@ func (s *Sample) Generations() float32 {
 // Clone returns a new Sample with the same values as the original.
 func (s *Sample) Clone() *Sample {
 	newS := *s
+	newS.Lanes = slices.Clone(s.Lanes)
 
 	return &newS
 }
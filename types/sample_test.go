@@ -41,6 +41,14 @@ func TestSample(t *testing.T) {
 		So(s.Key(), ShouldEqual, "sample.run")
 	})
 
+	Convey("CompareRunIDs compares numerically where possible", t, func() {
+		So(CompareRunIDs("9", "10"), ShouldBeLessThan, 0)
+		So(CompareRunIDs("10", "9"), ShouldBeGreaterThan, 0)
+		So(CompareRunIDs("10", "10"), ShouldEqual, 0)
+		So(CompareRunIDs("run9", "run10"), ShouldBeGreaterThan, 0)
+		So(CompareRunIDs("10", "run1"), ShouldBeLessThan, 0)
+	})
+
 	Convey("DimsumSampleName() combines selection and experiment replicate", t, func() {
 		s := &Sample{
 			Selection:           SelectionInput,
@@ -99,17 +107,102 @@ func TestSample(t *testing.T) {
 		So(s, ShouldEqual, Selection(""))
 	})
 
+	Convey("You can check a Sample's manual QC state", t, func() {
+		passed := &Sample{ManualQC: ManualQCPassed}
+		failed := &Sample{ManualQC: ManualQCFailed}
+		pending := &Sample{ManualQC: ManualQCPending}
+
+		So(passed.QCPassed(), ShouldBeTrue)
+		So(passed.QCFailed(), ShouldBeFalse)
+		So(passed.QCPending(), ShouldBeFalse)
+
+		So(failed.QCPassed(), ShouldBeFalse)
+		So(failed.QCFailed(), ShouldBeTrue)
+		So(failed.QCPending(), ShouldBeFalse)
+
+		So(pending.QCPassed(), ShouldBeFalse)
+		So(pending.QCFailed(), ShouldBeFalse)
+		So(pending.QCPending(), ShouldBeTrue)
+
+		So(passed.QCState(), ShouldEqual, QCStatePassed)
+		So(failed.QCState(), ShouldEqual, QCStateFailed)
+		So(pending.QCState(), ShouldEqual, QCStatePending)
+
+		Convey("And apply a QCPolicy to them", func() {
+			So(QCPolicyPassOnly.Accepts(passed), ShouldBeTrue)
+			So(QCPolicyPassOnly.Accepts(failed), ShouldBeFalse)
+			So(QCPolicyPassOnly.Accepts(pending), ShouldBeFalse)
+
+			So(QCPolicyIncludeFailed.Accepts(passed), ShouldBeTrue)
+			So(QCPolicyIncludeFailed.Accepts(failed), ShouldBeTrue)
+			So(QCPolicyIncludeFailed.Accepts(pending), ShouldBeFalse)
+
+			So(QCPolicyIncludePending.Accepts(passed), ShouldBeTrue)
+			So(QCPolicyIncludePending.Accepts(failed), ShouldBeFalse)
+			So(QCPolicyIncludePending.Accepts(pending), ShouldBeTrue)
+
+			So(QCPolicy("").Accepts(passed), ShouldBeTrue)
+			So(QCPolicy("").Accepts(failed), ShouldBeFalse)
+		})
+	})
+
+	Convey("You can convert strings to QCPolicies", t, func() {
+		p, err := StringToQCPolicy("pass")
+		So(err, ShouldBeNil)
+		So(p, ShouldEqual, QCPolicyPassOnly)
+
+		p, err = StringToQCPolicy("")
+		So(err, ShouldBeNil)
+		So(p, ShouldEqual, QCPolicyPassOnly)
+
+		p, err = StringToQCPolicy("failed")
+		So(err, ShouldBeNil)
+		So(p, ShouldEqual, QCPolicyIncludeFailed)
+
+		p, err = StringToQCPolicy("pending")
+		So(err, ShouldBeNil)
+		So(p, ShouldEqual, QCPolicyIncludePending)
+
+		_, err = StringToQCPolicy("foo")
+		So(err, ShouldEqual, ErrInvalidQCPolicy)
+
+		for s, expected := range map[string]QCPolicy{"passed": QCPolicyPassOnly, "fail": QCPolicyIncludeFailed} {
+			p, err = StringToQCPolicy(s)
+			So(err, ShouldBeNil)
+			So(p, ShouldEqual, expected)
+		}
+	})
+
+	Convey("You can convert strings to QC states, using either spelling", t, func() {
+		for s, expected := range map[string]string{
+			"pass": QCStatePassed, "passed": QCStatePassed,
+			"fail": QCStateFailed, "failed": QCStateFailed,
+			"pending": QCStatePending,
+		} {
+			state, err := StringToQCState(s)
+			So(err, ShouldBeNil)
+			So(state, ShouldEqual, expected)
+		}
+
+		_, err := StringToQCState("foo")
+		So(err, ShouldEqual, ErrInvalidQCState)
+	})
+
 	// TODO: Generations() testable here?
 
 	Convey("Clone lets you copy a Sample", t, func() {
 		orig := &Sample{
 			SampleName: "sample1",
+			Lanes:      []int{1, 2},
 		}
 		cloned := orig.Clone()
 
 		So(cloned.SampleName, ShouldEqual, "sample1")
+		So(cloned.Lanes, ShouldResemble, []int{1, 2})
 		cloned.SampleName = "sample2"
+		cloned.Lanes[0] = 3
 
 		So(orig.SampleName, ShouldEqual, "sample1")
+		So(orig.Lanes, ShouldResemble, []int{1, 2})
 	})
 }